@@ -187,19 +187,24 @@ func (b *Local) localRunDirect(op *backend.Operation, run *backend.LocalRun, cor
 		))
 	}
 
+	opVariables := op.Variables
+	if op.TfContext != nil && len(op.TfContext.Unknown) > 0 {
+		opVariables = b.stubUnknownVariables(opVariables, op.TfContext.Unknown, config.Module.Variables)
+	}
+
 	var rawVariables map[string]backend.UnparsedVariableValue
 	if op.AllowUnsetVariables {
 		// Rather than prompting for input, we'll just stub out the required
 		// but unset variables with unknown values to represent that they are
 		// placeholders for values the user would need to provide for other
 		// operations.
-		rawVariables = b.stubUnsetRequiredVariables(op.Variables, config.Module.Variables)
+		rawVariables = b.stubUnsetRequiredVariables(opVariables, config.Module.Variables)
 	} else {
 		// If interactive input is enabled, we might gather some more variable
 		// values through interactive prompts.
 		// TODO: Need to route the operation context through into here, so that
 		// the interactive prompts can be sensitive to its timeouts/etc.
-		rawVariables = b.interactiveCollectVariables(context.TODO(), op.Variables, config.Module.Variables, op.UIIn)
+		rawVariables = b.interactiveCollectVariables(context.TODO(), opVariables, config.Module.Variables, op.UIIn)
 	}
 
 	variables, varDiags := backend.ParseVariableValues(rawVariables, config.Module.Variables)
@@ -495,6 +500,22 @@ func (b *Local) stubUnsetRequiredVariables(existing map[string]backend.UnparsedV
 	return ret
 }
 
+// stubUnknownVariables sets the named variables to unknown values, so that
+// values of other mantis tasks which are only known after apply are planned
+// as such.
+func (b *Local) stubUnknownVariables(existing map[string]backend.UnparsedVariableValue, names []string, vcs map[string]*configs.Variable) map[string]backend.UnparsedVariableValue {
+	ret := make(map[string]backend.UnparsedVariableValue, len(existing)+len(names))
+	for k, v := range existing {
+		ret[k] = v
+	}
+	for _, name := range names {
+		if vc, ok := vcs[name]; ok {
+			ret[name] = unparsedUnknownVariableValue{Name: name, WantType: vc.Type}
+		}
+	}
+	return ret
+}
+
 type unparsedInteractiveVariableValue struct {
 	Name, RawValue string
 }
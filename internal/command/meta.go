@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/terraform-svchost/disco"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/colorstring"
@@ -709,6 +710,12 @@ func (m *Meta) showDiagnostics(vals ...interface{}) {
 
 	diags = diags.ConsolidateWarnings(1)
 
+	sources := m.configSources()
+	if m.MantisConfig != nil && m.MantisConfig.SourceMap != nil {
+		diags = tfdiags.MapSources(diags, m.MantisConfig.SourceMap)
+		sources = tfdiags.MergeSources(sources, m.MantisConfig.SourceMap)
+	}
+
 	// Since warning messages are generally competing
 	if m.compactWarnings {
 		// If the user selected compact warnings and all of the diagnostics are
@@ -734,9 +741,9 @@ func (m *Meta) showDiagnostics(vals ...interface{}) {
 	for _, diag := range diags {
 		var msg string
 		if m.Color {
-			msg = format.Diagnostic(diag, m.configSources(), m.Colorize(), outputWidth)
+			msg = format.Diagnostic(diag, sources, m.Colorize(), outputWidth)
 		} else {
-			msg = format.DiagnosticPlain(diag, m.configSources(), outputWidth)
+			msg = format.DiagnosticPlain(diag, sources, outputWidth)
 		}
 
 		switch diag.Severity() {
@@ -841,7 +848,13 @@ func (m *Meta) checkRequiredVersion() tfdiags.Diagnostics {
 		return diags
 	}
 
-	config, configDiags := loader.LoadConfig(pwd)
+	var config *configs.Config
+	var configDiags hcl.Diagnostics
+	if m.MantisConfig != nil {
+		config, configDiags = loader.LoadConfigFromString(m.MantisConfig, pwd)
+	} else {
+		config, configDiags = loader.LoadConfig(pwd)
+	}
 	if configDiags.HasErrors() {
 		diags = diags.Append(configDiags)
 		return diags
@@ -449,6 +449,7 @@ func (m *Meta) Operation(b backend.Backend, vt arguments.ViewType, enc encryptio
 		Workspace:       workspace,
 		StateLocker:     stateLocker,
 		DependencyLocks: depLocks,
+		ConfigDetails:   m.MantisConfig,
 	}
 }
 
@@ -391,6 +391,9 @@ func (m *Meta) initConfigLoader() (*configload.Loader, error) {
 		m.configLoader = loader
 		if m.View != nil {
 			m.View.SetConfigSources(loader.Sources)
+			if m.MantisConfig != nil {
+				m.View.SetSourceMapper(m.MantisConfig.SourceMap)
+			}
 		}
 	}
 	return m.configLoader, nil
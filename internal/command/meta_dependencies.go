@@ -8,6 +8,7 @@ package command
 import (
 	"log"
 	"os"
+	"path/filepath"
 
 	"github.com/opentofu/opentofu/internal/depsfile"
 	"github.com/opentofu/opentofu/internal/tfdiags"
@@ -52,12 +53,12 @@ func (m *Meta) lockedDependencies() (*depsfile.Locks, tfdiags.Diagnostics) {
 	// with no locks. There is in theory a race condition here in that
 	// the file could be created or removed in the meantime, but we're not
 	// promising to support two concurrent dependency installation processes.
-	_, err := os.Stat(dependencyLockFilename)
+	_, err := os.Stat(m.dependencyLockFile())
 	if os.IsNotExist(err) {
 		return m.annotateDependencyLocksWithOverrides(depsfile.NewLocks()), nil
 	}
 
-	ret, diags := depsfile.LoadLocksFromFile(dependencyLockFilename)
+	ret, diags := depsfile.LoadLocksFromFile(m.dependencyLockFile())
 	return m.annotateDependencyLocksWithOverrides(ret), diags
 }
 
@@ -65,7 +66,16 @@ func (m *Meta) lockedDependencies() (*depsfile.Locks, tfdiags.Diagnostics) {
 // current working directory to contain the information recorded in the given
 // locks object.
 func (m *Meta) replaceLockedDependencies(new *depsfile.Locks) tfdiags.Diagnostics {
-	return depsfile.SaveLocksToFile(new, dependencyLockFilename)
+	return depsfile.SaveLocksToFile(new, m.dependencyLockFile())
+}
+
+// dependencyLockFile returns the path of the lock file, which is in the
+// working directory of the task when running a mantis configuration.
+func (m *Meta) dependencyLockFile() string {
+	if m.MantisConfig != nil && m.MantisConfig.WorkingDir != "" {
+		return filepath.Join(m.MantisConfig.WorkingDir, dependencyLockFilename)
+	}
+	return dependencyLockFilename
 }
 
 // annotateDependencyLocksWithOverrides modifies the given Locks object in-place
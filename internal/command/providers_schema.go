@@ -9,10 +9,14 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/opentofu/opentofu/internal/addrs"
 	"github.com/opentofu/opentofu/internal/backend"
 	"github.com/opentofu/opentofu/internal/command/arguments"
 	"github.com/opentofu/opentofu/internal/command/jsonprovider"
+	"github.com/opentofu/opentofu/internal/providercache"
+	"github.com/opentofu/opentofu/internal/providers"
 	"github.com/opentofu/opentofu/internal/tfdiags"
+	"github.com/opentofu/opentofu/internal/tofu"
 )
 
 // ProvidersCommand is a Command implementation that prints out information
@@ -119,6 +123,29 @@ func (c *ProvidersSchemaCommand) Run(args []string) int {
 	return 0
 }
 
+// CachedProviderSchema starts the given locally cached provider plugin and
+// returns its schema in the same form as "providers schema -json", without
+// requiring a configuration or an initialized working directory.
+func CachedProviderSchema(cached *providercache.CachedProvider) (*jsonprovider.Provider, error) {
+	provider, err := providerFactory(cached)()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start provider %s: %w", cached.Provider, err)
+	}
+	defer provider.Close()
+
+	resp := provider.GetProviderSchema()
+	if resp.Diagnostics.HasErrors() {
+		return nil, resp.Diagnostics.Err()
+	}
+
+	schemas := jsonprovider.MarshalForRenderer(&tofu.Schemas{
+		Providers: map[addrs.Provider]providers.ProviderSchema{
+			cached.Provider: resp,
+		},
+	})
+	return schemas[cached.Provider.String()], nil
+}
+
 const providersSchemaCommandHelp = `
 Usage: tofu [global options] providers schema -json
 
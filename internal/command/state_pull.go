@@ -24,6 +24,7 @@ type StatePullCommand struct {
 func (c *StatePullCommand) Run(args []string) int {
 	args = c.Meta.process(args)
 	cmdFlags := c.Meta.defaultFlagSet("state pull")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", "", "path")
 	if err := cmdFlags.Parse(args); err != nil {
 		c.Ui.Error(fmt.Sprintf("Error parsing command-line flags: %s\n", err.Error()))
 		return 1
@@ -98,6 +99,12 @@ Usage: tofu [global options] state pull [options]
   The primary use of this is for state stored remotely. This command
   will still work with local state but is less useful for this.
 
+Options:
+
+  -state=statefile    Path to a OpenTofu state file to pull. By default,
+                      OpenTofu will consult the state of the
+                      currently-selected workspace.
+
 `
 	return strings.TrimSpace(helpText)
 }
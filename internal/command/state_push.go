@@ -36,6 +36,7 @@ func (c *StatePushCommand) Run(args []string) int {
 	cmdFlags.BoolVar(&flagForce, "force", false, "")
 	cmdFlags.BoolVar(&c.Meta.stateLock, "lock", true, "lock state")
 	cmdFlags.DurationVar(&c.Meta.stateLockTimeout, "lock-timeout", 0, "lock timeout")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", "", "path")
 	if err := cmdFlags.Parse(args); err != nil {
 		c.Ui.Error(fmt.Sprintf("Error parsing command-line flags: %s\n", err.Error()))
 		return 1
@@ -192,6 +193,10 @@ Options:
 
   -lock-timeout=0s    Duration to retry a state lock.
 
+  -state=statefile    Path to a OpenTofu state file to overwrite. By
+                      default, OpenTofu will use the state of the
+                      currently-selected workspace.
+
 `
 	return strings.TrimSpace(helpText)
 }
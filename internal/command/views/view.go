@@ -38,6 +38,10 @@ type View struct {
 	// will be dereferenced as late as possible when rendering diagnostics in
 	// order to access the config loader cache.
 	configSources func() map[string][]byte
+
+	// sourceMapper, if set, translates diagnostics for generated
+	// configuration back to the files it was generated from.
+	sourceMapper tfdiags.SourceMapper
 }
 
 // Initialize a View with the given streams, a disabled colorize object, and a
@@ -83,6 +87,17 @@ func (v *View) SetConfigSources(cb func() map[string][]byte) {
 	v.configSources = cb
 }
 
+// SetSourceMapper registers a mapper used to point diagnostics for generated
+// configuration at the files the configuration was generated from.
+func (v *View) SetSourceMapper(mapper tfdiags.SourceMapper) {
+	v.sourceMapper = mapper
+}
+
+// sources returns the config sources along with those of the source mapper.
+func (v *View) sources() map[string][]byte {
+	return tfdiags.MergeSources(v.configSources(), v.sourceMapper)
+}
+
 // Diagnostics renders a set of warnings and errors in human-readable form.
 // Warnings are printed to stdout, and errors to stderr.
 func (v *View) Diagnostics(diags tfdiags.Diagnostics) {
@@ -93,6 +108,7 @@ func (v *View) Diagnostics(diags tfdiags.Diagnostics) {
 	}
 
 	diags = diags.ConsolidateWarnings(1)
+	diags = tfdiags.MapSources(diags, v.sourceMapper)
 
 	// Since warning messages are generally competing
 	if v.compactWarnings {
@@ -119,9 +135,9 @@ func (v *View) Diagnostics(diags tfdiags.Diagnostics) {
 	for _, diag := range diags {
 		var msg string
 		if v.colorize.Disable {
-			msg = format.DiagnosticPlain(diag, v.configSources(), v.streams.Stderr.Columns())
+			msg = format.DiagnosticPlain(diag, v.sources(), v.streams.Stderr.Columns())
 		} else {
-			msg = format.Diagnostic(diag, v.configSources(), v.colorize, v.streams.Stderr.Columns())
+			msg = format.Diagnostic(diag, v.sources(), v.colorize, v.streams.Stderr.Columns())
 		}
 
 		if diag.Severity() == tfdiags.Error {
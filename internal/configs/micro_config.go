@@ -5,10 +5,32 @@
 
 package configs
 
+import "github.com/opentofu/opentofu/internal/tfdiags"
+
 // MantisConfig holds configuration data with its identifier, content in bytes, and format.
 type MantisConfig struct {
 	Identifier       string
 	Content          []byte
 	Format           string // Expected values: "cue", "json", "hcl"
 	BackendStatePath string
+
+	// WorkingDir optionally isolates the data directory and dependency
+	// lock file of this configuration from other configurations run by
+	// the same process. The root module directory is unaffected.
+	WorkingDir string
+
+	// SourceMap optionally maps ranges in Content back to the
+	// files Content was generated from, for diagnostics.
+	SourceMap tfdiags.SourceMapper
+}
+
+// Filename returns the synthetic filename that Content is parsed as,
+// which is the filename reported in diagnostics.
+func (c *MantisConfig) Filename() string {
+	switch c.Format {
+	case "json":
+		return "input.json"
+	default:
+		return "input.hcl"
+	}
 }
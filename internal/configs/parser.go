@@ -109,9 +109,9 @@ func (p *Parser) LoadHCLString(configDetails *MantisConfig) (hcl.Body, hcl.Diagn
 
 	switch configDetails.Format {
 	case "json":
-		file, diags = p.p.ParseJSON(configDetails.Content, "input.json")
+		file, diags = p.p.ParseJSON(configDetails.Content, configDetails.Filename())
 	default:
-		file, diags = p.p.ParseHCL(configDetails.Content, "input.hcl")
+		file, diags = p.p.ParseHCL(configDetails.Content, configDetails.Filename())
 	}
 
 	// If the returned file or body is nil, then we'll return a non-nil empty
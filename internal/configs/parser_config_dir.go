@@ -59,16 +59,12 @@ func (p *Parser) LoadConfigDir(path string) (*Module, hcl.Diagnostics) {
 
 // LoadConfigFromStr reads the configuration from a string and behaves similarly to LoadConfigDir.
 func (p *Parser) LoadConfigFromStr(configDetails *MantisConfig, currentDir string) (*Module, hcl.Diagnostics) {
-	// Simulate reading files from a directory by parsing the configuration string
-	primary, primaryDiags := p.loadConfigFromString(configDetails, false)
-	override, overrideDiags := p.loadConfigFromString(configDetails, true)
+	// Simulate reading files from a directory by parsing the configuration string.
+	// The string is the only, primary, file of the module: loading it again as
+	// an override would reject the moved, removed and import blocks it declares.
+	primary, diags := p.loadConfigFromString(configDetails, false)
 
-	var diags hcl.Diagnostics
-	diags = append(diags, primaryDiags...)
-	diags = append(diags, overrideDiags...)
-
-	// Combine primary and override configurations into a single module
-	mod, modDiags := NewModule([]*File{primary}, []*File{override})
+	mod, modDiags := NewModule([]*File{primary}, nil)
 	diags = append(diags, modDiags...)
 
 	// Set the source directory to the current directory
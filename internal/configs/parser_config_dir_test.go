@@ -238,6 +238,48 @@ func TestParserLoadConfigDirFailure(t *testing.T) {
 
 }
 
+// TestParserLoadConfigFromStr verifies that a configuration string is loaded
+// as the only file of its module, so the moved, removed and import blocks it
+// declares are accepted, as they are not allowed in override files.
+func TestParserLoadConfigFromStr(t *testing.T) {
+	parser := NewParser(nil)
+	config := &MantisConfig{
+		Identifier: "network.subnets",
+		Format:     "hcl",
+		Content: []byte(`
+resource "terraform_data" "b" {}
+
+moved {
+  from = terraform_data.a
+  to   = terraform_data.b
+}
+
+removed {
+  from = terraform_data.c
+}
+
+import {
+  to = terraform_data.b
+  id = "b"
+}
+`),
+	}
+
+	mod, diags := parser.LoadConfigFromStr(config, ".")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	if got := len(mod.Moved); got != 1 {
+		t.Errorf("wrong number of moved blocks %d; want 1", got)
+	}
+	if got := len(mod.Removed); got != 1 {
+		t.Errorf("wrong number of removed blocks %d; want 1", got)
+	}
+	if got := len(mod.Import); got != 1 {
+		t.Errorf("wrong number of import blocks %d; want 1", got)
+	}
+}
+
 func TestIsEmptyDir(t *testing.T) {
 	val, err := IsEmptyDir(filepath.Join("testdata", "valid-files"))
 	if err != nil {
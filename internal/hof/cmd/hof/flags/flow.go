@@ -28,6 +28,7 @@ type FlowPflagpole struct {
 	Bulk     string
 	Parallel int
 	Progress bool
+	Resume   bool
 }
 
 func SetupFlowPflags(fset *pflag.FlagSet, fpole *FlowPflagpole) {
@@ -0,0 +1,262 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/flow/tasker"
+	"github.com/opentofu/opentofu/internal/hof/flow/tasks/kubernetes"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// TaskDrift is the drift of a task from the config it was last applied with
+type TaskDrift struct {
+	Flow    string   `json:"flow"`
+	Task    string   `json:"task"`
+	Type    string   `json:"type"`
+	Drifted bool     `json:"drifted"`
+	Changes []string `json:"changes,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Drift checks every task, or a single task, applied by the flows in args
+// for changes made outside of mantis. Tasks are evaluated like mantis render
+// does, with the vars and outs recorded by the last apply of their flow. TF
+// tasks are refreshed without touching their state, the live objects of K8s
+// tasks are compared to their manifests. It reports whether any task drifted,
+// and fails when the drift of a task could not be checked.
+func Drift(dir string, args []string, rflags flags.RootPflagpole, taskID string, asJSON bool) (bool, error) {
+	R, err := prepRuntime(args, rflags, flags.FlowPflagpole{})
+	if err != nil {
+		return false, err
+	}
+	journals, err := loadJournals(dir)
+	if err != nil {
+		return false, err
+	}
+	names, roots, err := flowRoots(R)
+	if err != nil {
+		return false, err
+	}
+	unsealJournals(journals, roots)
+	vars, err := renderVars(journals, "")
+	if err != nil {
+		return false, err
+	}
+
+	var (
+		report  []TaskDrift
+		drifted bool
+		failed  int
+	)
+	for _, name := range names {
+		journal := journals[name]
+		if journal == nil {
+			continue
+		}
+		root := renderOuts(roots[name], journal)
+		tasks, types, err := tasker.FindTasks(root)
+		if err != nil {
+			return false, err
+		}
+
+		prefix := len(root.Path().Selectors())
+		for i, t := range tasks {
+			id := cue.MakePath(t.Path().Selectors()[prefix:]...).String()
+			entry := journal.Tasks[id]
+			if entry == nil || entry.Status != task.StatusCompleted || (taskID != "" && id != taskID) {
+				continue
+			}
+			if types[i] != mantis.MantisTaskTF && types[i] != mantis.MantisTaskK8s {
+				continue
+			}
+
+			ctx := flowctx.New()
+			ctx.FlowName = name
+			ctx.FlowPath = root.Path().String()
+			ctx.Vars = vars
+			ctx.CueContext = R.CueContext
+			val, run, err := tasker.RenderTask(ctx, id, types[i], t)
+			if err == nil && !run {
+				continue
+			}
+
+			d := TaskDrift{Flow: name, Task: id, Type: types[i]}
+			if err == nil {
+				switch types[i] {
+				case mantis.MantisTaskTF:
+					var config []byte
+					if config, err = val.LookupPath(cue.ParsePath("config")).MarshalJSON(); err == nil {
+						d.Changes, err = tfDrift(dir, id, config, asJSON)
+					}
+				case mantis.MantisTaskK8s:
+					d.Changes, err = k8sDrift(val, entry)
+				}
+			}
+			if err != nil {
+				d.Error = err.Error()
+				failed++
+			}
+			d.Drifted = len(d.Changes) > 0
+			drifted = drifted || d.Drifted
+			report = append(report, d)
+		}
+	}
+
+	if taskID != "" && len(report) == 0 {
+		return false, fmt.Errorf("no applied task %q in the flows, has its flow been applied?", taskID)
+	}
+
+	if asJSON {
+		if report == nil {
+			report = []TaskDrift{}
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return drifted, err
+		}
+		fmt.Println(string(data))
+	} else {
+		printDrift(report)
+	}
+
+	if failed > 0 {
+		return drifted, fmt.Errorf("the drift of %d task(s) could not be checked", failed)
+	}
+	return drifted, nil
+}
+
+// tfDrift runs a refresh-only plan of a TF task and returns the resources
+// which changed outside of mantis
+func tfDrift(dir, taskID string, config []byte, quiet bool) ([]string, error) {
+	statePath := filepath.Join(dir, mantis.TaskStatePath(taskID))
+	if _, err := os.Stat(statePath); err != nil {
+		// nothing was applied, so nothing can drift
+		return nil, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "mantis-drift-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	planPath := filepath.Join(tmp, "drift.tfplan")
+
+	op, err := runTaskCommand(dir, taskID, config, quiet, "plan", "-refresh-only", "-input=false", "-state="+statePath, "-out="+planPath)
+	if err != nil {
+		return nil, err
+	}
+	if op.PlanEmpty {
+		return nil, nil
+	}
+
+	plan, err := readTaskPlan(planPath, config)
+	if err != nil {
+		return nil, err
+	}
+	var changes []string
+	for _, rc := range plan.DriftedResources {
+		changes = append(changes, fmt.Sprintf("%s %s", rc.Addr, strings.ToLower(rc.Action.String())))
+	}
+	if len(changes) == 0 {
+		// drift in outputs or resources only some of whose attributes are
+		// tracked is not listed, yet the refresh would update the state
+		changes = append(changes, "state differs from the infrastructure")
+	}
+	return changes, nil
+}
+
+// k8sDrift compares the live object of a K8s task to its manifest, in the
+// cluster of the task
+func k8sDrift(val cue.Value, entry *flowctx.JournalEntry) ([]string, error) {
+	var cluster kubernetes.Cluster
+	if cv := val.LookupPath(cue.ParsePath(mantis.MantisTaskCluster)); cv.Exists() {
+		if err := cv.Decode(&cluster); err != nil {
+			return nil, fmt.Errorf("invalid cluster: %v", err)
+		}
+	}
+	var ignoreFields []string
+	val.LookupPath(cue.ParsePath(mantis.MantisTaskIgnoreFields)).Decode(&ignoreFields)
+
+	var manifest map[string]interface{}
+	if err := val.LookupPath(cue.ParsePath("config")).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	pinned, err := pinnedConfig(manifest, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.ClientFor(cluster)
+	if err != nil {
+		return nil, err
+	}
+	return client.Drift(gocontext.Background(), pinned, ignoreFields)
+}
+
+// pinnedConfig returns the manifest of a K8s task with the images it pinned
+// when last applied, out.images, which is what the cluster runs
+func pinnedConfig(manifest map[string]interface{}, entry *flowctx.JournalEntry) (string, error) {
+	var out struct {
+		Images map[string]string `json:"images"`
+	}
+	if len(entry.Out) > 0 {
+		json.Unmarshal(entry.Out, &out)
+	}
+	if len(out.Images) > 0 {
+		kubernetes.PinImages(manifest, func(image string) (string, error) {
+			if ref, ok := out.Images[image]; ok {
+				return ref, nil
+			}
+			return image, nil
+		})
+	}
+	data, err := json.Marshal(manifest)
+	return string(data), err
+}
+
+func printDrift(report []TaskDrift) {
+	if len(report) == 0 {
+		fmt.Println("No applied tasks recorded. Tasks are recorded when a flow is applied with --apply.")
+		return
+	}
+	flow := ""
+	for _, d := range report {
+		if d.Flow != flow {
+			flow = d.Flow
+			fmt.Printf("%s:\n", flow)
+		}
+		switch {
+		case d.Error != "":
+			fmt.Printf("  %s: error: %s\n", d.Task, d.Error)
+		case d.Drifted:
+			fmt.Printf("  %s: drifted\n", d.Task)
+			for _, c := range d.Changes {
+				fmt.Printf("    %s\n", c)
+			}
+		default:
+			fmt.Printf("  %s: no drift\n", d.Task)
+		}
+	}
+}
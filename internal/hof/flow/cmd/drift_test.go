@@ -0,0 +1,167 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestTFDrift(t *testing.T) {
+	// tofu only runs in a directory with a flow
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte("package flows\n"), 0644))
+
+	dir := "."
+	config := []byte(`{
+  "resource": {"terraform_data": {"marker": {"input": "a"}}},
+  "output": {"marker": {"value": "${terraform_data.marker.output}"}}
+}`)
+
+	changes, err := tfDrift(dir, "net", config, true)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "a task which was never applied can not drift")
+
+	statePath := filepath.Join(dir, mantis.TaskStatePath("net"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(statePath), 0755))
+	_, err = runTaskCommand(dir, "net", config, true, "apply", "-input=false", "-auto-approve", "-state="+statePath)
+	require.NoError(t, err)
+
+	changes, err = tfDrift(dir, "net", config, true)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// an output changed outside of mantis is drift without a drifted resource
+	state, err := os.ReadFile(statePath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(statePath, []byte(strings.Replace(string(state), `"value": "a"`, `"value": "b"`, 1)), 0644))
+
+	changes, err = tfDrift(dir, "net", config, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"state differs from the infrastructure"}, changes)
+}
+
+func TestTFDriftEncrypted(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte("package flows\n"), 0644))
+
+	dir := "."
+	config := func(output string) []byte {
+		return []byte(`{
+  "terraform": {"encryption": {
+    "key_provider": {"pbkdf2": {"k": {"passphrase": "correct horse battery staple"}}},
+    "method": {"aes_gcm": {"m": {"keys": "${key_provider.pbkdf2.k}"}}},
+    "state": {"method": "${method.aes_gcm.m}"},
+    "plan": {"method": "${method.aes_gcm.m}"}
+  }},
+  "resource": {"terraform_data": {"marker": {"input": "a"}}},
+  "output": {"marker": {"value": "` + output + `"}}
+}`)
+	}
+
+	statePath := filepath.Join(dir, mantis.TaskStatePath("net"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(statePath), 0755))
+	_, err = runTaskCommand(dir, "net", config("a"), true, "apply", "-input=false", "-auto-approve", "-state="+statePath)
+	require.NoError(t, err)
+
+	// the refresh plan is encrypted as the plans of the task are
+	changes, err := tfDrift(dir, "net", config("b"), true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"state differs from the infrastructure"}, changes)
+}
+
+// TestDrift checks a TF task of a flow, evaluated with the exports recorded
+// by the last apply of the flow
+func TestDrift(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte(`package flows
+
+f: {
+	@flow(f)
+	net: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: vpc: input: "10.0.0.0/16"
+		exports: [{var: "cidr", jqpath: ".terraform_data.vpc.input"}]
+	}
+	app: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: app: input: string @var(cidr)
+	}
+}
+`), 0644))
+	require.NoError(t, os.MkdirAll("mantis_state", 0755))
+	require.NoError(t, os.WriteFile(fmt.Sprintf(mantis.MantisJournalPath, "f"), []byte(`{
+  "flow": "f",
+  "tasks": {
+    "net": {"status": "completed", "type": "mantis.core.TF", "exports": [{"name": "cidr", "value": "10.0.0.0/16"}]},
+    "app": {"status": "completed", "type": "mantis.core.TF"}
+  }
+}`), 0600))
+
+	statePath := mantis.TaskStatePath("app")
+	require.NoError(t, os.MkdirAll(filepath.Dir(statePath), 0755))
+	config := []byte(`{"resource": {"terraform_data": {"app": {"input": "10.0.0.0/16"}}}}`)
+	_, err = runTaskCommand(".", "app", config, true, "apply", "-input=false", "-auto-approve", "-state="+statePath)
+	require.NoError(t, err)
+
+	drifted, err := Drift(".", nil, flags.RootPflagpole{}, "app", true)
+	require.NoError(t, err)
+	assert.False(t, drifted, "the var of app resolves to the recorded export")
+
+	_, err = Drift(".", nil, flags.RootPflagpole{}, "missing", true)
+	assert.ErrorContains(t, err, `no applied task "missing"`)
+}
+
+func TestPinnedConfig(t *testing.T) {
+	manifest := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "app"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "nginx:1.25"},
+				map[string]interface{}{"name": "sidecar", "image": "envoy:1.30"},
+			},
+		},
+	}
+	entry := &flowctx.JournalEntry{Out: []byte(`{"pod": {}, "images": {"nginx:1.25": "nginx:1.25@sha256:abc"}}`)}
+
+	pinned, err := pinnedConfig(manifest, entry)
+	require.NoError(t, err)
+	assert.Contains(t, pinned, `"image":"nginx:1.25@sha256:abc"`)
+	assert.Contains(t, pinned, `"image":"envoy:1.30"`, "images which were not pinned are kept")
+
+	pinned, err = pinnedConfig(manifest, &flowctx.JournalEntry{})
+	require.NoError(t, err)
+	assert.Contains(t, pinned, `"image":"envoy:1.30"`)
+}
@@ -0,0 +1,106 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/opentofu/opentofu/internal/hof/flow/tasks/kubernetes"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// ImportK8sOptions selects the live objects mantis import k8s adopts and
+// where their CUE is written
+type ImportK8sOptions struct {
+	Namespace string
+	Selector  string
+
+	// Cluster is the kubeconfig and context to read from, which the
+	// generated tasks deploy to as well
+	Cluster kubernetes.Cluster
+
+	// Secrets exports Secrets with their data in plain text, they are
+	// left out by default
+	Secrets bool
+
+	// Flow names the generated flow, the namespace by default
+	Flow string
+
+	Package string
+	Out     string
+}
+
+// ImportK8s reads the objects of a namespace matching a label selector and
+// writes them, without the fields populated by the cluster, as CUE
+// definitions and a flow of K8s tasks applying them, which plans without
+// changes against the cluster they were read from.
+func ImportK8s(opts ImportK8sOptions) error {
+	client, err := kubernetes.ClientFor(opts.Cluster)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+	objects, withheld, err := client.Export(gocontext.Background(), opts.Namespace, opts.Selector, opts.Secrets)
+	if err != nil {
+		return err
+	}
+	for _, obj := range withheld {
+		fmt.Printf("Left out Secret/%s, its data would be written in plain text: manage it with a K8sSecret task, or import it with --secrets\n", obj.GetName())
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("no objects found in namespace %s matching %q", opts.Namespace, opts.Selector)
+	}
+
+	if opts.Flow == "" {
+		opts.Flow = opts.Namespace
+	}
+	if opts.Out == "" {
+		opts.Out = fmt.Sprintf("k8s_%s.tf.cue", opts.Namespace)
+	}
+	if _, err := os.Stat(opts.Out); err == nil {
+		return fmt.Errorf("%s already exists, remove it or choose another file with --out", opts.Out)
+	}
+
+	var cluster map[string]any
+	data, err := json.Marshal(opts.Cluster)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &cluster); err != nil {
+		return err
+	}
+
+	manifests := make([]map[string]any, len(objects))
+	for i, obj := range objects {
+		manifests[i] = obj.Object
+	}
+	comment := fmt.Sprintf("imported from namespace %s", opts.Namespace)
+	if opts.Selector != "" {
+		comment += fmt.Sprintf(" with selector %s", opts.Selector)
+	}
+	src, err := mantis.ImportedK8sCUE(opts.Package, opts.Flow, manifests, cluster, comment)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.Out, src, 0644); err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		fmt.Printf("Imported %s/%s\n", obj.GetKind(), obj.GetName())
+	}
+	fmt.Printf("\nWrote %d objects to flow %s in %s. Check that they apply without changes with mantis run --plan.\n", len(objects), opts.Flow, opts.Out)
+	return nil
+}
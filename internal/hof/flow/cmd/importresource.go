@@ -0,0 +1,249 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/backend"
+	"github.com/opentofu/opentofu/internal/command"
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+	"github.com/opentofu/opentofu/internal/plans"
+	"github.com/opentofu/opentofu/internal/plans/planfile"
+)
+
+// MantisImportFile collects the configuration generated by mantis import,
+// next to the flow it belongs to
+const MantisImportFile = "imported.tf.cue"
+
+// ImportResource imports the resource with the given id into the state of a
+// TF task of the flow at flowPath, at address. Tofu generates its
+// configuration, which is written as CUE unifying with the task's config,
+// to out or to imported.tf.cue next to the flow. The import is planned
+// first, and applied only when it is all the plan does.
+func ImportResource(dir, flowPath, taskID, address, id, out string, rflags flags.RootPflagpole) error {
+	addr, diags := addrs.ParseAbsResourceInstanceStr(address)
+	if diags.HasErrors() {
+		return fmt.Errorf("invalid resource address %q: %v", address, diags.Err())
+	}
+	if !addr.Module.IsRoot() || addr.Resource.Key != addrs.NoKey || addr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+		return fmt.Errorf("%s: only managed resources of the task's root module, without count or for_each, can be imported", address)
+	}
+	res := addr.Resource.Resource
+
+	R, err := prepRuntime([]string{flowPath}, rflags, flags.FlowPflagpole{})
+	if err != nil {
+		return err
+	}
+	taskVal, err := lookupTFTask(R, taskID, flowPath)
+	if err != nil {
+		return err
+	}
+	config := taskVal.LookupPath(cue.ParsePath("config"))
+	if config.LookupPath(cue.MakePath(cue.Str("resource"), cue.Str(res.Type), cue.Str(res.Name))).Exists() {
+		return fmt.Errorf("%s is already configured in task %s", address, taskID)
+	}
+
+	// the provider configuration is enough to generate the resource's,
+	// the rest of the task may depend on values only known in a run
+	content := map[string]any{
+		"import": []map[string]string{{"to": address, "id": id}},
+	}
+	for _, section := range []string{"terraform", "provider"} {
+		v := config.LookupPath(cue.ParsePath(section))
+		if !v.Exists() {
+			continue
+		}
+		data, err := v.MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("the %s configuration of task %s must be concrete to import: %v", section, taskID, err)
+		}
+		content[section] = json.RawMessage(data)
+	}
+
+	tmp, err := os.MkdirTemp("", "mantis-import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	generated := filepath.Join(tmp, "generated.tf")
+
+	statePath := filepath.Join(dir, mantis.TaskStatePath(taskID))
+	fmt.Printf("Generating the configuration of %s in task %s...\n", address, taskID)
+	if err := runTaskOperation(dir, taskID, content, "plan", "-state="+statePath, "-generate-config-out="+generated); err != nil {
+		return err
+	}
+	src, err := os.ReadFile(generated)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no configuration was generated for %s, it may already be in the state of task %s", address, taskID)
+	} else if err != nil {
+		return err
+	}
+	resources, err := mantis.GeneratedResources(src, generated)
+	if err != nil {
+		return fmt.Errorf("failed to read the generated configuration: %w", err)
+	}
+
+	// the import is applied from a saved plan, checked to do nothing else
+	fmt.Printf("Importing %s into the state of task %s...\n", address, taskID)
+	content["resource"] = resources
+	planPath := filepath.Join(tmp, "import.tfplan")
+	if err := runTaskOperation(dir, taskID, content, "plan", "-input=false", "-state="+statePath, "-target="+address, "-out="+planPath); err != nil {
+		return err
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	plan, err := readTaskPlan(planPath, data)
+	if err != nil {
+		return err
+	}
+	if err := importOnly(plan, addr); err != nil {
+		return fmt.Errorf("not importing %s: %w", address, err)
+	}
+	if err := runTaskOperation(dir, taskID, content, "apply", "-input=false", "-state="+statePath, planPath); err != nil {
+		return err
+	}
+
+	if out == "" {
+		out = filepath.Join(R.BuildInstances[0].Dir, MantisImportFile)
+	}
+	pkg := R.BuildInstances[0].PkgName
+	if _, err := os.Stat(out); err == nil {
+		// append to the declarations of earlier imports
+		pkg = ""
+	}
+	cueSrc, err := mantis.ImportedCUE(pkg, taskVal.Path(), resources, fmt.Sprintf("imported %s with id %q", address, id))
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if pkg == "" {
+		cueSrc = append([]byte("\n"), cueSrc...)
+	}
+	if _, err := f.Write(cueSrc); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s\nWrote the configuration of %s to %s. Review it, it unifies with the config of task %s.\n", cueSrc, address, out, taskID)
+	return nil
+}
+
+// importOnly checks that the only change of plan is the import of addr,
+// which leaves the imported resource as it is
+func importOnly(plan *plans.Plan, addr addrs.AbsResourceInstance) error {
+	imported := false
+	for _, rc := range plan.Changes.Resources {
+		if !rc.Addr.Equal(addr) || rc.Importing == nil {
+			return fmt.Errorf("the plan would also %s %s", strings.ToLower(rc.Action.String()), rc.Addr)
+		}
+		if rc.Action != plans.NoOp {
+			return fmt.Errorf("the plan would %s %s once imported, its generated configuration does not match it", strings.ToLower(rc.Action.String()), rc.Addr)
+		}
+		imported = true
+	}
+	for _, oc := range plan.Changes.Outputs {
+		if oc.Action != plans.NoOp {
+			return fmt.Errorf("the plan would also %s %s", strings.ToLower(oc.Action.String()), oc.Addr)
+		}
+	}
+	if !imported {
+		return fmt.Errorf("the plan does not import it")
+	}
+	return nil
+}
+
+// readTaskPlan reads a plan file of a TF task, encrypted with the plan
+// encryption of its JSON config
+func readTaskPlan(path string, config []byte) (*plans.Plan, error) {
+	enc, err := mantis.TaskEncryption(config)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := planfile.Open(path, enc.Plan())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the plan: %w", err)
+	}
+	plan, err := reader.ReadPlan()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the plan: %w", err)
+	}
+	return plan, nil
+}
+
+// lookupTFTask returns the TF task with the given ID in the flows of R
+func lookupTFTask(R *Runtime, taskID, flowPath string) (cue.Value, error) {
+	for _, WF := range R.Workflows {
+		v := WF.Root.LookupPath(cue.ParsePath(taskID))
+		if v.Exists() && mantis.IsTask(v, mantis.MantisTaskTF) {
+			return v, nil
+		}
+	}
+	return cue.Value{}, fmt.Errorf("no TF task %s found in %s", taskID, flowPath)
+}
+
+// runTaskOperation runs tofu plan or apply with content as the configuration
+// of a TF task
+func runTaskOperation(dir, taskID string, content map[string]any, op string, args ...string) error {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	_, err = runTaskCommand(dir, taskID, data, false, op, args...)
+	return err
+}
+
+// runTaskCommand runs tofu plan or apply with the JSON config of a TF task
+func runTaskCommand(dir, taskID string, config []byte, quiet bool, op string, args ...string) (*backend.RunningOperation, error) {
+	commands, err := taskCommands(dir, taskID, config, quiet)
+	if err != nil {
+		return nil, err
+	}
+	c, err := commands[op]()
+	if err != nil {
+		return nil, err
+	}
+
+	tfContext := flowctx.NewTFContext(&sync.Map{})
+	var running *backend.RunningOperation
+	switch c := c.(type) {
+	case *command.PlanCommand:
+		running, err = c.RunAPI(args, tfContext)
+	case *command.ApplyCommand:
+		running, err = c.RunAPI(args, tfContext)
+	default:
+		return nil, fmt.Errorf("unsupported operation %s", op)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s of task %s failed: %w", op, taskID, err)
+	}
+	if running == nil || running.Result != backend.OperationSuccess {
+		return nil, fmt.Errorf("%s of task %s failed", op, taskID)
+	}
+	return running, nil
+}
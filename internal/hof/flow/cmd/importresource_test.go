@@ -0,0 +1,76 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+	"github.com/opentofu/opentofu/internal/plans"
+)
+
+func TestImportResource(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte(`package flows
+
+f: {
+	@flow(f)
+	app: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: marker: input: "a"
+	}
+}
+`), 0644))
+
+	require.NoError(t, ImportResource(".", ".", "app", "terraform_data.imported", "4a371858-cee6-757f-d64d-d3f22315debf", "", flags.RootPflagpole{}))
+
+	state, err := os.ReadFile(mantis.TaskStatePath("app"))
+	require.NoError(t, err)
+	assert.Contains(t, string(state), "4a371858-cee6-757f-d64d-d3f22315debf")
+	assert.NotContains(t, string(state), `"marker"`, "only the import is applied")
+	assert.FileExists(t, MantisImportFile)
+
+	err = ImportResource(".", ".", "app", "terraform_data.marker", "id", "", flags.RootPflagpole{})
+	assert.ErrorContains(t, err, "already configured in task app")
+}
+
+func TestImportOnly(t *testing.T) {
+	addr := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "aws_s3_bucket", Name: "logs"}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+	other := addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "aws_s3_bucket", Name: "data"}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+	change := func(addr addrs.AbsResourceInstance, action plans.Action, importing bool) *plans.ResourceInstanceChangeSrc {
+		rc := &plans.ResourceInstanceChangeSrc{Addr: addr, PrevRunAddr: addr}
+		rc.Action = action
+		if importing {
+			rc.Importing = &plans.ImportingSrc{ID: "logs"}
+		}
+		return rc
+	}
+	plan := func(changes ...*plans.ResourceInstanceChangeSrc) *plans.Plan {
+		return &plans.Plan{Changes: &plans.Changes{Resources: changes}}
+	}
+
+	assert.NoError(t, importOnly(plan(change(addr, plans.NoOp, true)), addr))
+	assert.ErrorContains(t, importOnly(plan(change(addr, plans.Update, true)), addr), "would update aws_s3_bucket.logs once imported")
+	assert.ErrorContains(t, importOnly(plan(change(addr, plans.NoOp, true), change(other, plans.Delete, false)), addr), "would also delete aws_s3_bucket.data")
+	assert.ErrorContains(t, importOnly(plan(change(addr, plans.Create, false)), addr), "would also create aws_s3_bucket.logs")
+	assert.ErrorContains(t, importOnly(plan(), addr), "does not import it")
+}
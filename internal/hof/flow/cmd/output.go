@@ -0,0 +1,127 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/yagu"
+)
+
+// Output prints the values exported by every task, or a single task, as
+// recorded in the run journals by the last apply of each flow. The values
+// of sensitive exports are printed as (sensitive), unless asJSON or raw is
+// set: they are then unsealed with the encryption of their task, found in
+// the flows in args.
+func Output(dir string, args []string, rflags flags.RootPflagpole, taskID string, asJSON, raw bool) error {
+	journals, err := loadJournals(dir)
+	if err != nil {
+		return err
+	}
+	if asJSON || raw {
+		if err := unsealOutputs(journals, args, rflags, taskID); err != nil {
+			return err
+		}
+	}
+
+	// flow -> task -> var -> value
+	outputs := map[string]map[string]map[string]interface{}{}
+	// flow -> task -> var, of sensitive exports
+	sensitive := map[string]map[string]map[string]bool{}
+	for _, flow := range yagu.SortedKeys(journals) {
+		journal := journals[flow]
+		for id, entry := range journal.Tasks {
+			if len(entry.Exports) == 0 || (taskID != "" && id != taskID) {
+				continue
+			}
+			if outputs[journal.Flow] == nil {
+				outputs[journal.Flow] = map[string]map[string]interface{}{}
+				sensitive[journal.Flow] = map[string]map[string]bool{}
+			}
+			vars := map[string]interface{}{}
+			sensitive[journal.Flow][id] = map[string]bool{}
+			for _, export := range entry.Exports {
+				vars[export.Name] = export.Value
+				sensitive[journal.Flow][id][export.Name] = export.Sensitive
+			}
+			outputs[journal.Flow][id] = vars
+		}
+	}
+
+	if taskID != "" && len(outputs) == 0 {
+		return fmt.Errorf("no exports recorded for task %q, has its flow been applied?", taskID)
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(outputs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(outputs) == 0 {
+		fmt.Println("No exports recorded. Exports are recorded when a flow is applied with --apply.")
+		return nil
+	}
+	for _, flow := range yagu.SortedKeys(outputs) {
+		fmt.Printf("%s:\n", flow)
+		for _, id := range yagu.SortedKeys(outputs[flow]) {
+			fmt.Printf("  %s:\n", id)
+			vars := outputs[flow][id]
+			for _, name := range yagu.SortedKeys(vars) {
+				if sensitive[flow][id][name] && !raw {
+					fmt.Printf("    %s = %s\n", name, flowctx.SensitiveValue)
+					continue
+				}
+				value, err := json.Marshal(vars[name])
+				if err != nil {
+					return err
+				}
+				fmt.Printf("    %s = %s\n", name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// unsealOutputs unseals the values of the sensitive exports of the journals,
+// loading the flows in args only when some are sealed
+func unsealOutputs(journals map[string]*flowctx.Journal, args []string, rflags flags.RootPflagpole, taskID string) error {
+	sealed := false
+	for _, journal := range journals {
+		for id, entry := range journal.Tasks {
+			if len(entry.Sealed) > 0 && (taskID == "" || id == taskID) {
+				sealed = true
+			}
+		}
+	}
+	if !sealed {
+		return nil
+	}
+
+	R, err := prepRuntime(args, rflags, flags.FlowPflagpole{})
+	if err != nil {
+		return fmt.Errorf("the values of sensitive exports are sealed, loading the flows to unseal them failed: %w", err)
+	}
+	_, roots, err := flowRoots(R)
+	if err != nil {
+		return err
+	}
+	unsealJournals(journals, roots)
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/flow/tasker"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+const outputFlow = `package flows
+
+f: {
+	@flow(f)
+	db: {
+		@task(mantis.core.TF)
+		config: {
+			resource: terraform_data: db: input: "db.internal"
+			terraform: encryption: {
+				key_provider: pbkdf2: k: passphrase: "correct horse battery staple"
+				method: aes_gcm: m: keys: "${key_provider.pbkdf2.k}"
+				state: method: "${method.aes_gcm.m}"
+			}
+		}
+	}
+}
+`
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestOutputSensitive(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte(outputFlow), 0644))
+
+	// the journal of the last apply, with the password sealed
+	db := cuecontext.New().CompileString(outputFlow).LookupPath(cue.ParsePath("f.db"))
+	entry := &flowctx.JournalEntry{
+		Status: task.StatusCompleted,
+		Type:   mantis.MantisTaskTF,
+		Exports: []flowctx.Export{
+			{Name: "host", Value: "db.internal"},
+			{Name: "password", Value: "hunter2", Sensitive: true},
+		},
+	}
+	config := tasker.EncryptionConfig(db)
+	require.NoError(t, entry.Seal(nil, func(data []byte) ([]byte, error) {
+		return mantis.SealTaskData(config, data)
+	}))
+	require.NotEmpty(t, entry.Sealed)
+	J, err := flowctx.NewJournal(fmt.Sprintf(mantis.MantisJournalPath, "f"), "f")
+	require.NoError(t, err)
+	require.NoError(t, J.Record("db", entry))
+
+	out := captureStdout(t, func() {
+		require.NoError(t, Output(".", nil, flags.RootPflagpole{}, "", false, false))
+	})
+	assert.Contains(t, out, `host = "db.internal"`)
+	assert.Contains(t, out, `password = (sensitive)`)
+	assert.NotContains(t, out, "hunter2")
+
+	out = captureStdout(t, func() {
+		require.NoError(t, Output(".", nil, flags.RootPflagpole{}, "db", false, true))
+	})
+	assert.Contains(t, out, `password = "hunter2"`)
+
+	out = captureStdout(t, func() {
+		require.NoError(t, Output(".", nil, flags.RootPflagpole{}, "", true, false))
+	})
+	var values map[string]map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(out), &values))
+	assert.Equal(t, "hunter2", values["f"]["db"]["password"])
+}
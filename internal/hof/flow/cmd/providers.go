@@ -0,0 +1,47 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// LockProviders pins one version of every provider across the TF tasks
+// initialized below dir
+func LockProviders(dir string) error {
+	locks, pending, err := mantis.LockProviders(dir)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for addr, lock := range locks.AllProviders() {
+		lines = append(lines, fmt.Sprintf("  %s %s", addr, lock.Version()))
+	}
+	sort.Strings(lines)
+
+	fmt.Println("Pinned providers for all tasks:")
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	if len(pending) > 0 {
+		fmt.Println("Not installed yet, run the flow with -I to install:")
+		for _, p := range pending {
+			fmt.Println("  " + p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	cueyaml "cuelang.org/go/pkg/encoding/yaml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/flow"
+	"github.com/opentofu/opentofu/internal/hof/flow/tasker"
+	"github.com/opentofu/opentofu/internal/hof/flow/tasks/kubernetes"
+	"github.com/opentofu/opentofu/internal/hof/lib/hof"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+	"github.com/opentofu/opentofu/internal/hof/lib/yagu"
+)
+
+// RenderOptions controls what mantis render writes
+type RenderOptions struct {
+	// Out is the directory receiving a directory per flow
+	Out string
+
+	// VarsFile holds values of vars, flow -> task -> var as printed by
+	// mantis output --json. They take precedence over persisted exports.
+	VarsFile string
+
+	// Kustomization writes a kustomization.yaml listing the manifests
+	Kustomization bool
+
+	// LockImages adds the digests of the images pinned for the first time
+	// to the image lock file of the flow
+	LockImages bool
+}
+
+// Render writes the config of every task of the flows in args without
+// running them: the manifests of K8s and Helm tasks to
+// <out>/<flow>/k8s/<task>.yaml and the config of TF tasks to
+// <out>/<flow>/tf/<task>/main.tf.json. Vars
+// are the exports persisted by the last apply of each flow, below dir, and
+// those of opts.VarsFile. References to the out of a task resolve to its
+// out as of the last apply.
+func Render(dir string, args []string, rflags flags.RootPflagpole, opts RenderOptions) error {
+	R, err := prepRuntime(args, rflags, flags.FlowPflagpole{})
+	if err != nil {
+		return err
+	}
+
+	journals, err := loadJournals(dir)
+	if err != nil {
+		return err
+	}
+	names, roots, err := flowRoots(R)
+	if err != nil {
+		return err
+	}
+	unsealJournals(journals, roots)
+	vars, err := renderVars(journals, opts.VarsFile)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range names {
+		root := roots[name]
+		if journal := journals[name]; journal != nil {
+			root = renderOuts(root, journal)
+		}
+
+		tasks, types, err := tasker.FindTasks(root)
+		if err != nil {
+			return err
+		}
+
+		outDir := filepath.Join(opts.Out, name)
+		k8sDir, tfDir := filepath.Join(outDir, "k8s"), filepath.Join(outDir, "tf")
+		// tasks removed from the flow must not linger in the output
+		for _, d := range []string{k8sDir, tfDir} {
+			if err := os.RemoveAll(d); err != nil {
+				return err
+			}
+		}
+
+		var manifests []string
+		prefix := len(root.Path().Selectors())
+		for i, t := range tasks {
+			id := cue.MakePath(t.Path().Selectors()[prefix:]...).String()
+			file := strings.NewReplacer(`"`, "", "/", "_").Replace(id)
+
+			ctx := flowctx.New()
+			ctx.FlowName = name
+			ctx.FlowPath = root.Path().String()
+			ctx.FlowDir = flowDir(R, root)
+			ctx.Vars = vars
+			ctx.CueContext = R.CueContext
+			val, run, err := tasker.RenderTask(ctx, id, types[i], t)
+			for _, w := range ctx.FlowWarnings {
+				fmt.Fprint(os.Stderr, "warning: "+w)
+			}
+			if err == nil && !run {
+				fmt.Printf("Skipping task %s (when is false)\n", id)
+				continue
+			}
+
+			config := val.LookupPath(cue.ParsePath("config"))
+			switch types[i] {
+			case mantis.MantisTaskK8s, mantis.MantisTaskK8sJob:
+				path := filepath.Join(k8sDir, file+".yaml")
+				if err == nil {
+					pin, _ := val.LookupPath(cue.ParsePath(mantis.MantisTaskPinImages)).Bool()
+					if pin {
+						lockPath := filepath.Join(ctx.FlowDir, mantis.MantisImageLockPath)
+						config, err = pinManifest(R.CueContext, config, lockPath, opts.LockImages)
+					}
+				}
+				if err == nil {
+					err = renderManifest(config, path)
+				}
+				if err == nil {
+					manifests = append(manifests, filepath.Base(path))
+				}
+				err = reportRender(id, path, err)
+			case mantis.MantisTaskHelm:
+				path := filepath.Join(k8sDir, file+".yaml")
+				if err == nil {
+					err = renderHelm(config, path)
+				}
+				if err == nil {
+					manifests = append(manifests, filepath.Base(path))
+				}
+				err = reportRender(id, path, err)
+			case mantis.MantisTaskTF:
+				path := filepath.Join(tfDir, file, "main.tf.json")
+				if err == nil {
+					err = renderTFConfig(config, path)
+				}
+				err = reportRender(id, path, err)
+			case mantis.MantisTaskK8sSecret:
+				// values are only decrypted on apply
+				fmt.Printf("Skipping task %s (secrets are not rendered)\n", id)
+				err = nil
+			default:
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error rendering task %s: %v\n", id, err)
+				}
+			}
+			if err != nil {
+				failed++
+			}
+		}
+
+		if opts.Kustomization && len(manifests) > 0 {
+			path := filepath.Join(k8sDir, "kustomization.yaml")
+			if err := writeKustomization(path, manifests); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %s\n", path)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d task(s) could not be rendered", failed)
+	}
+	return nil
+}
+
+// loadJournals reads the run journals below dir, by flow
+func loadJournals(dir string) (map[string]*flowctx.Journal, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, fmt.Sprintf(mantis.MantisJournalPath, "*")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	journals := map[string]*flowctx.Journal{}
+	for _, path := range paths {
+		journal, err := flowctx.LoadJournal(path)
+		if err != nil {
+			return nil, err
+		}
+		journals[journal.Flow] = journal
+	}
+	return journals, nil
+}
+
+// flowRoots returns the values of the flows of R, with the instances of
+// their for_each tasks expanded, by flow name, and the names in flow order
+func flowRoots(R *Runtime) ([]string, map[string]cue.Value, error) {
+	var names []string
+	roots := map[string]cue.Value{}
+	for _, WF := range R.Workflows {
+		root, err := tasker.ExpandForEach(WF.Root)
+		if err != nil {
+			return nil, nil, err
+		}
+		node, err := hof.ParseHof[flow.Flow](root)
+		if err != nil {
+			return nil, nil, err
+		}
+		name := flowName(node)
+		names = append(names, name)
+		roots[name] = root
+	}
+	return names, roots, nil
+}
+
+// unsealJournals decrypts the out and the sensitive exports which the
+// journals record sealed, by the encryption of their task in the flow roots.
+// Those which can not be decrypted are left out.
+func unsealJournals(journals map[string]*flowctx.Journal, roots map[string]cue.Value) {
+	for flow, journal := range journals {
+		root, ok := roots[flow]
+		for _, id := range yagu.SortedKeys(journal.Tasks) {
+			entry := journal.Tasks[id]
+			if len(entry.Sealed) == 0 {
+				continue
+			}
+			if !ok {
+				fmt.Fprintf(os.Stderr, "warning: flow %s is not loaded, the sealed out of task %s is left out\n", flow, id)
+				continue
+			}
+			unsealed, err := tasker.UnsealEntry(entry, root.LookupPath(cue.ParsePath(id)))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: the sealed out of task %s of flow %s is left out: %v\n", id, flow, err)
+				continue
+			}
+			journal.Tasks[id] = unsealed
+		}
+	}
+}
+
+// renderVars returns the exports persisted in the run journals, then the
+// values of varsFile
+func renderVars(journals map[string]*flowctx.Journal, varsFile string) (*flowctx.VarScope, error) {
+	vars := flowctx.NewVarScope()
+	for _, flow := range yagu.SortedKeys(journals) {
+		journal := journals[flow]
+		for _, id := range yagu.SortedKeys(journal.Tasks) {
+			for _, e := range journal.Tasks[id].Exports {
+				vars.Store(journal.Flow, id, e.Name, e.Value, e.Public)
+			}
+		}
+	}
+
+	if varsFile == "" {
+		return vars, nil
+	}
+	data, err := os.ReadFile(varsFile)
+	if err != nil {
+		return nil, err
+	}
+	// flow -> task -> var -> value, JSON being YAML as well
+	var values map[string]map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid vars file %s, expected flow -> task -> var -> value: %w", varsFile, err)
+	}
+	for _, flow := range yagu.SortedKeys(values) {
+		for _, task := range yagu.SortedKeys(values[flow]) {
+			for _, name := range yagu.SortedKeys(values[flow][task]) {
+				vars.Store(flow, task, name, values[flow][task][name], false)
+			}
+		}
+	}
+	return vars, nil
+}
+
+// renderOuts fills the out of the tasks of a flow recorded in its journal
+func renderOuts(root cue.Value, journal *flowctx.Journal) cue.Value {
+	for _, id := range yagu.SortedKeys(journal.Tasks) {
+		entry := journal.Tasks[id]
+		if len(entry.Out) == 0 {
+			continue
+		}
+		var out interface{}
+		if err := json.Unmarshal(entry.Out, &out); err != nil {
+			continue
+		}
+		sels := append(cue.ParsePath(id).Selectors(), cue.Str(mantis.MantisTaskOuts))
+		root = root.FillPath(cue.MakePath(sels...), out)
+	}
+	return root
+}
+
+// pinManifest pins the images of the manifest of a K8s task setting
+// pin_images to the digests of the image lock file at lockPath, which is
+// only written with lock
+func pinManifest(cuectx *cue.Context, config cue.Value, lockPath string, lock bool) (cue.Value, error) {
+	obj, _, err := kubernetes.PinManifest(config, lockPath, lock)
+	if err != nil {
+		return config, fmt.Errorf("failed to pin images: %v", err)
+	}
+	return cuectx.Encode(obj), nil
+}
+
+// renderManifest writes the manifest of a K8s task
+func renderManifest(config cue.Value, path string) error {
+	manifest, err := cueyaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return writeRendered(path, []byte(manifest))
+}
+
+// renderHelm writes the manifests of the release of a Helm task, rendered
+// by helm template
+func renderHelm(config cue.Value, path string) error {
+	manifest, err := kubernetes.HelmTemplate(gocontext.Background(), config)
+	if err != nil {
+		return err
+	}
+	return writeRendered(path, manifest)
+}
+
+func renderTFConfig(config cue.Value, path string) error {
+	var v interface{}
+	if err := config.Decode(&v); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeRendered(path, append(data, '\n'))
+}
+
+func writeKustomization(path string, manifests []string) error {
+	sort.Strings(manifests)
+	var sb strings.Builder
+	sb.WriteString("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n")
+	for _, m := range manifests {
+		fmt.Fprintf(&sb, "  - %s\n", m)
+	}
+	return writeRendered(path, []byte(sb.String()))
+}
+
+func writeRendered(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func reportRender(id, path string, err error) error {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering task %s: %v\n", id, err)
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// readTree returns the files below dir by their relative path
+func readTree(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	require.NoError(t, err)
+	return files
+}
+
+// TestRender renders testdata/render/flow, with the journal of its last
+// apply and a vars file, and compares the output to testdata/render/golden.
+// The values of its K8sSecret task, password: hunter2, are not rendered,
+// only the hash of their file is.
+func TestRender(t *testing.T) {
+	golden, err := filepath.Abs("testdata/render/golden")
+	require.NoError(t, err)
+	out := t.TempDir()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("testdata/render/flow"))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// left over from a previous render of a task which no longer exists
+	stale := filepath.Join(out, "f", "k8s", "gone.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0755))
+	require.NoError(t, os.WriteFile(stale, nil, 0644))
+
+	err = Render(".", nil, flags.RootPflagpole{}, RenderOptions{
+		Out:           out,
+		VarsFile:      "vars.yaml",
+		Kustomization: true,
+	})
+	require.NoError(t, err)
+	rendered := readTree(t, out)
+	assert.Equal(t, readTree(t, golden), rendered)
+	for path, data := range rendered {
+		assert.NotContains(t, data, "hunter2", path)
+	}
+}
+
+// TestRenderLockImages renders a flow pinning its images, which are only
+// added to the image lock file next to the flow with LockImages
+func TestRenderLockImages(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	image := strings.TrimPrefix(srv.URL, "http://") + "/app:v1"
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, image))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.Mkdir("deploy", 0755))
+	require.NoError(t, os.WriteFile("deploy/flow.tf.cue", []byte(`package flows
+
+f: {
+	@flow(f)
+	app: {
+		@task(mantis.core.K8s)
+		pin_images: true
+		config: {
+			apiVersion: "v1"
+			kind:       "Pod"
+			metadata: name: "app"
+			spec: containers: [{name: "app", image: "`+image+`"}]
+		}
+	}
+}
+`), 0644))
+
+	require.NoError(t, Render(".", []string{"./deploy"}, flags.RootPflagpole{}, RenderOptions{Out: "out"}))
+	manifest, err := os.ReadFile("out/f/k8s/app.yaml")
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), "@sha256:")
+	assert.NoFileExists(t, filepath.Join("deploy", mantis.MantisImageLockPath), "render does not lock images by default")
+	assert.NoFileExists(t, mantis.MantisImageLockPath)
+
+	require.NoError(t, Render(".", []string{"./deploy"}, flags.RootPflagpole{}, RenderOptions{Out: "out", LockImages: true}))
+	assert.FileExists(t, filepath.Join("deploy", mantis.MantisImageLockPath))
+	assert.NoFileExists(t, mantis.MantisImageLockPath, "the lock file is next to the flow")
+}
+
+// TestRenderHelm renders a Helm task with helm template, a stub printing
+// its arguments, and reports a task which can not be evaluated
+func TestRenderHelm(t *testing.T) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "helm"), []byte("#!/bin/sh\necho \"# helm $1 $2 $3\"\n"), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte(`package flows
+
+f: {
+	@flow(f)
+	web: {
+		@task(mantis.core.Helm)
+		config: {
+			release: "web"
+			chart:   "./charts/web"
+		}
+	}
+	bad: {
+		@task(os.Exec)
+		when: "yes"
+	}
+}
+`), 0644))
+
+	var renderErr error
+	stderr := captureStderr(t, func() {
+		renderErr = Render(".", nil, flags.RootPflagpole{}, RenderOptions{Out: "out"})
+	})
+	assert.EqualError(t, renderErr, "1 task(s) could not be rendered")
+	assert.Contains(t, stderr, "Error rendering task bad: when must be a concrete bool")
+
+	manifest, err := os.ReadFile("out/f/k8s/web.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "# helm template web ./charts/web\n", string(manifest))
+}
+
+func TestRenderVars(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "mantis_state"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mantis_state", "journal_f.json"), []byte(`{
+  "flow": "f",
+  "tasks": {
+    "net": {"status": "completed", "exports": [{"name": "ip", "value": "10.0.0.1"}, {"name": "cidr", "value": "10.0.0.0/16", "public": true}]},
+    "svc": {"status": "completed", "out": {"service": {"spec": {"type": "LoadBalancer"}}}}
+  }
+}`), 0644))
+	varsFile := filepath.Join(dir, "vars.json")
+	require.NoError(t, os.WriteFile(varsFile, []byte(`{"f": {"net": {"ip": "10.0.0.2"}, "db": {"host": "db.internal"}}}`), 0644))
+
+	journals, err := loadJournals(dir)
+	require.NoError(t, err)
+	require.Contains(t, journals, "f")
+	assert.Contains(t, journals["f"].Tasks, "svc")
+
+	vars, err := renderVars(journals, varsFile)
+	require.NoError(t, err)
+
+	lookup := func(task, name string) interface{} {
+		v, ok := vars.Lookup("f", task+"."+name)
+		require.True(t, ok, "%s.%s", task, name)
+		return v
+	}
+	assert.Equal(t, "10.0.0.2", lookup("net", "ip"), "the vars file takes precedence")
+	assert.Equal(t, "10.0.0.0/16", lookup("net", "cidr"))
+	assert.Equal(t, "db.internal", lookup("db", "host"))
+
+	// without a vars file, only the journals are read
+	vars, err = renderVars(journals, "")
+	require.NoError(t, err)
+	v, _ := vars.Lookup("f", "net.ip")
+	assert.Equal(t, "10.0.0.1", v)
+
+	require.NoError(t, os.WriteFile(varsFile, []byte(`["not", "nested"]`), 0644))
+	_, err = renderVars(journals, varsFile)
+	assert.ErrorContains(t, err, "expected flow -> task -> var -> value")
+
+	_, err = renderVars(journals, filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
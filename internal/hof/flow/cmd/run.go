@@ -16,9 +16,14 @@
 package cmd
 
 import (
+	gocontext "context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 
 	"cuelang.org/go/cue"
 	"github.com/gammazero/workerpool"
@@ -30,16 +35,33 @@ import (
 	"github.com/opentofu/opentofu/internal/hof/flow/task"  // ensure tasks register
 	"github.com/opentofu/opentofu/internal/hof/flow/tasks" // ensure tasks register
 	"github.com/opentofu/opentofu/internal/hof/lib/hof"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
 )
 
-func prepFlow(R *Runtime, val cue.Value) (*flow.Flow, error) {
+// flowDir returns the directory of the CUE package declaring the flow val
+func flowDir(R *Runtime, val cue.Value) string {
+	if file := val.Pos().Filename(); file != "" {
+		return filepath.Dir(file)
+	}
+	if len(R.BuildInstances) > 0 {
+		return R.BuildInstances[0].Dir
+	}
+	return "."
+}
+
+func prepFlow(R *Runtime, val cue.Value, vars *flowctx.VarScope, goctx gocontext.Context) (*flow.Flow, error) {
 	node, err := hof.ParseHof[flow.Flow](val)
 	if err != nil {
 		return nil, err
 	}
 
 	c := flowctx.New()
+	c.GoContext = goctx
 	c.RootValue = val
+	c.Vars = vars
+	c.FlowPath = val.Path().String()
+	c.FlowName = flowName(node)
+	c.FlowDir = flowDir(R, val)
 	c.Stdin = os.Stdin
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
@@ -51,6 +73,21 @@ func prepFlow(R *Runtime, val cue.Value) (*flow.Flow, error) {
 	c.Gist = R.Flags.Gist
 	c.CueContext = R.CueContext
 
+	// journal applies so that a failed run can be resumed,
+	// bulk instances share a flow name and are not journaled
+	if c.Apply && R.FlowFlags.Bulk == "" {
+		path := fmt.Sprintf(mantis.MantisJournalPath, c.FlowName)
+		if R.FlowFlags.Resume {
+			c.Journal, err = flowctx.LoadJournal(path)
+		} else {
+			c.Journal, err = flowctx.NewJournal(path, c.FlowName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Resume = R.FlowFlags.Resume
+	}
+
 	// how to inject tags into original value
 	// fill / return value
 	middleware.UseDefaults(c, R.Flags, R.FlowFlags)
@@ -61,7 +98,24 @@ func prepFlow(R *Runtime, val cue.Value) (*flow.Flow, error) {
 	return f, err
 }
 
+// notifyContext is the context cancelled by SIGINT/SIGTERM, replaced in tests
+var notifyContext = signal.NotifyContext
+
 func Run(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole) error {
+	return runFlows(args, rflags, cflags, nil)
+}
+
+// runFlows runs the flows found in args, calling done, when set, with the
+// context of each flow which ran to its end, whether its tasks failed or not.
+// done is called concurrently when flows run in parallel.
+func runFlows(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole, done func(*flowctx.Context)) error {
+
+	if cflags.Resume && !rflags.Apply {
+		return fmt.Errorf("--resume can only be used with --apply")
+	}
+	if cflags.Resume && cflags.Bulk != "" {
+		return fmt.Errorf("--resume can not be used in bulk mode")
+	}
 
 	wp := workerpool.New(cflags.Parallel)
 
@@ -85,6 +139,26 @@ func Run(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole)
 
 	errCnt := 0
 
+	// SIGINT/SIGTERM cancel the flows: running tasks are asked to stop,
+	// no new tasks start. Restoring the default handlers right away lets
+	// a second signal terminate the process immediately.
+	goctx, stop := notifyContext(gocontext.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-goctx.Done():
+			stop()
+			fmt.Fprintln(os.Stderr, "\nInterrupt received, stopping running tasks. Send it again to exit immediately.")
+		case <-finished:
+		}
+	}()
+
+	// vars exported by all flows of this run,
+	// bulk instances each get an isolated scope below
+	vars := flowctx.NewVarScope()
+
 	for _, WF := range R.Workflows {
 
 		if R.Flags.Verbosity > 0 {
@@ -92,15 +166,36 @@ func Run(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole)
 		}
 
 		// runs the workflow in a single value
-		fn := func(val cue.Value) error {
+		fn := func(val cue.Value, vars *flowctx.VarScope) error {
+
+			if goctx.Err() != nil {
+				return fmt.Errorf("flow %s not started: interrupted", val.Path())
+			}
 
-			F, err := prepFlow(R, val)
+			F, err := prepFlow(R, val, vars, goctx)
 			if err != nil {
 				return err
 			}
 
 			err = F.Start()
+			if goctx.Err() != nil {
+				// the flow returns as soon as it is cancelled,
+				// wait for the tasks still stopping
+				F.FlowCtx.Running.Wait()
+				printInterruptSummary(F.FlowCtx)
+				return fmt.Errorf("flow %s interrupted", F.FlowCtx.FlowName)
+			}
+			if done != nil {
+				// report the tasks still running when a task failed as well
+				F.FlowCtx.Running.Wait()
+				done(F.FlowCtx)
+			}
 			if err != nil {
+				// the flow returns on the first failure, let the
+				// tasks still running finish so they are journaled
+				if F.FlowCtx.Journal != nil {
+					F.FlowCtx.Running.Wait()
+				}
 				return err
 			}
 
@@ -145,7 +240,7 @@ func Run(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole)
 
 					v := WF.Root.FillPath(cue.ParsePath(dst), data)
 
-					err := fn(v)
+					err := fn(v, vars.Isolate())
 					if err != nil {
 						fmt.Println(err)
 						errCnt += 1
@@ -158,7 +253,7 @@ func Run(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole)
 
 		} else {
 			wp.Submit(func() {
-				err := fn(WF.Root)
+				err := fn(WF.Root, vars)
 				if err != nil {
 					fmt.Println(err)
 					errCnt += 1
@@ -175,6 +270,47 @@ func Run(args []string, rflags flags.RootPflagpole, cflags flags.FlowPflagpole)
 	return nil
 }
 
+// flowName is the namespace under which a flow's task exports are stored
+func flowName(node *hof.Node[flow.Flow]) string {
+	if node == nil {
+		return ""
+	}
+	if node.Hof.Flow.Name != "" {
+		return node.Hof.Flow.Name
+	}
+	return node.Hof.Label
+}
+
+// printInterruptSummary lists the tasks of an interrupted flow by status
+func printInterruptSummary(ctx *flowctx.Context) {
+	byStatus := map[task.Status][]string{}
+	ctx.Tasks.Range(func(key, value interface{}) bool {
+		t := value.(*task.BaseTask)
+		byStatus[t.Status] = append(byStatus[t.Status], t.ID)
+		return true
+	})
+
+	fmt.Fprintf(os.Stderr, "\nInterrupted flow %s:\n", ctx.FlowName)
+	for _, status := range []task.Status{
+		task.StatusCompleted,
+		task.StatusFailed,
+		task.StatusInterrupted,
+		task.StatusSkipped,
+		task.StatusRunning,
+		task.StatusPending,
+	} {
+		ids := byStatus[status]
+		if len(ids) == 0 {
+			continue
+		}
+		sort.Strings(ids)
+		fmt.Fprintf(os.Stderr, "  %s:\n", status)
+		for _, id := range ids {
+			fmt.Fprintf(os.Stderr, "    %s\n", id)
+		}
+	}
+}
+
 func printFinalContext(ctx *flowctx.Context) error {
 	// to start, print ids / timings
 	// rebuild task dependencies with hof tasks from cue tasks
@@ -0,0 +1,112 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	gocontext "context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+)
+
+// interruptFlow starts slow, whose provisioner runs until it is stopped,
+// next to quick, with after waiting on slow
+const interruptFlow = `package flows
+
+f: {
+	@flow(f)
+
+	quick: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: quick: input: "a"
+	}
+
+	slow: {
+		@task(mantis.core.TF)
+		config: {
+			resource: terraform_data: slow: provisioner: "local-exec": command: "touch started && exec sleep 60"
+			output: id: value: "${terraform_data.slow.id}"
+		}
+	}
+
+	after: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: after: input: slow.out.id
+	}
+}
+`
+
+// captureStderr returns what f writes to stderr
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestRunInterrupted(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte(interruptFlow), 0644))
+
+	// cancelling the context stands in for SIGINT, once slow has started
+	// its provisioner
+	notify := notifyContext
+	t.Cleanup(func() { notifyContext = notify })
+	notifyContext = func(parent gocontext.Context, _ ...os.Signal) (gocontext.Context, gocontext.CancelFunc) {
+		ctx, cancel := gocontext.WithCancel(parent)
+		go func() {
+			for ctx.Err() == nil {
+				if _, err := os.Stat("started"); err == nil {
+					cancel()
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		return ctx, cancel
+	}
+
+	start := time.Now()
+	var runErr error
+	summary := captureStderr(t, func() {
+		runErr = Run([]string{"."}, flags.RootPflagpole{Apply: true}, flags.FlowPflagpole{Parallel: 1})
+	})
+	require.Error(t, runErr)
+
+	// tofu stopped the provisioner rather than waiting for it to finish
+	assert.Less(t, time.Since(start), 45*time.Second)
+	assert.Contains(t, summary, "Interrupted flow f:")
+	assert.Contains(t, summary, "  completed:\n    quick\n")
+	assert.Contains(t, summary, "  interrupted:\n    slow\n")
+	assert.Contains(t, summary, "  never started:\n    after\n")
+}
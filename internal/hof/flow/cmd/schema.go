@@ -0,0 +1,146 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/command"
+	"github.com/opentofu/opentofu/internal/command/cliconfig"
+	"github.com/opentofu/opentofu/internal/getproviders"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis/schema"
+	"github.com/opentofu/opentofu/internal/providercache"
+)
+
+// ImportProviderSchema generates CUE definitions for the resources and data
+// sources of a provider, which must already be installed (mantis run -I)
+// in the module or the plugin cache.
+func ImportProviderSchema(source, version, dir string) error {
+	addr, diags := addrs.ParseProviderSourceString(source)
+	if diags.HasErrors() {
+		return fmt.Errorf("invalid provider source %q: %w", source, diags.Err())
+	}
+	ver, err := getproviders.ParseVersion(version)
+	if err != nil {
+		return fmt.Errorf("invalid provider version %q: %w", version, err)
+	}
+
+	cached := findProvider(addr, ver, dir)
+	if cached == nil {
+		return fmt.Errorf("provider %s %s is not installed; run a flow using it with -I first, or set plugin_cache_dir", addr, version)
+	}
+
+	p, err := command.CachedProviderSchema(cached)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("provider %s returned no schema", addr)
+	}
+
+	out, err := schema.Provider(schema.ProviderInfo{
+		Source:  addr.String(),
+		Type:    addr.Type,
+		Version: ver.String(),
+	}, p)
+	if err != nil {
+		return err
+	}
+
+	return writeSchema(filepath.Join(dir, schema.GenDir, addr.String(), "schema.cue"), out)
+}
+
+// findProvider looks for an installed provider in the module's provider
+// directory first, then the shared plugin cache, then the working
+// directories of the tasks
+func findProvider(addr addrs.Provider, ver getproviders.Version, dir string) *providercache.CachedProvider {
+	dirs := []string{filepath.Join(dir, ".terraform", "providers")}
+	if cfg, diags := cliconfig.LoadConfig(); !diags.HasErrors() && cfg.PluginCacheDir != "" {
+		dirs = append(dirs, cfg.PluginCacheDir)
+	}
+	dirs = append(dirs, filepath.Join(dir, mantis.MantisPluginCacheDir))
+	if tasks, err := filepath.Glob(filepath.Join(dir, mantis.MantisTasksDir, "*", ".terraform", "providers")); err == nil {
+		dirs = append(dirs, tasks...)
+	}
+	for _, d := range dirs {
+		if cached := providercache.NewDir(d).ProviderVersion(addr, ver); cached != nil {
+			return cached
+		}
+	}
+	return nil
+}
+
+// ImportK8sSchema generates CUE definitions for the built-in Kubernetes
+// kinds, from the OpenAPI spec of a release or a local copy of it.
+func ImportK8sSchema(version, spec, dir string) error {
+	var (
+		o   *schema.OpenAPI
+		err error
+	)
+	switch {
+	case spec != "":
+		o, err = schema.LoadOpenAPI(spec)
+		if version == "" {
+			version = spec
+		}
+	case version != "":
+		o, err = schema.FetchKubernetes(version)
+	default:
+		return fmt.Errorf("either --version or --spec is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	out, err := schema.Kubernetes(version, o)
+	if err != nil {
+		return err
+	}
+	return writeSchema(filepath.Join(dir, schema.GenDir, "k8s.io", "api", "schema.cue"), out)
+}
+
+// ImportCRDSchema generates CUE definitions for the CustomResourceDefinitions
+// in a YAML file. Each file is written next to previously imported ones.
+func ImportCRDSchema(file, dir string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	crds, err := schema.ParseCRDs(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	out, err := schema.CRDs(crds)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) + ".cue"
+	return writeSchema(filepath.Join(dir, schema.GenDir, "k8s.io", "crd", name), out)
+}
+
+func writeSchema(target string, out []byte) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, out, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", target)
+	return nil
+}
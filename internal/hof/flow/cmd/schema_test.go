@@ -0,0 +1,54 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/getproviders"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestFindProvider(t *testing.T) {
+	addr := addrs.MustParseProviderSourceString("acme/demo")
+	ver := getproviders.MustParseVersion("1.0.0")
+
+	install := func(dir string) {
+		pkg := filepath.Join(dir, addr.Hostname.String(), addr.Namespace, addr.Type, ver.String(), getproviders.CurrentPlatform.String())
+		require.NoError(t, os.MkdirAll(pkg, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(pkg, "terraform-provider-demo"), nil, 0755))
+	}
+
+	for name, dir := range map[string]string{
+		"plugin cache": mantis.MantisPluginCacheDir,
+		"task":         filepath.Join(mantis.TaskWorkDir("net"), ".terraform", "providers"),
+		"module":       filepath.Join(".terraform", "providers"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("TF_CLI_CONFIG_FILE", os.DevNull)
+			root := t.TempDir()
+			assert.Nil(t, findProvider(addr, ver, root))
+
+			install(filepath.Join(root, dir))
+			cached := findProvider(addr, ver, root)
+			require.NotNil(t, cached)
+			assert.Equal(t, ver, cached.Version)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"cuelang.org/go/cue"
+	"gopkg.in/yaml.v3"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// EncryptSecret encrypts the flat YAML or JSON file in, - for stdin, with
+// the encryption config of the source of a K8sSecret task of the flow at
+// flowPath. It is written to out, the source file of the task by default.
+func EncryptSecret(flowPath, taskID, in, out string, rflags flags.RootPflagpole) error {
+	R, err := prepRuntime([]string{flowPath}, rflags, flags.FlowPflagpole{})
+	if err != nil {
+		return err
+	}
+	var taskVal cue.Value
+	for _, WF := range R.Workflows {
+		v := WF.Root.LookupPath(cue.ParsePath(taskID))
+		if v.Exists() && mantis.IsTask(v, mantis.MantisTaskK8sSecret) {
+			taskVal = v
+			break
+		}
+	}
+	if !taskVal.Exists() {
+		return fmt.Errorf("no K8sSecret task %s found in %s", taskID, flowPath)
+	}
+	source := taskVal.LookupPath(cue.ParsePath(mantis.MantisSecretSource))
+	cfg, err := source.LookupPath(cue.ParsePath("encryption")).MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("the %s.encryption config of task %s must be concrete to encrypt: %v", mantis.MantisSecretSource, taskID, err)
+	}
+	if out == "" {
+		if out, err = source.LookupPath(cue.ParsePath("file")).String(); err != nil {
+			return fmt.Errorf("task %s has no %s.file, set --out: %v", taskID, mantis.MantisSecretSource, err)
+		}
+	}
+
+	var data []byte
+	if in == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(in)
+	}
+	if err != nil {
+		return err
+	}
+	// JSON being YAML as well
+	var decoded map[string]interface{}
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("invalid secrets file %s, expected a flat YAML or JSON object: %w", in, err)
+	}
+	values, err := mantis.SecretValues(decoded)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := mantis.EncryptSecrets(cfg, values)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, encrypted, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d values to %s for task %s. Remove the plaintext file.\n", len(values), out, taskID)
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/terraform-svchost/disco"
+	"github.com/mitchellh/cli"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	backendInit "github.com/opentofu/opentofu/internal/backend/init"
+	"github.com/opentofu/opentofu/internal/command/cliconfig"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/getproviders"
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/flow/tasker"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+	"github.com/opentofu/opentofu/internal/terminal"
+	"github.com/opentofu/opentofu/internal/utils"
+)
+
+// MoveTaskState moves the state of a TF task to a new task ID, after the
+// task was renamed or nested elsewhere in its flow
+func MoveTaskState(dir, from, to string) error {
+	if err := mantis.MoveTaskState(dir, from, to); err != nil {
+		return err
+	}
+	fmt.Printf("Moved state of task %s to %s\n", from, to)
+	return nil
+}
+
+// StateCommand runs a tofu state subcommand (list, show, rm, mv, pull or
+// push) against the state file of a TF task. args are passed on to the
+// subcommand after its -state flag.
+func StateCommand(dir, sub, taskID string, args []string) error {
+	statePath := filepath.Join(dir, mantis.TaskStatePath(taskID))
+	if _, err := os.Stat(statePath); err != nil && sub != "push" {
+		return fmt.Errorf("task %q has no state at %s", taskID, statePath)
+	}
+
+	// the task's own configuration is not needed to work on its state
+	commands, err := taskCommands(dir, taskID, []byte("{}"), false)
+	if err != nil {
+		return err
+	}
+
+	factory, ok := commands["state "+sub]
+	if !ok {
+		return fmt.Errorf("unknown state subcommand %q", sub)
+	}
+	command, err := factory()
+	if err != nil {
+		return err
+	}
+
+	if code := command.Run(append([]string{"-state=" + statePath}, args...)); code != 0 {
+		return fmt.Errorf("state %s of task %s failed", sub, taskID)
+	}
+	return nil
+}
+
+// taskCommands returns the tofu commands of a TF task, run against content
+// as the task's configuration. With quiet, their output goes to stderr.
+func taskCommands(dir, taskID string, content []byte, quiet bool) (map[string]cli.CommandFactory, error) {
+	config, diags := cliconfig.LoadConfig()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to load CLI configuration: %v", diags.Err())
+	}
+	streams, err := terminal.Init()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize terminal: %v", err)
+	}
+	if quiet {
+		streams.Stdout = streams.Stderr
+	}
+	services := disco.NewWithCredentialsSource(nil)
+	backendInit.Init(services)
+
+	configDetails := &configs.MantisConfig{
+		Identifier:       taskID,
+		Content:          content,
+		Format:           "json",
+		BackendStatePath: mantis.TaskBackendStatePath(taskID),
+		WorkingDir:       filepath.Join(dir, mantis.TaskWorkDir(taskID)),
+	}
+	return utils.InitCommandsWrapper(gocontext.Background(), "", streams, config, services,
+		getproviders.NewRegistrySource(services),
+		map[addrs.Provider]getproviders.PackageLocalDir{},
+		map[addrs.Provider]*plugin.ReattachConfig{},
+		configDetails), nil
+}
+
+// StateMoveCommand runs tofu state mv against the state of a TF task,
+// moving the resources into the state of task to when it is set
+func StateMoveCommand(dir, taskID, to string, args []string) error {
+	if to != "" && to != taskID {
+		args = append([]string{"-state-out=" + filepath.Join(dir, mantis.TaskStatePath(to))}, args...)
+	}
+	return StateCommand(dir, "mv", taskID, args)
+}
+
+// AdoptState copies resources of an existing Terraform state into the state
+// of a TF task of the flow at flowPath, encrypted as the task encrypts it.
+// With verify, the flow is then planned to check that the configuration of
+// the task matches the adopted resources.
+func AdoptState(dir string, opts mantis.AdoptOptions, flowPath string, verify bool, rflags flags.RootPflagpole) error {
+	R, err := prepRuntime([]string{flowPath}, rflags, flags.FlowPflagpole{})
+	if err != nil {
+		return err
+	}
+	taskVal, err := lookupTFTask(R, opts.Task, flowPath)
+	if err != nil {
+		return err
+	}
+	opts.TaskConfig = tasker.EncryptionConfig(taskVal)
+
+	adopted, err := mantis.AdoptState(dir, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Adopted %d resource instance(s) into task %s:\n", len(adopted), opts.Task)
+	for _, addr := range adopted {
+		fmt.Printf("  %s\n", addr)
+	}
+	if !verify {
+		return nil
+	}
+
+	fmt.Printf("\nPlanning %s to verify task %s...\n", flowPath, opts.Task)
+	rflags.Plan, rflags.Apply, rflags.Init, rflags.Destroy = true, false, false, false
+
+	var (
+		mu      sync.Mutex
+		planned *task.BaseTask
+	)
+	err = runFlows([]string{flowPath}, rflags, flags.FlowPflagpole{}, func(ctx *flowctx.Context) {
+		if v, ok := ctx.Tasks.Load(opts.Task); ok {
+			mu.Lock()
+			planned = v.(*task.BaseTask)
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("resources were adopted, but planning the flow failed: %w", err)
+	}
+	if planned == nil || planned.Changes == nil {
+		return fmt.Errorf("resources were adopted, but no TF task %s was planned in %s", opts.Task, flowPath)
+	}
+	if *planned.Changes {
+		return fmt.Errorf("resources were adopted, but the plan of task %s has changes. Align its configuration with the adopted resources, using moved blocks for renamed addresses, and plan again", opts.Task)
+	}
+	fmt.Printf("\nThe plan of task %s has no changes, adoption verified.\n", opts.Task)
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestMoveTaskState(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, mantis.TaskStatePath("get_subnets"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(old), 0755))
+	require.NoError(t, os.WriteFile(old, []byte("state"), 0644))
+
+	require.NoError(t, MoveTaskState(dir, "get_subnets", "network.subnets"))
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, filepath.Join(dir, mantis.TaskStatePath("network.subnets")))
+
+	assert.ErrorContains(t, MoveTaskState(dir, "get_subnets", "network.subnets"), "has no state")
+}
+
+// TestAdoptState adopts the state of a Terraform root module, applied
+// outside of mantis, and verifies it against the config of the task
+func TestAdoptState(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+	require.NoError(t, os.WriteFile("flow.tf.cue", []byte(`package flows
+
+f: {
+	@flow(f)
+	app: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: marker: input: "a"
+	}
+	other: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: marker: input: "b"
+	}
+}
+`), 0644))
+
+	dir := "."
+	legacy := filepath.Join(dir, mantis.TaskStatePath("legacy"))
+	require.NoError(t, os.MkdirAll(filepath.Dir(legacy), 0755))
+	_, err = runTaskCommand(dir, "legacy", []byte(`{"resource": {"terraform_data": {"marker": {"input": "a"}}}}`),
+		true, "apply", "-input=false", "-auto-approve", "-state="+legacy)
+	require.NoError(t, err)
+
+	require.NoError(t, AdoptState(dir, mantis.AdoptOptions{Task: "app", From: legacy}, ".", true, flags.RootPflagpole{}))
+	assert.FileExists(t, filepath.Join(dir, mantis.TaskStatePath("app")))
+
+	err = AdoptState(dir, mantis.AdoptOptions{Task: "other", From: legacy}, ".", true, flags.RootPflagpole{})
+	assert.ErrorContains(t, err, "the plan of task other has changes")
+
+	err = AdoptState(dir, mantis.AdoptOptions{Task: "missing", From: legacy}, ".", false, flags.RootPflagpole{})
+	assert.ErrorContains(t, err, "no TF task missing found")
+}
@@ -0,0 +1,75 @@
+package main
+
+f: {
+	@flow(f)
+
+	net: {
+		@task(mantis.core.TF)
+		config: resource: terraform_data: vpc: input: "10.0.0.0/16"
+		exports: [{var: "ip", jqpath: ".ip"}]
+	}
+
+	svc: {
+		@task(mantis.core.K8s)
+		config: {
+			apiVersion: "v1"
+			kind:       "Service"
+			metadata: name: "web"
+			spec: {
+				type: "LoadBalancer"
+				ports: [{port: 80}]
+			}
+		}
+	}
+
+	apps: web: {
+		@task(mantis.core.K8s)
+		config: {
+			apiVersion: "v1"
+			kind:       "ConfigMap"
+			metadata: {
+				name:        "web"
+				annotations: db.out.annotations
+			}
+			data: {
+				ip:       string @var(net.ip)
+				region:   string @var(region)
+				hostname: svc.out.service.status.loadBalancer.ingress[0].hostname
+			}
+		}
+	}
+
+	db: {
+		@task(mantis.core.K8sSecret)
+		config: metadata: name: "db"
+		source: {
+			file: "db.enc"
+			encryption: {
+				key_provider: pbkdf2: k: passphrase: "correct horse battery staple"
+				method: aes_gcm: m: keys: "${key_provider.pbkdf2.k}"
+				state: method: "${method.aes_gcm.m}"
+			}
+		}
+	}
+
+	ns: {
+		@task(mantis.core.K8s)
+		for_each: ["dev", "prod"]
+		each: _
+		config: {
+			apiVersion: "v1"
+			kind:       "Namespace"
+			metadata: name: each.value
+		}
+	}
+
+	off: {
+		@task(mantis.core.K8s)
+		when: false
+		config: {
+			apiVersion: "v1"
+			kind:       "Namespace"
+			metadata: name: "off"
+		}
+	}
+}
@@ -20,6 +20,7 @@ package context
 import (
 	gocontext "context"
 	"io"
+	"strings"
 	"sync"
 
 	"cuelang.org/go/cue"
@@ -56,6 +57,9 @@ type Context struct {
 	// BOOKKEEPING
 	Tasks *sync.Map
 
+	// tasks currently running, waited on when the flow is interrupted
+	Running *sync.WaitGroup
+
 	// experimental
 	BaseTask *task.BaseTask
 
@@ -78,8 +82,30 @@ type Context struct {
 	// CUE context
 	CueContext *cue.Context
 
-	// output vars
-	GlobalVars *sync.Map
+	// name and CUE path of the flow being run,
+	// used to namespace exported vars
+	FlowName string
+	FlowPath string
+
+	// directory of the CUE package declaring the flow, files of the
+	// flow such as the image lock file are kept next to it
+	FlowDir string
+
+	// output vars, scoped by flow and task
+	Vars *VarScope
+
+	// values in the out of the task which a plan could
+	// only determine after apply, by their dotted path
+	Unknown map[string]UnknownValue
+
+	// values in the out of the task which are sensitive, such as
+	// passwords, by their dotted path. They are not journaled in clear.
+	Sensitive []string
+
+	// progress of the run, and whether tasks completed by
+	// a previous run with unchanged inputs are skipped
+	Journal *Journal
+	Resume  bool
 	// store flow errors and warnings
 	FlowErrors   []string
 	FlowWarnings []string
@@ -95,9 +121,10 @@ func New() *Context {
 		Middlewares:  make([]Middleware, 0),
 		TaskRegistry: new(sync.Map),
 		Tasks:        new(sync.Map),
+		Running:      new(sync.WaitGroup),
 		Pools:        new(sync.Map),
 		CueContext:   nil,
-		GlobalVars:   new(sync.Map),
+		Vars:         NewVarScope(),
 		FlowErrors:   []string{},
 		FlowWarnings: []string{},
 	}
@@ -122,6 +149,7 @@ func Copy(ctx *Context) *Context {
 		Middlewares:  ctx.Middlewares,
 		TaskRegistry: ctx.TaskRegistry,
 		Tasks:        ctx.Tasks,
+		Running:      ctx.Running,
 		Pools:        ctx.Pools,
 		Apply:        ctx.Apply,
 		Plan:         ctx.Plan,
@@ -129,7 +157,12 @@ func Copy(ctx *Context) *Context {
 		Init:         ctx.Init,
 		Destroy:      ctx.Destroy,
 		CueContext:   ctx.CueContext,
-		GlobalVars:   ctx.GlobalVars,
+		FlowName:     ctx.FlowName,
+		FlowPath:     ctx.FlowPath,
+		FlowDir:      ctx.FlowDir,
+		Vars:         ctx.Vars,
+		Journal:      ctx.Journal,
+		Resume:       ctx.Resume,
 	}
 }
 
@@ -137,6 +170,14 @@ func (C *Context) Use(m Middleware) {
 	C.Middlewares = append(C.Middlewares, m)
 }
 
+// TaskName returns the id of a task relative to the flow being run.
+func (C *Context) TaskName(taskId string) string {
+	if C.FlowPath == "" {
+		return taskId
+	}
+	return strings.TrimPrefix(taskId, C.FlowPath+".")
+}
+
 // Register registers a task for cue commands.
 func (C *Context) Register(key string, f RunnerFunc) {
 	C.TaskRegistry.Store(key, f)
@@ -180,6 +221,29 @@ type Runner interface {
 // TFContext is a type that includes ParsedVariables which is a map of string keys to another map of string keys and cty.Value values.
 type TFContext struct {
 	ParsedVariables *sync.Map
+
+	// root module variables which are planned with an unknown value,
+	// standing in for values of other tasks only known after apply
+	Unknown []string
+
+	// dotted paths within ParsedVariables of the values which are
+	// sensitive, by their provider schema or their config
+	sensitiveMu sync.Mutex
+	sensitive   []string
+}
+
+// AddSensitive records the dotted path of a sensitive value
+func (T *TFContext) AddSensitive(path string) {
+	T.sensitiveMu.Lock()
+	defer T.sensitiveMu.Unlock()
+	T.sensitive = append(T.sensitive, path)
+}
+
+// Sensitive returns the dotted paths of the sensitive values
+func (T *TFContext) Sensitive() []string {
+	T.sensitiveMu.Lock()
+	defer T.sensitiveMu.Unlock()
+	return append([]string(nil), T.sensitive...)
 }
 
 // NewTFContext is a constructor for TFContext.
@@ -0,0 +1,207 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+)
+
+// Journal records the progress of a flow applied with --apply,
+// so that a failed or interrupted run can be resumed with --resume.
+type Journal struct {
+	mu   sync.Mutex
+	path string
+
+	Flow    string                   `json:"flow"`
+	Started time.Time                `json:"started"`
+	Tasks   map[string]*JournalEntry `json:"tasks"`
+}
+
+// JournalEntry is the outcome of a single task
+type JournalEntry struct {
+	Status task.Status `json:"status"`
+
+	// Type of the task, such as mantis.core.TF
+	Type string `json:"type,omitempty"`
+
+	// hash of the task value after vars were injected,
+	// a task is only skipped when its inputs are unchanged
+	InputHash string `json:"input_hash"`
+
+	// Out of the task, which the tasks referring to it are resumed and
+	// rendered with. The out of TF tasks holds every attribute of their
+	// resources, it is only recorded sealed.
+	Out json.RawMessage `json:"out,omitempty"`
+
+	// Exports of the task, without the values of sensitive exports
+	Exports []Export `json:"exports,omitempty"`
+
+	// Sealed holds the out of a TF task and the values of its sensitive
+	// exports, encrypted like the state of the task. Tasks which do not
+	// encrypt their state do not record them.
+	Sealed []byte `json:"sealed,omitempty"`
+
+	Error   string    `json:"error,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Export is a var stored by a task
+type Export struct {
+	Name   string      `json:"name"`
+	Value  interface{} `json:"value"`
+	Public bool        `json:"public,omitempty"`
+
+	// Sensitive tells that the value derives from a sensitive value,
+	// such as a password, it is only recorded sealed
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+// sealedEntry is the content of JournalEntry.Sealed
+type sealedEntry struct {
+	Out    json.RawMessage        `json:"out,omitempty"`
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+// Seal records out and the values of the sensitive exports of the entry in
+// Sealed, encrypted by encrypt, and clears them from the entry. When encrypt
+// returns nothing, they are not recorded.
+func (E *JournalEntry) Seal(out json.RawMessage, encrypt func([]byte) ([]byte, error)) error {
+	payload := sealedEntry{Out: out, Values: map[string]interface{}{}}
+	exports := make([]Export, len(E.Exports))
+	for i, e := range E.Exports {
+		if e.Sensitive {
+			payload.Values[e.Name] = e.Value
+			e.Value = nil
+		}
+		exports[i] = e
+	}
+	E.Exports, E.Out, E.Sealed = exports, nil, nil
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	E.Sealed, err = encrypt(data)
+	return err
+}
+
+// Unseal returns a copy of the entry with the out and the values of the
+// sensitive exports recorded by Seal, decrypted by decrypt
+func (E *JournalEntry) Unseal(decrypt func([]byte) ([]byte, error)) (*JournalEntry, error) {
+	data, err := decrypt(E.Sealed)
+	if err != nil {
+		return nil, err
+	}
+	var payload sealedEntry
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("invalid sealed entry: %w", err)
+	}
+
+	entry := *E
+	entry.Out = payload.Out
+	entry.Exports = make([]Export, len(E.Exports))
+	for i, e := range E.Exports {
+		if e.Sensitive {
+			e.Value = payload.Values[e.Name]
+		}
+		entry.Exports[i] = e
+	}
+	entry.Sealed = nil
+	return &entry, nil
+}
+
+// NewJournal starts the journal of a new run. The entries of previous runs
+// are carried forward, so that mantis output, drift and render still see
+// the tasks which do not run again; the tasks which do replace their entry.
+func NewJournal(path, flow string) (*Journal, error) {
+	J, err := LoadJournal(path)
+	if err != nil {
+		if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+			return nil, err
+		}
+		J = &Journal{path: path, Tasks: make(map[string]*JournalEntry)}
+	}
+	J.Flow = flow
+	J.Started = time.Now()
+
+	J.mu.Lock()
+	defer J.mu.Unlock()
+	return J, J.save()
+}
+
+// LoadJournal reads the journal of a previous run
+func LoadJournal(path string) (*Journal, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no run journal at %s to resume from", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	J := &Journal{path: path}
+	if err := json.Unmarshal(data, J); err != nil {
+		return nil, fmt.Errorf("invalid run journal %s: %w", path, err)
+	}
+	if J.Tasks == nil {
+		J.Tasks = make(map[string]*JournalEntry)
+	}
+	return J, nil
+}
+
+// Completed returns the entry of a task which completed with the same inputs
+func (J *Journal) Completed(taskId, inputHash string) *JournalEntry {
+	J.mu.Lock()
+	defer J.mu.Unlock()
+
+	entry, ok := J.Tasks[taskId]
+	if !ok || entry.Status != task.StatusCompleted || entry.InputHash != inputHash {
+		return nil
+	}
+	return entry
+}
+
+// Record stores the outcome of a task and writes the journal
+func (J *Journal) Record(taskId string, entry *JournalEntry) error {
+	J.mu.Lock()
+	defer J.mu.Unlock()
+
+	entry.Updated = time.Now()
+	J.Tasks[taskId] = entry
+	return J.save()
+}
+
+// save writes the journal through a temporary file,
+// so an interrupted write never leaves a truncated journal
+func (J *Journal) save() error {
+	data, err := json.MarshalIndent(J, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(J.path), 0755); err != nil {
+		return err
+	}
+	tmp := J.path + ".tmp"
+	// out and exports may hold values which are not public
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, J.path)
+}
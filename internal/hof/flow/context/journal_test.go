@@ -0,0 +1,103 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+)
+
+func TestJournalCarriesEntriesForward(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mantis_state", "journal_f.json")
+
+	J, err := NewJournal(path, "f")
+	require.NoError(t, err)
+	require.NoError(t, J.Record("net", &JournalEntry{Status: task.StatusCompleted, InputHash: "a", Out: []byte(`{"id":"vpc-1"}`)}))
+	require.NoError(t, J.Record("db", &JournalEntry{Status: task.StatusCompleted, InputHash: "b"}))
+
+	// the next run only runs db
+	J, err = NewJournal(path, "f")
+	require.NoError(t, err)
+	require.NoError(t, J.Record("db", &JournalEntry{Status: task.StatusFailed, InputHash: "c", Error: "boom"}))
+
+	J, err = LoadJournal(path)
+	require.NoError(t, err)
+	require.Contains(t, J.Tasks, "net")
+	assert.JSONEq(t, `{"id":"vpc-1"}`, string(J.Tasks["net"].Out))
+	assert.Equal(t, task.StatusFailed, J.Tasks["db"].Status)
+
+	assert.NotNil(t, J.Completed("net", "a"))
+	assert.Nil(t, J.Completed("net", "changed"))
+	assert.Nil(t, J.Completed("db", "c"), "failed tasks are not completed")
+	assert.Nil(t, J.Completed("missing", "a"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the journal may hold values which are not public")
+}
+
+func TestJournalEntrySeal(t *testing.T) {
+	exports := []Export{
+		{Name: "host", Value: "db.internal"},
+		{Name: "password", Value: "hunter2", Sensitive: true},
+	}
+	// reverses the bytes, standing in for an encryption
+	reverse := func(data []byte) ([]byte, error) {
+		out := make([]byte, len(data))
+		for i, b := range data {
+			out[len(data)-1-i] = b
+		}
+		return out, nil
+	}
+
+	entry := &JournalEntry{Status: task.StatusCompleted, Exports: append([]Export(nil), exports...)}
+	require.NoError(t, entry.Seal([]byte(`{"password":"hunter2"}`), reverse))
+	assert.Empty(t, entry.Out)
+	assert.NotEmpty(t, entry.Sealed)
+	assert.NotContains(t, string(entry.Sealed), `"hunter2"`)
+	assert.Equal(t, []Export{
+		{Name: "host", Value: "db.internal"},
+		{Name: "password", Sensitive: true},
+	}, entry.Exports)
+
+	unsealed, err := entry.Unseal(reverse)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"hunter2"}`, string(unsealed.Out))
+	assert.Equal(t, exports, unsealed.Exports)
+	assert.NotEmpty(t, entry.Sealed, "the entry itself stays sealed")
+
+	// without an encryption, out and sensitive values are not recorded
+	entry = &JournalEntry{Status: task.StatusCompleted, Exports: append([]Export(nil), exports...)}
+	require.NoError(t, entry.Seal([]byte(`{"password":"hunter2"}`), func([]byte) ([]byte, error) { return nil, nil }))
+	assert.Empty(t, entry.Out)
+	assert.Empty(t, entry.Sealed)
+	assert.Nil(t, entry.Exports[1].Value)
+	assert.Equal(t, "hunter2", exports[1].Value, "the exports given are not modified")
+}
+
+func TestJournalInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal_f.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+
+	_, err := NewJournal(path, "f")
+	assert.ErrorContains(t, err, "invalid run journal")
+
+	_, err = LoadJournal(filepath.Join(t.TempDir(), "none.json"))
+	assert.ErrorContains(t, err, "no run journal")
+}
@@ -0,0 +1,82 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SensitiveValue replaces a sensitive value wherever it is shown
+const SensitiveValue = "(sensitive)"
+
+// sensitiveToken prefixes the masked form of a sensitive value, random per
+// run like unknownToken
+var sensitiveToken = randomToken("mantis-sensitive-")
+
+// MaskSensitive returns a copy of v where the values at the given dotted
+// paths are replaced with strings, so that the results of a jq query of v
+// which derive from them can be told by IsSensitive.
+func MaskSensitive(v interface{}, paths []string) interface{} {
+	if len(paths) == 0 {
+		return v
+	}
+	set := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		set[p] = true
+	}
+	return maskSensitive(v, "", set)
+}
+
+func maskSensitive(v interface{}, path string, paths map[string]bool) interface{} {
+	if paths[path] && path != "" {
+		return sensitiveToken
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = maskSensitive(e, joinPath(path, k), paths)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, e := range x {
+			l[i] = maskSensitive(e, joinPath(path, strconv.Itoa(i)), paths)
+		}
+		return l
+	}
+	return v
+}
+
+// IsSensitive reports whether v, queried from the result of MaskSensitive,
+// holds any sensitive value
+func IsSensitive(v interface{}) bool {
+	switch x := v.(type) {
+	case string:
+		return strings.Contains(x, sensitiveToken)
+	case map[string]interface{}:
+		for k, e := range x {
+			if strings.Contains(k, sensitiveToken) || IsSensitive(e) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, e := range x {
+			if IsSensitive(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
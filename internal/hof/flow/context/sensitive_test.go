@@ -0,0 +1,41 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskSensitive(t *testing.T) {
+	out := map[string]interface{}{
+		"aws_db_instance": map[string]interface{}{
+			"main": map[string]interface{}{
+				"address":  "db.internal",
+				"password": "hunter2",
+				"users":    []interface{}{"app", "admin"},
+			},
+		},
+	}
+	masked := MaskSensitive(out, []string{"aws_db_instance.main.password", "aws_db_instance.main.users.1"})
+
+	db := masked.(map[string]interface{})["aws_db_instance"].(map[string]interface{})["main"].(map[string]interface{})
+	assert.False(t, IsSensitive(db["address"]))
+	assert.True(t, IsSensitive(db["password"]))
+	assert.True(t, IsSensitive(db["users"]), "a list holding a sensitive value")
+	assert.True(t, IsSensitive(db))
+	assert.Equal(t, "hunter2", out["aws_db_instance"].(map[string]interface{})["main"].(map[string]interface{})["password"], "out itself is not masked")
+
+	assert.Equal(t, out, MaskSensitive(out, nil))
+}
@@ -0,0 +1,141 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnknownValue is a placeholder for a value which tofu can only
+// determine after apply. It is produced during plan, carried through
+// task outputs and exports, and shown as "(known after apply: <type>)".
+type UnknownValue struct {
+	// Type is the tofu type constraint of the value, e.g. "string" or "list(string)"
+	Type string
+}
+
+func NewUnknownValue(ty cty.Type) UnknownValue {
+	return UnknownValue{Type: typeexpr.TypeString(ty)}
+}
+
+func (U UnknownValue) String() string {
+	return fmt.Sprintf("(known after apply: %s)", U.Type)
+}
+
+func (U UnknownValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(U.String())
+}
+
+// unknownToken prefixes the masked form of an UnknownValue. It is random
+// per run, so that no string of the user can be taken for an unknown value.
+var unknownToken = randomToken("mantis-unknown-")
+
+func randomToken(prefix string) string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b) + ":"
+}
+
+// UnknownPaths collects the unknown values within v by their
+// dotted path, e.g. "aws_instance.web.id" or "subnets.0".
+func UnknownPaths(v interface{}) map[string]UnknownValue {
+	paths := map[string]UnknownValue{}
+	walkUnknowns(v, "", paths)
+	return paths
+}
+
+func walkUnknowns(v interface{}, path string, paths map[string]UnknownValue) {
+	switch x := v.(type) {
+	case UnknownValue:
+		paths[path] = x
+	case map[string]interface{}:
+		for k, e := range x {
+			walkUnknowns(e, joinPath(path, k), paths)
+		}
+	case []interface{}:
+		for i, e := range x {
+			walkUnknowns(e, joinPath(path, strconv.Itoa(i)), paths)
+		}
+	}
+}
+
+// MaskUnknowns returns a copy of v where the values at the given paths are
+// replaced with strings, so that v can be queried with jq. UnmaskUnknowns
+// turns the query results back into UnknownValues.
+func MaskUnknowns(v interface{}, paths map[string]UnknownValue) interface{} {
+	if len(paths) == 0 {
+		return v
+	}
+	return maskUnknowns(v, "", paths)
+}
+
+func maskUnknowns(v interface{}, path string, paths map[string]UnknownValue) interface{} {
+	if u, ok := paths[path]; ok && path != "" {
+		return unknownToken + u.Type
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = maskUnknowns(e, joinPath(path, k), paths)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, e := range x {
+			l[i] = maskUnknowns(e, joinPath(path, strconv.Itoa(i)), paths)
+		}
+		return l
+	}
+	return v
+}
+
+// UnmaskUnknowns reverses MaskUnknowns.
+func UnmaskUnknowns(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		if typ, ok := strings.CutPrefix(x, unknownToken); ok {
+			return UnknownValue{Type: typ}
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = UnmaskUnknowns(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, e := range x {
+			l[i] = UnmaskUnknowns(e)
+		}
+		return l
+	}
+	return v
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,249 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// VarScope holds the values exported by tasks, namespaced by flow and task.
+//
+// A reference passed to Lookup is resolved in this order:
+//
+//	var            a var exported by any task of the current flow,
+//	               then a public re-export from any flow
+//	flow.task.var  a var exported by a task of another (or the same) flow
+//	task.var       a var exported by a task of the current flow
+//
+// Any remaining path elements index into the resolved value. Indexing into
+// a bare var comes first, so that @var(x.y) keeps reading field y of var x
+// when a task happens to be named x.
+type VarScope struct {
+	mu sync.RWMutex
+
+	// flow -> task -> var -> value
+	vars map[string]map[string]map[string]interface{}
+
+	// flow -> var -> task that exported it most recently
+	latest map[string]map[string]string
+
+	// public re-exports, addressable by bare name from every flow
+	public map[string]interface{}
+
+	// an isolated scope can still read the public exports of its parent
+	parent *VarScope
+}
+
+func NewVarScope() *VarScope {
+	return &VarScope{
+		vars:   make(map[string]map[string]map[string]interface{}),
+		latest: make(map[string]map[string]string),
+		public: make(map[string]interface{}),
+	}
+}
+
+// Isolate returns an empty scope whose writes are invisible to S.
+// Public exports of S remain readable. Used for bulk-mode instances.
+func (S *VarScope) Isolate() *VarScope {
+	iso := NewVarScope()
+	iso.parent = S
+	return iso
+}
+
+// Store records a var exported by a task. It returns the other task in the
+// same flow which previously exported the same name, if any.
+func (S *VarScope) Store(flow, task, name string, value interface{}, public bool) (shadowed string) {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+
+	tasks, ok := S.vars[flow]
+	if !ok {
+		tasks = make(map[string]map[string]interface{})
+		S.vars[flow] = tasks
+	}
+	vars, ok := tasks[task]
+	if !ok {
+		vars = make(map[string]interface{})
+		tasks[task] = vars
+	}
+	vars[name] = value
+
+	latest, ok := S.latest[flow]
+	if !ok {
+		latest = make(map[string]string)
+		S.latest[flow] = latest
+	}
+	if prev, ok := latest[name]; ok && prev != task {
+		shadowed = prev
+	}
+	latest[name] = task
+
+	if public {
+		S.public[name] = value
+	}
+
+	return shadowed
+}
+
+// Lookup resolves a @var reference from within the given flow.
+func (S *VarScope) Lookup(flow, ref string) (interface{}, bool) {
+	S.mu.RLock()
+	defer S.mu.RUnlock()
+
+	parts := strings.Split(ref, ".")
+	tasks := S.vars[flow]
+
+	// var
+	if task, ok := S.latest[flow][parts[0]]; ok {
+		if val, ok := descend(tasks[task][parts[0]], parts[1:]); ok {
+			return val, true
+		}
+	}
+	if val, ok := S.lookupPublic(parts); ok {
+		return val, true
+	}
+
+	// flow.task.var
+	if tasks, ok := S.vars[parts[0]]; ok && len(parts) > 2 {
+		if val, ok := lookupTask(tasks, parts[1:]); ok {
+			return val, true
+		}
+	}
+
+	// task.var
+	if len(parts) > 1 {
+		if val, ok := lookupTask(tasks, parts); ok {
+			return val, true
+		}
+	}
+
+	return nil, false
+}
+
+func (S *VarScope) lookupPublic(parts []string) (interface{}, bool) {
+	if val, ok := S.public[parts[0]]; ok {
+		return descend(val, parts[1:])
+	}
+	if S.parent != nil {
+		S.parent.mu.RLock()
+		defer S.parent.mu.RUnlock()
+		return S.parent.lookupPublic(parts)
+	}
+	return nil, false
+}
+
+// lookupTask matches the longest task id which prefixes parts,
+// so tasks nested under sub-structs (a.b) remain addressable.
+func lookupTask(tasks map[string]map[string]interface{}, parts []string) (interface{}, bool) {
+	for i := len(parts) - 1; i > 0; i-- {
+		vars, ok := tasks[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+		if val, ok := vars[parts[i]]; ok {
+			return descend(val, parts[i+1:])
+		}
+	}
+	return nil, false
+}
+
+func descend(val interface{}, parts []string) (interface{}, bool) {
+	for _, part := range parts {
+		switch curr := val.(type) {
+		case map[string]interface{}:
+			next, ok := curr[part]
+			if !ok {
+				return nil, false
+			}
+			val = next
+		case map[string]string:
+			next, ok := curr[part]
+			if !ok {
+				return nil, false
+			}
+			val = next
+		case *sync.Map:
+			next, ok := curr.Load(part)
+			if !ok {
+				return nil, false
+			}
+			val = next
+		default:
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// Names lists every reference visible from the given flow, sorted.
+func (S *VarScope) Names(flow string) []string {
+	S.mu.RLock()
+	defer S.mu.RUnlock()
+
+	seen := map[string]bool{}
+	for f, tasks := range S.vars {
+		for t, vars := range tasks {
+			for v := range vars {
+				if f == flow {
+					seen[t+"."+v] = true
+				} else {
+					seen[f+"."+t+"."+v] = true
+				}
+			}
+		}
+	}
+	for v := range S.latest[flow] {
+		seen[v] = true
+	}
+	for v := range S.public {
+		seen[v] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Flatten returns the bare names visible from the given flow with their values.
+func (S *VarScope) Flatten(flow string) map[string]interface{} {
+	out := S.publicVars()
+
+	S.mu.RLock()
+	defer S.mu.RUnlock()
+
+	for v, task := range S.latest[flow] {
+		out[v] = S.vars[flow][task][v]
+	}
+	return out
+}
+
+func (S *VarScope) publicVars() map[string]interface{} {
+	out := make(map[string]interface{})
+	if S.parent != nil {
+		out = S.parent.publicVars()
+	}
+
+	S.mu.RLock()
+	defer S.mu.RUnlock()
+
+	for v, val := range S.public {
+		out[v] = val
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarScopeLookup(t *testing.T) {
+	S := NewVarScope()
+	S.Store("infra", "net", "vpc", "vpc-123", false)
+	S.Store("infra", "net", "subnets", map[string]interface{}{"a": "subnet-a"}, false)
+	S.Store("infra", "db", "host", "db.internal", true)
+	S.Store("app", "web", "port", 8080, false)
+	// a var named like a task of the same flow
+	S.Store("app", "cfg", "web", map[string]interface{}{"port": 9090}, false)
+
+	cases := []struct {
+		flow, ref string
+		want      interface{}
+		found     bool
+	}{
+		{"app", "infra.net.vpc", "vpc-123", true},
+		{"app", "infra.net.subnets.a", "subnet-a", true},
+		{"infra", "net.vpc", "vpc-123", true},
+		{"infra", "vpc", "vpc-123", true},
+		{"infra", "subnets.a", "subnet-a", true},
+		{"app", "host", "db.internal", true},
+		{"app", "vpc", nil, false},
+		{"app", "web.port", 9090, true},
+		{"app", "port", 8080, true},
+		{"infra", "net.missing", nil, false},
+	}
+	for _, c := range cases {
+		got, ok := S.Lookup(c.flow, c.ref)
+		assert.Equal(t, c.found, ok, "%s: %s", c.flow, c.ref)
+		assert.Equal(t, c.want, got, "%s: %s", c.flow, c.ref)
+	}
+}
+
+func TestVarScopeShadowed(t *testing.T) {
+	S := NewVarScope()
+	assert.Equal(t, "", S.Store("f", "a", "x", 1, false))
+	assert.Equal(t, "a", S.Store("f", "b", "x", 2, false))
+
+	val, _ := S.Lookup("f", "x")
+	assert.Equal(t, 2, val)
+	val, _ = S.Lookup("f", "a.x")
+	assert.Equal(t, 1, val)
+}
+
+func TestVarScopeIsolate(t *testing.T) {
+	S := NewVarScope()
+	S.Store("f", "a", "shared", "yes", true)
+	S.Store("f", "a", "private", "no", false)
+
+	iso := S.Isolate()
+	iso.Store("f", "b", "local", "iso", true)
+
+	val, ok := iso.Lookup("f", "shared")
+	assert.True(t, ok)
+	assert.Equal(t, "yes", val)
+
+	_, ok = iso.Lookup("f", "private")
+	assert.False(t, ok)
+
+	_, ok = S.Lookup("f", "local")
+	assert.False(t, ok)
+	assert.Equal(t, map[string]interface{}{"shared": "yes", "local": "iso"}, iso.Flatten("f"))
+}
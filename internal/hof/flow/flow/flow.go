@@ -132,6 +132,12 @@ func (P *Flow) run() error {
 		cfg.Root = P.Orig.Path()
 	}
 
+	// add the instances of tasks with for_each before building the task graph
+	root, err := tasker.ExpandForEach(root)
+	if err != nil {
+		return err
+	}
+
 	// copy orig for good measure
 	// This is helpful for when
 	v := P.Orig.Context().CompileString("{...}")
@@ -146,7 +152,7 @@ func (P *Flow) run() error {
 	}
 
 	// fmt.Println("Flow.run() start")
-	err := P.Ctrl.Run(P.FlowCtx.GoContext)
+	err = P.Ctrl.Run(P.FlowCtx.GoContext)
 
 	//print error from ctx.FlowErrors and ctx.FlowWarnings
 	if len(P.FlowCtx.FlowErrors) > 0 || len(P.FlowCtx.FlowWarnings) > 0 {
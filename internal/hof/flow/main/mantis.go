@@ -22,6 +22,7 @@ import (
 
 	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
 	runner "github.com/opentofu/opentofu/internal/hof/flow/cmd"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
 	"github.com/spf13/cobra"
 )
 
@@ -213,6 +214,355 @@ var indexCmd = &cobra.Command{
 	},
 }
 
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Manage generated CUE schemas",
+}
+
+var schemaImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Generate CUE schemas from external definitions",
+}
+
+var schemaImportProviderCmd = &cobra.Command{
+	Use:   "provider <source> <version>",
+	Short: "Generate CUE definitions for a provider's resources",
+	Long: `Generate closed CUE definitions for every resource and data source of an
+installed provider, written to cue.mod/gen/<source>/schema.cue.
+
+Example: mantis schema import provider hashicorp/aws 5.31.0 -C ./infra`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runner.ImportProviderSchema(args[0], args[1], rootDir()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing provider schema: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var schemaImportK8sCmd = &cobra.Command{
+	Use:   "k8s",
+	Short: "Generate CUE definitions for the built-in Kubernetes kinds",
+	Long: `Generate closed CUE definitions from the Kubernetes OpenAPI v3 spec, written
+to cue.mod/gen/k8s.io/api. mantis validate checks K8s task configs against
+them by apiVersion and kind.
+
+Example: mantis schema import k8s --version 1.30 -C ./infra`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, _ := cmd.Flags().GetString("version")
+		spec, _ := cmd.Flags().GetString("spec")
+		if err := runner.ImportK8sSchema(version, spec, rootDir()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing Kubernetes schema: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var schemaImportCRDCmd = &cobra.Command{
+	Use:   "crd <file>",
+	Short: "Generate CUE definitions for CustomResourceDefinitions",
+	Long: `Generate closed CUE definitions from the openAPIV3Schema of every version of
+the CRDs in a YAML file, written to cue.mod/gen/k8s.io/crd.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runner.ImportCRDSchema(args[0], rootDir()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing CRD schema: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// rootDir returns the absolute path of the code directory
+func rootDir() string {
+	codeDir := rflags.CodeDir
+	if codeDir == "" {
+		codeDir = "."
+	}
+	absDir, err := filepath.Abs(codeDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving directory path: %v\n", err)
+		os.Exit(1)
+	}
+	return absDir
+}
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "Manage the providers used by TF tasks",
+}
+
+var providersLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Pin provider versions across all tasks",
+	Long: `Select one version of each provider used by the TF tasks, the newest locked by
+any task which satisfies the constraints of every task. The selection is
+written to each task's lock file and to .mantis/providers.lock.hcl, which
+seeds the lock file of tasks initialized later.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runner.LockProviders(rootDir()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error locking providers: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage the state of TF tasks",
+}
+
+var stateMvTaskCmd = &cobra.Command{
+	Use:   "mv-task <old> <new>",
+	Short: "Move the state of a task to a new task ID",
+	Long: `Move the state file of a TF task, with its backup and working directory,
+from the task ID it had to the one it has after being renamed or nested
+elsewhere in its flow. Task IDs are the paths printed by mantis run.
+
+Declaring moved: {from: "<old>"} on the task does the same on the next apply.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runner.MoveTaskState(rootDir(), args[0], args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error moving state: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// stateTaskCmd runs a tofu state subcommand against the state of the task
+// selected with --task. Flags of the tofu subcommand go after --, which
+// tofu reads before the addresses: mantis state rm --task net -- -dry-run addr
+func stateTaskCmd(sub, use, short string, args cobra.PositionalArgs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  args,
+		Run: func(cmd *cobra.Command, args []string) {
+			task, _ := cmd.Flags().GetString("task")
+			var err error
+			if sub == "mv" {
+				to, _ := cmd.Flags().GetString("to-task")
+				err = runner.StateMoveCommand(rootDir(), task, to, args)
+			} else {
+				err = runner.StateCommand(rootDir(), sub, task, args)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().String("task", "", "ID of the TF task, as printed by mantis run")
+	cmd.MarkFlagRequired("task")
+	return cmd
+}
+
+var (
+	stateListCmd = stateTaskCmd("list", "list --task <id> [address...]", "List the resources in the state of a task", cobra.ArbitraryArgs)
+	stateShowCmd = stateTaskCmd("show", "show --task <id> <address>", "Show a resource in the state of a task", cobra.MinimumNArgs(1))
+	stateRmCmd   = stateTaskCmd("rm", "rm --task <id> <address...>", "Remove resources from the state of a task", cobra.MinimumNArgs(1))
+	stateMvCmd   = stateTaskCmd("mv", "mv --task <id> [--to-task <id>] <source> <destination>", "Move a resource within the state of a task, or into another task", cobra.MinimumNArgs(2))
+	statePullCmd = stateTaskCmd("pull", "pull --task <id>", "Print the state of a task", cobra.ArbitraryArgs)
+	statePushCmd = stateTaskCmd("push", "push --task <id> <path>", "Overwrite the state of a task with a local state file", cobra.MinimumNArgs(1))
+)
+
+var stateAdoptCmd = &cobra.Command{
+	Use:   "adopt [path] --task <id> --from <statefile|dir>",
+	Short: "Adopt resources of an existing Terraform state into a task",
+	Long: `Copy resources of an existing Terraform state into the state of a TF task,
+leaving the source untouched. --from is a state file, - to read one from stdin,
+or the directory of a Terraform root module whose configured backend holds the
+state. Repeat --address to adopt only the resources it contains, and split one
+root module across several tasks.
+
+The task is looked up in the flow in path, the current directory by default,
+and the adopted resources are encrypted as its state is. The flow is then
+planned to verify that the task's configuration matches the adopted resources.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		flowPath := "."
+		if len(args) > 0 {
+			flowPath = args[0]
+		}
+		var opts mantis.AdoptOptions
+		opts.Task, _ = cmd.Flags().GetString("task")
+		opts.From, _ = cmd.Flags().GetString("from")
+		opts.Workspace, _ = cmd.Flags().GetString("workspace")
+		opts.Addresses, _ = cmd.Flags().GetStringArray("address")
+		noVerify, _ := cmd.Flags().GetBool("no-verify")
+
+		if err := runner.AdoptState(rootDir(), opts, flowPath, !noVerify, rflags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Bring existing resources under the management of tasks",
+}
+
+var importResourceCmd = &cobra.Command{
+	Use:   "resource [path] --task <id> <address> <id>",
+	Short: "Import an existing resource into a TF task and generate its CUE config",
+	Long: `Import the resource with the given provider id into the state of a TF task, at
+an address of the task's root module such as aws_s3_bucket.logs. Its
+configuration is generated by tofu from the imported state and written as CUE
+to imported.tf.cue next to the flow, or to --out, where it unifies with the
+config of the task. The task must have been initialized with mantis run -I.
+
+The flow is loaded from path, the current directory by default.`,
+	Args: cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		flowPath := "."
+		if len(args) == 3 {
+			flowPath, args = args[0], args[1:]
+		}
+		task, _ := cmd.Flags().GetString("task")
+		out, _ := cmd.Flags().GetString("out")
+		if err := runner.ImportResource(rootDir(), flowPath, task, args[0], args[1], out, rflags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var importK8sCmd = &cobra.Command{
+	Use:   "k8s --namespace <ns> [--selector <labels>]",
+	Short: "Adopt live Kubernetes objects as CUE and a flow of K8s tasks",
+	Long: `Read the objects of a namespace matching a label selector and write them as
+CUE definitions, with a flow of K8s tasks applying them, to k8s_<ns>.tf.cue or
+--out. Fields populated by the cluster, such as status, managedFields, allocated
+addresses and defaults, are left out, so that the flow plans without changes.
+
+Objects owned by other objects, such as the pods of a Deployment, and those
+the cluster creates in every namespace are not imported. Secrets are left out
+and listed, since their data would be written in plain text, unless --secrets
+is set; manage them with K8sSecret tasks instead.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts runner.ImportK8sOptions
+		opts.Namespace, _ = cmd.Flags().GetString("namespace")
+		opts.Selector, _ = cmd.Flags().GetString("selector")
+		opts.Cluster.Kubeconfig, _ = cmd.Flags().GetString("kubeconfig")
+		opts.Cluster.Context, _ = cmd.Flags().GetString("context")
+		opts.Secrets, _ = cmd.Flags().GetBool("secrets")
+		opts.Flow, _ = cmd.Flags().GetString("flow")
+		opts.Package, _ = cmd.Flags().GetString("package")
+		opts.Out, _ = cmd.Flags().GetString("out")
+		if err := runner.ImportK8s(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Manage the encrypted files of K8sSecret tasks",
+}
+
+var secretEncryptCmd = &cobra.Command{
+	Use:   "encrypt [path] --task <id> <file>",
+	Short: "Encrypt the values of a K8sSecret task",
+	Long: `Encrypt a flat YAML or JSON file of values, - for stdin, with the encryption
+config of the source of a K8sSecret task, the encryption block of a TF task
+whose state method is used. The result is written to the source file of the
+task, or to --out, and is decrypted when the task is applied.
+
+The flow is loaded from path, the current directory by default.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		flowPath := "."
+		if len(args) == 2 {
+			flowPath, args = args[0], args[1:]
+		}
+		task, _ := cmd.Flags().GetString("task")
+		out, _ := cmd.Flags().GetString("out")
+		if err := runner.EncryptSecret(flowPath, task, args[0], out, rflags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [flow...]",
+	Short: "Detect changes made outside of mantis",
+	Long: `Check every task applied by the flows, or the one selected with --task, for
+changes made outside of mantis. Tasks are evaluated as mantis render does, with
+the vars and outs recorded by the last apply of their flow, so their config is
+the one of the current CUE. TF tasks run a refresh-only plan, which leaves their
+state untouched. The live objects of K8s tasks are compared to the fields set
+by their manifests.
+
+Exits with 2 when a task drifted, and 1 when the drift of a task could not
+be checked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		task, _ := cmd.Flags().GetString("task")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		drifted, err := runner.Drift(rootDir(), args, rflags, task, asJSON)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if drifted {
+			os.Exit(2)
+		}
+	},
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render [flow...] --out <dir>",
+	Short: "Write the manifests and TF config of flows for GitOps",
+	Long: `Evaluate the flows without running them and write the config of their tasks
+below --out, in a directory per flow: the manifests of K8s tasks as
+k8s/<task>.yaml, those of Helm tasks as rendered by helm template, and the
+config of TF tasks as tf/<task>/main.tf.json. Nothing is sent to a cluster or
+a cloud.
+
+Vars take the values exported by the last apply of each flow, overridden by
+--vars, a JSON or YAML file of flow -> task -> var -> value as printed by
+mantis output --json. Tasks whose when is false are skipped. The images of K8s
+tasks setting pin_images are pinned to the digests of mantis.images.lock.json,
+next to the flow. New images are resolved from their registry, and only added
+to it with --lock-images; an apply adds them otherwise.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var opts runner.RenderOptions
+		opts.Out, _ = cmd.Flags().GetString("out")
+		opts.VarsFile, _ = cmd.Flags().GetString("vars")
+		opts.Kustomization, _ = cmd.Flags().GetBool("kustomize")
+		opts.LockImages, _ = cmd.Flags().GetBool("lock-images")
+		if err := runner.Render(rootDir(), args, rflags, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+var outputCmd = &cobra.Command{
+	Use:   "output [flow...]",
+	Short: "Print the values exported by tasks",
+	Long: `Print the values exported by every task, or the one selected with --task,
+as of the last apply of each flow, without running the flows again.
+
+The values of sensitive exports, derived from sensitive attributes such as
+passwords, are printed as (sensitive) unless --json or --raw is given. They are
+then decrypted with the encryption of their task, loading the flows. Tasks which
+do not encrypt their state do not record them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		task, _ := cmd.Flags().GetString("task")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		raw, _ := cmd.Flags().GetBool("raw")
+		if err := runner.Output(rootDir(), args, rflags, task, asJSON, raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
 func init() {
 	// Initialize flags using the function from root.go
 	// flags.SetupRootPflags(rootCmd.PersistentFlags(), &rflags)
@@ -233,6 +583,75 @@ func init() {
 	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(queryCmd)
 	rootCmd.AddCommand(indexCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(providersCmd)
+	rootCmd.AddCommand(stateCmd)
+	rootCmd.AddCommand(outputCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(driftCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(secretCmd)
+
+	providersCmd.AddCommand(providersLockCmd)
+
+	stateCmd.AddCommand(stateListCmd)
+	stateCmd.AddCommand(stateShowCmd)
+	stateCmd.AddCommand(stateRmCmd)
+	stateCmd.AddCommand(stateMvCmd)
+	stateCmd.AddCommand(statePullCmd)
+	stateCmd.AddCommand(statePushCmd)
+	stateCmd.AddCommand(stateMvTaskCmd)
+	stateCmd.AddCommand(stateAdoptCmd)
+
+	importCmd.AddCommand(importResourceCmd)
+	importCmd.AddCommand(importK8sCmd)
+	secretCmd.AddCommand(secretEncryptCmd)
+	stateMvCmd.Flags().String("to-task", "", "ID of the task to move the resource into, instead of within --task")
+
+	stateAdoptCmd.Flags().String("task", "", "ID of the TF task adopting the resources")
+	stateAdoptCmd.Flags().String("from", "", "state file, - for stdin, or Terraform root module directory")
+	stateAdoptCmd.Flags().String("workspace", "", "workspace of the root module to adopt from")
+	stateAdoptCmd.Flags().StringArray("address", nil, "only adopt the resources within this address, may be repeated")
+	stateAdoptCmd.Flags().Bool("no-verify", false, "do not plan the flow after adopting")
+	stateAdoptCmd.MarkFlagRequired("task")
+	stateAdoptCmd.MarkFlagRequired("from")
+
+	importResourceCmd.Flags().String("task", "", "ID of the TF task importing the resource")
+	importResourceCmd.Flags().String("out", "", "CUE file receiving the generated configuration, imported.tf.cue next to the flow by default")
+	importResourceCmd.MarkFlagRequired("task")
+
+	importK8sCmd.Flags().String("namespace", "default", "namespace of the objects")
+	importK8sCmd.Flags().String("selector", "", "label selector of the objects, such as app=x")
+	importK8sCmd.Flags().String("kubeconfig", "", "kubeconfig file, $KUBECONFIG or ~/.kube/config by default")
+	importK8sCmd.Flags().String("context", "", "context of the kubeconfig, its current context by default")
+	importK8sCmd.Flags().Bool("secrets", false, "import Secrets with their data in plain text")
+	importK8sCmd.Flags().String("flow", "", "name of the generated flow, the namespace by default")
+	importK8sCmd.Flags().String("package", "main", "CUE package of the generated file")
+	importK8sCmd.Flags().String("out", "", "CUE file to write, k8s_<namespace>.tf.cue by default")
+
+	secretEncryptCmd.Flags().String("task", "", "ID of the K8sSecret task")
+	secretEncryptCmd.Flags().String("out", "", "encrypted file to write, the source file of the task by default")
+	secretEncryptCmd.MarkFlagRequired("task")
+
+	driftCmd.Flags().String("task", "", "only check this task")
+	driftCmd.Flags().Bool("json", false, "print the drift of each task as JSON")
+
+	renderCmd.Flags().String("out", "", "directory receiving the rendered flows")
+	renderCmd.Flags().String("vars", "", "JSON or YAML file of var values, flow -> task -> var -> value")
+	renderCmd.Flags().Bool("kustomize", false, "also write a kustomization.yaml listing the manifests of each flow")
+	renderCmd.Flags().Bool("lock-images", false, "add the digests of new images to the image lock file")
+	renderCmd.MarkFlagRequired("out")
+
+	outputCmd.Flags().String("task", "", "only print the exports of this task")
+	outputCmd.Flags().Bool("json", false, "print the exports as JSON")
+	outputCmd.Flags().Bool("raw", false, "print the values of sensitive exports")
+
+	schemaCmd.AddCommand(schemaImportCmd)
+	schemaImportCmd.AddCommand(schemaImportProviderCmd)
+	schemaImportCmd.AddCommand(schemaImportK8sCmd)
+	schemaImportCmd.AddCommand(schemaImportCRDCmd)
+
+	runCmd.Flags().Bool("resume", false, "with --apply, skip tasks completed by the previous run with unchanged inputs")
 
 	validateCmd.Flags().StringP("code-dir", "C", "", "Directory to query")
 	// Add the --code-dir flag to queryCmd
@@ -244,6 +663,9 @@ func init() {
 
 	indexCmd.Flags().StringP("code-dir", "C", "", "Directory to index")
 	indexCmd.Flags().StringP("system-prompt", "S", "", "Path to system prompt file")
+	schemaImportK8sCmd.Flags().String("version", "", "Kubernetes minor version to fetch the OpenAPI spec of, e.g. 1.30")
+	schemaImportK8sCmd.Flags().String("spec", "", "Local OpenAPI v3 document, or directory of documents, to use instead")
+
 	indexCmd.Flags().StringP("index-dir", "i", "", "Index cache directory (defaults to ~/.mantis/cache)")
 }
 
@@ -261,6 +683,7 @@ func runFlowFromFileOrDir(cmd *cobra.Command, args []string) {
 
 	// Prepare the runtime with initialized flags
 	cflags := flags.FlowPflagpole{}
+	cflags.Resume, _ = cmd.Flags().GetBool("resume")
 
 	// Convert the flowPath into a format that can be passed to Run
 	// Assuming Run can take the flowPath directly as part of args
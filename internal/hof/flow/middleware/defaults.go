@@ -30,4 +30,5 @@ func UseDefaults(ctx *hofcontext.Context, opts flags.RootPflagpole, popts flags.
 	//Workdir: ".hof/flow",
 	//}, opts, popts))
 	ctx.Use(sync.NewPool(opts, popts))
+	ctx.Use(sync.NewRetry(opts, popts))
 }
@@ -0,0 +1,209 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ *
+ * Attribution:
+ * This work is based on code from https://github.com/hofstadter-io/hof, licensed under the Apache License 2.0.
+ */
+
+package sync
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"cuelang.org/go/cue"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+)
+
+// Retry runs a task again when it fails, and bounds each attempt by a
+// deadline which is passed to the task through ctx.GoContext.
+//
+//	retry: {
+//	  attempts: 3         // total, including the first
+//	  backoff:  "10s"     // doubled after each retry
+//	  on: ["Throttling"]  // only retry errors matching one of these
+//	}
+//	timeout: "15m"        // per attempt
+type Retry struct {
+	val  cue.Value
+	next flowctx.Runner
+}
+
+// retryConfig is read when the task runs, after vars were injected
+type retryConfig struct {
+	attempts int
+	backoff  time.Duration
+	on       []*regexp.Regexp
+	timeout  time.Duration
+}
+
+const defaultBackoff = 5 * time.Second
+
+func NewRetry(opts flags.RootPflagpole, popts flags.FlowPflagpole) *Retry {
+	return &Retry{}
+}
+
+func (M *Retry) Run(ctx *flowctx.Context) (results interface{}, err error) {
+	cfg, err := parseRetry(ctx.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry/timeout @ %s: %w", M.val.Path(), err)
+	}
+
+	parent := ctx.GoContext
+	defer func() { ctx.GoContext = parent }()
+
+	backoff := cfg.backoff
+	for attempt := 1; ; attempt++ {
+		event := fmt.Sprintf("attempt.%d", attempt)
+		if ctx.BaseTask != nil {
+			ctx.BaseTask.AddTimeEvent(event + ".beg")
+		}
+
+		results, err = M.attempt(ctx, parent, cfg.timeout)
+
+		if ctx.BaseTask != nil {
+			ctx.BaseTask.AddTimeEvent(event + ".end")
+		}
+
+		if err == nil || attempt >= cfg.attempts || parent.Err() != nil || !cfg.retries(err) {
+			return results, err
+		}
+
+		fmt.Printf("task %s failed (attempt %d of %d), retrying in %s: %v\n", M.val.Path(), attempt, cfg.attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-parent.Done():
+			return results, err
+		}
+		backoff *= 2
+	}
+}
+
+// attempt runs the task once, under the timeout if there is one
+func (M *Retry) attempt(ctx *flowctx.Context, parent gocontext.Context, timeout time.Duration) (interface{}, error) {
+	if timeout <= 0 {
+		ctx.GoContext = parent
+		return M.next.Run(ctx)
+	}
+
+	deadline, cancel := gocontext.WithTimeout(parent, timeout)
+	defer cancel()
+	ctx.GoContext = deadline
+
+	results, err := M.next.Run(ctx)
+	if err != nil && errors.Is(deadline.Err(), gocontext.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", timeout, err)
+	}
+	return results, err
+}
+
+func (cfg *retryConfig) retries(err error) bool {
+	if len(cfg.on) == 0 {
+		return true
+	}
+	for _, re := range cfg.on {
+		if re.MatchString(err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRetry(val cue.Value) (*retryConfig, error) {
+	cfg := &retryConfig{attempts: 1, backoff: defaultBackoff}
+
+	if t := val.LookupPath(cue.ParsePath("timeout")); t.Exists() && t.IncompleteKind() == cue.StringKind {
+		s, err := t.String()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.timeout, err = time.ParseDuration(s); err != nil {
+			return nil, err
+		}
+	}
+
+	r := val.LookupPath(cue.ParsePath("retry"))
+	if !isRetryStruct(r) {
+		return cfg, nil
+	}
+
+	if a := r.LookupPath(cue.ParsePath("attempts")); a.Exists() {
+		n, err := a.Int64()
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("retry.attempts must be at least 1")
+		}
+		cfg.attempts = int(n)
+	}
+
+	if b := r.LookupPath(cue.ParsePath("backoff")); b.Exists() {
+		s, err := b.String()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.backoff, err = time.ParseDuration(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if on := r.LookupPath(cue.ParsePath("on")); on.Exists() {
+		iter, err := on.List()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			s, err := iter.Value().String()
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, err
+			}
+			cfg.on = append(cfg.on, re)
+		}
+	}
+
+	return cfg, nil
+}
+
+// isRetryStruct tells a retry policy from task fields of the same
+// name, such as the retry interval of os.FileLock
+func isRetryStruct(v cue.Value) bool {
+	return v.Exists() && v.IncompleteKind() == cue.StructKind
+}
+
+func (M *Retry) Apply(ctx *flowctx.Context, runner flowctx.RunnerFunc) flowctx.RunnerFunc {
+	return func(val cue.Value) (flowctx.Runner, error) {
+		next, err := runner(val)
+		if err != nil {
+			return nil, err
+		}
+
+		timeout := val.LookupPath(cue.ParsePath("timeout"))
+		hasTimeout := timeout.Exists() && timeout.IncompleteKind() == cue.StringKind
+		if !hasTimeout && !isRetryStruct(val.LookupPath(cue.ParsePath("retry"))) {
+			return next, nil
+		}
+
+		return &Retry{
+			val:  val,
+			next: next,
+		}, nil
+	}
+}
@@ -0,0 +1,127 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package sync
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+	"time"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/cmd/hof/flags"
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+)
+
+// flakyRunner fails until it ran succeedAt times, recording when it ran
+type flakyRunner struct {
+	succeedAt int
+	err       error
+	block     bool
+	runs      []time.Time
+}
+
+func (r *flakyRunner) Run(ctx *flowctx.Context) (interface{}, error) {
+	r.runs = append(r.runs, time.Now())
+	if r.block {
+		<-ctx.GoContext.Done()
+		return nil, ctx.GoContext.Err()
+	}
+	if len(r.runs) >= r.succeedAt {
+		return "ok", nil
+	}
+	return nil, r.err
+}
+
+func runRetry(t *testing.T, goctx gocontext.Context, src string, next *flakyRunner) (interface{}, error) {
+	val := cuecontext.New().CompileString(src)
+	require.NoError(t, val.Err())
+
+	runner, err := NewRetry(flags.RootPflagpole{}, flags.FlowPflagpole{}).Apply(nil, func(v cue.Value) (flowctx.Runner, error) {
+		return next, nil
+	})(val)
+	require.NoError(t, err)
+
+	ctx := flowctx.New()
+	ctx.GoContext = goctx
+	ctx.Value = val
+	return runner.Run(ctx)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	next := &flakyRunner{succeedAt: 3, err: errors.New("Throttling: slow down")}
+	res, err := runRetry(t, gocontext.Background(), `retry: {attempts: 3, backoff: "20ms"}`, next)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res)
+	require.Len(t, next.runs, 3)
+
+	// the backoff doubles after each retry
+	assert.GreaterOrEqual(t, next.runs[1].Sub(next.runs[0]), 20*time.Millisecond)
+	assert.GreaterOrEqual(t, next.runs[2].Sub(next.runs[1]), 40*time.Millisecond)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	next := &flakyRunner{succeedAt: 10, err: errors.New("Throttling")}
+	_, err := runRetry(t, gocontext.Background(), `retry: {attempts: 2, backoff: "1ms"}`, next)
+	assert.EqualError(t, err, "Throttling")
+	assert.Len(t, next.runs, 2)
+}
+
+func TestRetryOn(t *testing.T) {
+	next := &flakyRunner{succeedAt: 2, err: errors.New("AccessDenied")}
+	_, err := runRetry(t, gocontext.Background(), `retry: {attempts: 3, backoff: "1ms", on: ["Throttl"]}`, next)
+	assert.EqualError(t, err, "AccessDenied")
+	assert.Len(t, next.runs, 1, "errors not matching on are not retried")
+}
+
+func TestRetryTimeout(t *testing.T) {
+	next := &flakyRunner{block: true}
+	start := time.Now()
+	_, err := runRetry(t, gocontext.Background(), `timeout: "20ms", retry: {attempts: 2, backoff: "1ms"}`, next)
+	assert.ErrorContains(t, err, "timed out after 20ms")
+	assert.ErrorIs(t, err, gocontext.DeadlineExceeded)
+	assert.Len(t, next.runs, 2, "each attempt gets its own deadline")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRetryCancelled(t *testing.T) {
+	goctx, cancel := gocontext.WithCancel(gocontext.Background())
+	next := &flakyRunner{block: true}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err := runRetry(t, goctx, `retry: {attempts: 5, backoff: "1ms"}`, next)
+	assert.ErrorIs(t, err, gocontext.Canceled)
+	assert.Len(t, next.runs, 1, "no retry once the flow is cancelled")
+}
+
+func TestParseRetry(t *testing.T) {
+	ctx := cuecontext.New()
+
+	cfg, err := parseRetry(ctx.CompileString(`retry: "5s"`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, cfg.attempts, "a retry field which is not a policy is ignored")
+	assert.Equal(t, defaultBackoff, cfg.backoff)
+
+	_, err = parseRetry(ctx.CompileString(`retry: attempts: 0`))
+	assert.Error(t, err)
+	_, err = parseRetry(ctx.CompileString(`timeout: "soon"`))
+	assert.Error(t, err)
+	_, err = parseRetry(ctx.CompileString(`retry: on: ["("]`))
+	assert.Error(t, err)
+}
@@ -44,11 +44,29 @@ type TimeEventer interface {
 	AddTimeEvent(key string)
 }
 
+// Status is the progress of a task within a run
+type Status string
+
+const (
+	StatusPending     Status = "never started"
+	StatusRunning     Status = "running"
+	StatusCompleted   Status = "completed"
+	StatusFailed      Status = "failed"
+	StatusInterrupted Status = "interrupted"
+	StatusSkipped     Status = "skipped"
+)
+
 type BaseTask struct {
 	// IDer
 	ID   string
 	UUID uuid.UUID
 
+	Status Status
+
+	// Changes reports whether the plan of the task has changes,
+	// nil until a task which plans has run with --plan
+	Changes *bool
+
 	// cue bookkeeping
 	CueTask *cueflow.Task
 	Node    *hof.Node[any]
@@ -71,6 +89,7 @@ func NewBaseTask(node *hof.Node[any]) *BaseTask {
 		UUID:       uuid.New(),
 		Node:       node,
 		Orig:       val,
+		Status:     StatusPending,
 		TimeEvents: make(map[string]time.Time),
 	}
 }
@@ -0,0 +1,184 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ *
+ * Attribution:
+ * This work is based on code from https://github.com/hofstadter-io/hof, licensed under the Apache License 2.0.
+ */
+
+package tasker
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/hof"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// ExpandForEach adds an instance of every task with a for_each field for
+// each item, under the task and keyed by the item:
+//
+//	ns: {
+//	  @task(mantis.core.K8s)
+//	  for_each: tenants
+//	  each: _
+//	  config: metadata: name: each.key
+//	}
+//
+// runs ns.acme, ns.globex... with each set to {key, value}. The keys
+// are the labels of a struct or the items of a list, which must then be
+// strings, numbers or bools, so that instances keep their ID, and so their
+// state, as long as the item does. Other values are keyed with a struct.
+// The task itself is kept as the template and is not run.
+func ExpandForEach(root cue.Value) (cue.Value, error) {
+	var templates []cue.Value
+	if err := findForEach(root, &templates); err != nil {
+		return root, err
+	}
+
+	prefix := len(root.Path().Selectors())
+	for _, tmpl := range templates {
+		items, err := forEachItems(tmpl)
+		if err != nil {
+			return root, fmt.Errorf("for_each @ %s: %w", tmpl.Path(), err)
+		}
+
+		path := tmpl.Path().Selectors()[prefix:]
+		refs := &ast.StructLit{}
+		list := &ast.ListLit{}
+		for i, item := range items {
+			if f := tmpl.LookupPath(cue.MakePath(cue.Str(item.key))); f.Exists() {
+				return root, fmt.Errorf("for_each @ %s: key %q is also a field of the task", tmpl.Path(), item.key)
+			}
+			inst := tmpl.
+				FillPath(cue.ParsePath(mantis.MantisTaskEach+".key"), item.key).
+				FillPath(cue.ParsePath(mantis.MantisTaskEach+".value"), item.value)
+			sels := append(append([]cue.Selector{}, path...), cue.Str(item.key))
+			root = root.FillPath(cue.MakePath(sels...), inst)
+
+			alias := ast.NewIdent(fmt.Sprintf("I%d", i))
+			refs.Elts = append(refs.Elts, &ast.Field{
+				Label: &ast.Alias{Ident: alias, Expr: ast.NewString(item.key)},
+				Value: ast.NewIdent("_"),
+			})
+			// a reference into the instance, so it is not a task itself
+			list.Elts = append(list.Elts, &ast.SelectorExpr{X: ast.NewIdent(alias.Name), Sel: ast.NewIdent(mantis.MantisTaskEach)})
+		}
+
+		// tasks depending on the template depend on all of its instances
+		refs.Elts = append(refs.Elts, &ast.Field{Label: ast.NewIdent(instancesLabel), Value: list})
+		root = root.FillPath(cue.MakePath(path...), refs)
+	}
+
+	return root, root.Err()
+}
+
+// instancesLabel references the instances of a template
+const instancesLabel = "_instances"
+
+// isForEachTemplate reports whether val is a task with for_each which was
+// not instantiated by ExpandForEach
+func isForEachTemplate(val cue.Value) bool {
+	if !val.LookupPath(cue.ParsePath(mantis.MantisTaskForEach)).Exists() {
+		return false
+	}
+	return !val.LookupPath(cue.ParsePath(mantis.MantisTaskEach + ".key")).IsConcrete()
+}
+
+func findForEach(val cue.Value, found *[]cue.Value) error {
+	iter, err := val.Fields(cue.Hidden(true))
+	if err != nil {
+		return nil
+	}
+	for iter.Next() {
+		v := iter.Value()
+		if iter.Selector().IsDefinition() || v.IncompleteKind() != cue.StructKind {
+			continue
+		}
+
+		node, err := hof.ParseHof[any](v)
+		if err != nil {
+			return err
+		}
+		if node != nil && node.Hof.Flow.Task != "" {
+			if v.LookupPath(cue.ParsePath(mantis.MantisTaskForEach)).Exists() {
+				*found = append(*found, v)
+			}
+			continue
+		}
+
+		if err := findForEach(v, found); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type forEachItem struct {
+	key   string
+	value cue.Value
+}
+
+func forEachItems(tmpl cue.Value) ([]forEachItem, error) {
+	src := tmpl.LookupPath(cue.ParsePath(mantis.MantisTaskForEach))
+
+	var items []forEachItem
+	switch src.IncompleteKind() {
+	case cue.StructKind:
+		iter, err := src.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			items = append(items, forEachItem{key: iter.Selector().Unquoted(), value: iter.Value()})
+		}
+
+	case cue.ListKind:
+		iter, err := src.List()
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for i := 0; iter.Next(); i++ {
+			v := iter.Value()
+			key, err := itemKey(v)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+			if seen[key] {
+				return nil, fmt.Errorf("duplicate item %q", key)
+			}
+			seen[key] = true
+			items = append(items, forEachItem{key: key, value: v})
+		}
+
+	default:
+		return nil, fmt.Errorf("must be a list or a struct known before the flow starts, got %v", src.IncompleteKind())
+	}
+
+	return items, nil
+}
+
+// itemKey keys a list item by its value, an index would move
+// the instances of all items after an inserted one
+func itemKey(v cue.Value) (string, error) {
+	switch v.Kind() {
+	case cue.StringKind:
+		return v.String()
+	case cue.IntKind, cue.FloatKind, cue.NumberKind, cue.BoolKind:
+		b, err := v.MarshalJSON()
+		return string(b), err
+	default:
+		return "", fmt.Errorf("list items must be strings, numbers or bools, got %v; use a struct to key other values", v.IncompleteKind())
+	}
+}
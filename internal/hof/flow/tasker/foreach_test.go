@@ -0,0 +1,121 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandForEach(t *testing.T) {
+	root := cuecontext.New().CompileString(`
+f: {
+	@flow(f)
+	ns: {
+		@task(mantis.core.K8s)
+		for_each: {acme: "gold", globex: "silver"}
+		each: _
+		config: metadata: {name: each.key, labels: tier: each.value}
+	}
+	port: {
+		@task(mantis.core.K8s)
+		for_each: [80, 443]
+		each: _
+		config: spec: port: each.value
+	}
+	after: {
+		@task(mantis.core.K8s)
+		dep: ns
+	}
+}
+`)
+	require.NoError(t, root.Err())
+
+	expanded, err := ExpandForEach(root)
+	require.NoError(t, err)
+
+	str := func(path string) string {
+		s, err := expanded.LookupPath(cue.ParsePath(path)).String()
+		require.NoError(t, err, path)
+		return s
+	}
+	assert.Equal(t, "acme", str("f.ns.acme.config.metadata.name"))
+	assert.Equal(t, "silver", str("f.ns.globex.config.metadata.labels.tier"))
+	assert.Equal(t, "443", str(`f.port."443".each.key`))
+
+	port, err := expanded.LookupPath(cue.ParsePath(`f.port."80".config.spec.port`)).Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(80), port)
+
+	assert.True(t, isForEachTemplate(expanded.LookupPath(cue.ParsePath("f.ns"))))
+	assert.False(t, isForEachTemplate(expanded.LookupPath(cue.ParsePath("f.ns.acme"))))
+	assert.False(t, isForEachTemplate(expanded.LookupPath(cue.ParsePath("f.after"))))
+
+	instances, err := expanded.LookupPath(cue.MakePath(cue.Str("f"), cue.Str("ns"), cue.Hid(instancesLabel, "_"))).List()
+	require.NoError(t, err)
+	n := 0
+	for instances.Next() {
+		n++
+	}
+	assert.Equal(t, 2, n)
+}
+
+func TestForEachItems(t *testing.T) {
+	ctx := cuecontext.New()
+	keys := func(src string) ([]string, error) {
+		items, err := forEachItems(ctx.CompileString(src))
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, item.key)
+		}
+		return keys, err
+	}
+
+	got, err := keys(`for_each: ["a", "b"]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, got)
+
+	got, err = keys(`for_each: [1, 2.5, true]`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2.5", "true"}, got)
+
+	got, err = keys(`for_each: {x: {size: 1}, y: {size: 2}}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "y"}, got)
+
+	_, err = keys(`for_each: [{size: 1}]`)
+	assert.ErrorContains(t, err, "use a struct to key other values")
+
+	_, err = keys(`for_each: ["a", "a"]`)
+	assert.ErrorContains(t, err, "duplicate item")
+
+	_, err = keys(`for_each: string`)
+	assert.ErrorContains(t, err, "must be a list or a struct")
+}
+
+func TestExpandForEachKeyConflict(t *testing.T) {
+	root := cuecontext.New().CompileString(`
+ns: {
+	@task(mantis.core.K8s)
+	for_each: ["config"]
+	each: _
+	config: {}
+}
+`)
+	_, err := ExpandForEach(root)
+	assert.ErrorContains(t, err, `key "config" is also a field of the task`)
+}
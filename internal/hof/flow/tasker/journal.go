@@ -0,0 +1,154 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/format"
+	cueflow "cuelang.org/go/tools/flow"
+
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// hashInputs identifies the inputs of a task, its value with vars injected
+func hashInputs(v cue.Value) string {
+	src, err := format.Node(v.Syntax(cue.Final(), cue.Docs(false), cue.Attributes(true)))
+	if err != nil {
+		src = []byte(fmt.Sprint(v))
+	}
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordTask writes the outcome of a task to the run journal. The out of a
+// TF task and its sensitive exports are only recorded sealed, when the task
+// encrypts its state.
+func recordTask(J *flowctx.Journal, bt *task.BaseTask, inputHash string, exports []flowctx.Export) {
+	entry := &flowctx.JournalEntry{
+		Status:    bt.Status,
+		InputHash: inputHash,
+		Exports:   exports,
+	}
+	if bt.Node != nil {
+		entry.Type = bt.Node.Hof.Flow.Task
+	}
+	if bt.Error != nil {
+		entry.Error = bt.Error.Error()
+	}
+	// failed tasks may report what they did as well, such as a rollback
+	var out json.RawMessage
+	if v := bt.Final.LookupPath(cue.ParsePath(mantis.MantisTaskOuts)); v.Exists() {
+		if data, err := v.MarshalJSON(); err == nil {
+			out = data
+		}
+	}
+
+	if entry.Type == mantis.MantisTaskTF || hasSensitive(exports) {
+		config := EncryptionConfig(bt.Final)
+		err := entry.Seal(out, func(data []byte) ([]byte, error) {
+			return mantis.SealTaskData(config, data)
+		})
+		if err != nil {
+			fmt.Printf("warning: failed to seal the out of task %s in the run journal: %v\n", bt.ID, err)
+		}
+	} else {
+		entry.Out = out
+	}
+
+	if err := J.Record(bt.ID, entry); err != nil {
+		fmt.Printf("warning: failed to record task %s in the run journal: %v\n", bt.ID, err)
+	}
+}
+
+func hasSensitive(exports []flowctx.Export) bool {
+	for _, e := range exports {
+		if e.Sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptionConfig returns the encryption block of a TF task as the JSON of
+// a config declaring only it, all that the encryption of the task depends on
+func EncryptionConfig(v cue.Value) []byte {
+	enc := v.LookupPath(cue.ParsePath("config.terraform.encryption"))
+	if !enc.Exists() {
+		return []byte(`{}`)
+	}
+	data, err := enc.MarshalJSON()
+	if err != nil {
+		return []byte(`{}`)
+	}
+	return []byte(fmt.Sprintf(`{"terraform":{"encryption":%s}}`, data))
+}
+
+// UnsealEntry returns the journal entry of a task with its sealed out and
+// sensitive exports decrypted, by the encryption of the task value v
+func UnsealEntry(entry *flowctx.JournalEntry, v cue.Value) (*flowctx.JournalEntry, error) {
+	if len(entry.Sealed) == 0 {
+		return entry, nil
+	}
+	config := EncryptionConfig(v)
+	return entry.Unseal(func(data []byte) ([]byte, error) {
+		return mantis.UnsealTaskData(config, data)
+	})
+}
+
+// resumable reports whether the entry of a task records all it produced,
+// which the out of TF tasks not encrypting their state is not
+func resumable(entry *flowctx.JournalEntry) bool {
+	if entry.Type == mantis.MantisTaskTF && len(entry.Out) == 0 {
+		return false
+	}
+	for _, e := range entry.Exports {
+		if e.Sensitive && e.Value == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// resumeTask restores the outputs and exports of a task completed by a
+// previous run, instead of running it again
+func resumeTask(c *flowctx.Context, bt *task.BaseTask, t *cueflow.Task, entry *flowctx.JournalEntry) error {
+	value := c.Value
+	if len(entry.Out) > 0 {
+		var out interface{}
+		if err := json.Unmarshal(entry.Out, &out); err != nil {
+			return fmt.Errorf("invalid output of %s in the run journal: %v", bt.ID, err)
+		}
+		value = value.FillPath(cue.ParsePath(mantis.MantisTaskOuts), out)
+	}
+
+	if err := t.Fill(value); err != nil {
+		bt.Error = err
+		return err
+	}
+	bt.Final = t.Value()
+
+	taskName := c.TaskName(bt.ID)
+	for _, e := range entry.Exports {
+		c.Vars.Store(c.FlowName, taskName, e.Name, e.Value, e.Public)
+	}
+
+	bt.Status = task.StatusCompleted
+	return nil
+}
@@ -0,0 +1,201 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue"
+	cueflow "cuelang.org/go/tools/flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/hof"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestHashInputs(t *testing.T) {
+	ctx := newTestContext()
+	a := ctx.CueContext.CompileString(`config: {b: 2, a: 1} @task(x)`)
+	b := ctx.CueContext.CompileString(`config: {b: 2, a: 1} @task(x)`)
+	c := ctx.CueContext.CompileString(`config: {b: 3, a: 1} @task(x)`)
+
+	assert.Equal(t, hashInputs(a), hashInputs(b))
+	assert.NotEqual(t, hashInputs(a), hashInputs(c))
+}
+
+func TestRecordTask(t *testing.T) {
+	ctx := newTestContext()
+	path := filepath.Join(t.TempDir(), "journal_f.json")
+	J, err := flowctx.NewJournal(path, "f")
+	require.NoError(t, err)
+
+	bt := &task.BaseTask{
+		ID:     "f.app",
+		Status: task.StatusCompleted,
+		Final: ctx.CueContext.CompileString(`
+config: {kind: "ConfigMap", metadata: name: "app"}
+cluster: {server: "https://k8s", token: "secret"}
+out: {uid: "123"}
+`),
+	}
+	exports := []flowctx.Export{{Name: "uid", Value: "123"}}
+	recordTask(J, bt, "hash", exports)
+
+	entry := J.Tasks["f.app"]
+	require.NotNil(t, entry)
+	assert.Equal(t, task.StatusCompleted, entry.Status)
+	assert.Equal(t, "hash", entry.InputHash)
+	assert.JSONEq(t, `{"uid":"123"}`, string(entry.Out))
+	assert.Equal(t, exports, entry.Exports)
+	assert.Empty(t, entry.Sealed)
+
+	// a failed task keeps its out, such as a rollback
+	bt.ID = "f.broken"
+	bt.Status = task.StatusFailed
+	bt.Error = assert.AnError
+	recordTask(J, bt, "hash", nil)
+	entry = J.Tasks["f.broken"]
+	assert.Equal(t, assert.AnError.Error(), entry.Error)
+	assert.JSONEq(t, `{"uid":"123"}`, string(entry.Out))
+
+	// neither is the config of the task, nor its cluster
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "ConfigMap")
+	assert.NotContains(t, string(data), "secret")
+}
+
+const tfTaskFinal = `
+config: {
+	resource: aws_db_instance: main: password: "hunter2"
+	%s
+}
+out: aws_db_instance: main: {address: "db.internal", password: "hunter2"}
+`
+
+const tfTaskEncryption = `terraform: encryption: {
+		key_provider: pbkdf2: k: passphrase: "correct horse battery staple"
+		method: aes_gcm: m: keys: "${key_provider.pbkdf2.k}"
+		state: method: "${method.aes_gcm.m}"
+	}`
+
+func TestRecordTaskTF(t *testing.T) {
+	ctx := newTestContext()
+	path := filepath.Join(t.TempDir(), "journal_f.json")
+	J, err := flowctx.NewJournal(path, "f")
+	require.NoError(t, err)
+
+	exports := []flowctx.Export{
+		{Name: "host", Value: "db.internal"},
+		{Name: "password", Value: "hunter2", Sensitive: true},
+	}
+	node := &hof.Node[any]{}
+	node.Hof.Flow.Task = mantis.MantisTaskTF
+
+	// a task encrypting its state records its out sealed
+	encrypted := ctx.CueContext.CompileString(fmt.Sprintf(tfTaskFinal, tfTaskEncryption))
+	require.NoError(t, encrypted.Err())
+	recordTask(J, &task.BaseTask{ID: "db", Status: task.StatusCompleted, Node: node, Final: encrypted}, "hash", exports)
+	// one which does not, not at all
+	plain := ctx.CueContext.CompileString(fmt.Sprintf(tfTaskFinal, ""))
+	require.NoError(t, plain.Err())
+	recordTask(J, &task.BaseTask{ID: "db2", Status: task.StatusCompleted, Node: node, Final: plain}, "hash", exports)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+
+	entry := J.Tasks["db"]
+	assert.Empty(t, entry.Out)
+	assert.NotEmpty(t, entry.Sealed)
+	assert.Equal(t, []flowctx.Export{
+		{Name: "host", Value: "db.internal"},
+		{Name: "password", Sensitive: true},
+	}, entry.Exports)
+	assert.False(t, resumable(entry))
+
+	unsealed, err := UnsealEntry(entry, encrypted)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"aws_db_instance":{"main":{"address":"db.internal","password":"hunter2"}}}`, string(unsealed.Out))
+	assert.Equal(t, exports, unsealed.Exports)
+	assert.True(t, resumable(unsealed))
+
+	_, err = UnsealEntry(entry, plain)
+	assert.Error(t, err, "the task no longer encrypts its state")
+
+	entry = J.Tasks["db2"]
+	assert.Empty(t, entry.Out)
+	assert.Empty(t, entry.Sealed)
+	assert.False(t, resumable(entry), "the out of the task is not recorded")
+}
+
+func TestResumeTask(t *testing.T) {
+	ctx := newTestContext()
+	ctx.FlowPath = "f"
+	entry := &flowctx.JournalEntry{
+		Status:  task.StatusCompleted,
+		Out:     []byte(`{"id":"vpc-1"}`),
+		Exports: []flowctx.Export{{Name: "vpc", Value: "vpc-1", Public: true}},
+	}
+
+	root := ctx.CueContext.CompileString(`
+f: {
+	net: {
+		@task(mantis.core.TF)
+		out: _
+	}
+	app: {
+		@task(mantis.core.TF)
+		vpc: net.out.id
+	}
+}
+`)
+	require.NoError(t, root.Err())
+
+	bts := map[string]*task.BaseTask{}
+	var resumeErr error
+	ctrl := cueflow.New(nil, root, func(v cue.Value) (cueflow.Runner, error) {
+		if !mantis.IsTask(v, mantis.MantisTaskTF) {
+			return nil, nil
+		}
+		return cueflow.RunnerFunc(func(t *cueflow.Task) error {
+			id := t.Path().String()
+			bts[id] = &task.BaseTask{ID: id}
+			if id != "f.net" {
+				return nil
+			}
+			c := flowctx.Copy(ctx)
+			c.Value = t.Value()
+			resumeErr = resumeTask(c, bts[id], t, entry)
+			return resumeErr
+		}), nil
+	})
+	require.NoError(t, ctrl.Run(gocontext.Background()))
+	require.NoError(t, resumeErr)
+
+	assert.Equal(t, task.StatusCompleted, bts["f.net"].Status)
+	id, err := ctrl.Value().LookupPath(cue.ParsePath("f.app.vpc")).String()
+	require.NoError(t, err)
+	assert.Equal(t, "vpc-1", id)
+
+	val, ok := ctx.Vars.Lookup("f", "net.vpc")
+	assert.True(t, ok)
+	assert.Equal(t, "vpc-1", val)
+}
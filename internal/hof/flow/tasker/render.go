@@ -0,0 +1,67 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	"cuelang.org/go/cue"
+
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/hof"
+)
+
+// FindTasks returns the tasks of a flow whose for_each were expanded,
+// along with their types
+func FindTasks(root cue.Value) (tasks []cue.Value, types []string, err error) {
+	err = findTasks(root, &tasks, &types)
+	return tasks, types, err
+}
+
+func findTasks(val cue.Value, tasks *[]cue.Value, types *[]string) error {
+	iter, err := val.Fields(cue.Hidden(true))
+	if err != nil {
+		return err
+	}
+	for iter.Next() {
+		v := iter.Value()
+		if iter.Selector().IsDefinition() || v.IncompleteKind() != cue.StructKind {
+			continue
+		}
+
+		node, err := hof.ParseHof[any](v)
+		if err != nil {
+			return err
+		}
+		if node != nil && node.Hof.Flow.Task != "" && !isForEachTemplate(v) {
+			*tasks = append(*tasks, v)
+			*types = append(*types, node.Hof.Flow.Task)
+			continue
+		}
+		// the instances of a template are below it
+		if err := findTasks(v, tasks, types); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RenderTask returns a task with the vars of ctx injected, as it would run,
+// without running it. It reports false when the task's when is false.
+func RenderTask(ctx *flowctx.Context, taskID, taskType string, value cue.Value) (cue.Value, bool, error) {
+	expr, unknown, err := injectVariables(ctx, taskID, taskType, value, ctx.Vars)
+	if err != nil {
+		return value, false, err
+	}
+	ctx.Value = declareUnknown(ctx.CueContext.BuildExpr(expr), unknown)
+	run, err := evalWhen(ctx)
+	return ctx.Value, run, err
+}
@@ -0,0 +1,100 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestFindTasks(t *testing.T) {
+	ctx := newTestContext()
+	root := ctx.CueContext.CompileString(`
+f: {
+	@flow(f)
+	net: {
+		@task(mantis.core.TF)
+		config: {}
+	}
+	apps: web: {
+		@task(mantis.core.K8s)
+		config: {}
+	}
+	ns: {
+		@task(mantis.core.K8s)
+		for_each: ["dev", "prod"]
+		each: _
+		config: metadata: name: each.value
+	}
+	#Template: {
+		@task(mantis.core.K8s)
+		config: {}
+	}
+	settings: region: "eu-west-1"
+}
+`)
+	require.NoError(t, root.Err())
+	expanded, err := ExpandForEach(root.LookupPath(cue.ParsePath("f")))
+	require.NoError(t, err)
+
+	tasks, types, err := FindTasks(expanded)
+	require.NoError(t, err)
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.Path().String())
+	}
+	assert.Equal(t, []string{"f.net", "f.apps.web", "f.ns.dev", "f.ns.prod"}, ids)
+	assert.Equal(t, []string{mantis.MantisTaskTF, mantis.MantisTaskK8s, mantis.MantisTaskK8s, mantis.MantisTaskK8s}, types)
+
+	_, _, err = FindTasks(ctx.CueContext.CompileString(`f: net: #hof: flow: task: 1`))
+	assert.Error(t, err)
+}
+
+func TestRenderTask(t *testing.T) {
+	ctx := newTestContext()
+	ctx.Vars.Store("f", "net", "ip", "10.0.0.1", false)
+
+	task := ctx.CueContext.CompileString(`
+@task(mantis.core.K8s)
+when: true
+config: data: ip: string @var(net.ip)
+`)
+	val, run, err := RenderTask(ctx, "app", mantis.MantisTaskK8s, task)
+	require.NoError(t, err)
+	assert.True(t, run)
+	ip, err := val.LookupPath(cue.ParsePath("config.data.ip")).String()
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1", ip)
+
+	task = ctx.CueContext.CompileString(`
+@task(mantis.core.K8s)
+when: false
+config: data: ip: "x"
+`)
+	_, run, err = RenderTask(ctx, "off", mantis.MantisTaskK8s, task)
+	require.NoError(t, err)
+	assert.False(t, run)
+
+	task = ctx.CueContext.CompileString(`
+@task(mantis.core.K8s)
+when: bool
+config: data: ip: "x"
+`)
+	_, _, err = RenderTask(ctx, "unknown", mantis.MantisTaskK8s, task)
+	assert.ErrorContains(t, err, "when must be a concrete bool")
+}
@@ -18,10 +18,8 @@ package tasker
 import (
 	"fmt"
 	"log"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/itchyny/gojq"
 
@@ -63,6 +61,10 @@ func NewTasker(ctx *flowctx.Context) cueflow.TaskFunc {
 		if node.Hof.Flow.Task == "" {
 			return nil, nil
 		}
+		// the instances added by ExpandForEach are run instead
+		if isForEachTemplate(val) {
+			return nil, nil
+		}
 		//if node.Hof.Flow.Task == "nest" {
 		//  fmt.Println("New Tasker NEST", node.Hof.Path, node.Hof.Label)
 		//}
@@ -106,13 +108,45 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 	}
 
 	// do per-task setup / common base / initial value / bookkeeping
+	// tasks are found again each time the flow value is updated,
+	// keep the first to preserve its status
 	bt := task.NewBaseTask(node)
-	ctx.Tasks.Store(bt.ID, bt)
+	if prev, loaded := ctx.Tasks.LoadOrStore(bt.ID, bt); loaded {
+		bt = prev.(*task.BaseTask)
+	}
 
 	// wrap our RunnerFunc with cue/flow RunnerFunc
 	return cueflow.RunnerFunc(func(t *cueflow.Task) error {
 		//fmt.Println("makeTask.func()", t.Index(), t.Path())
 
+		// once interrupted, no more tasks are started
+		if ctx.GoContext.Err() != nil {
+			return fmt.Errorf("flow interrupted before %s started", bt.ID)
+		}
+		ctx.Running.Add(1)
+		bt.Status = task.StatusRunning
+
+		var (
+			inputHash string
+			exports   []flowctx.Export
+		)
+		defer func() {
+			// returned before the task itself ran
+			if bt.Status == task.StatusRunning {
+				bt.Status = task.StatusFailed
+			}
+			if ctx.Journal != nil && inputHash != "" {
+				recordTask(ctx.Journal, bt, inputHash, exports)
+			}
+			ctx.Running.Done()
+		}()
+
+		if skippedDeps(ctx, t) {
+			fmt.Println("Skipping task id:", bt.ID, "(all its dependencies were skipped)")
+			bt.Status = task.StatusSkipped
+			return nil
+		}
+
 		// why do we need a copy?
 		// maybe for local Value / CurrTask
 		c := flowctx.Copy(ctx)
@@ -126,15 +160,25 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 		// Inject variables before running the task
 		// (only if we are applying)
 		if c.Apply || c.Plan {
-			injectedNode, err := injectVariables(c, bt.ID, node.Value, c.GlobalVars)
+			injectedNode, unknown, err := injectVariables(c, bt.ID, node.Hof.Flow.Task, node.Value, c.Vars)
 			if err != nil {
 				return fmt.Errorf("error injecting variables: %v", err)
 			}
-			c.Value = c.CueContext.BuildExpr(injectedNode)
+			c.Value = declareUnknown(c.CueContext.BuildExpr(injectedNode), unknown)
 		}
 
 		// fmt.Println("Injected value: %v", c.Value)
 
+		run, err := evalWhen(c)
+		if err != nil {
+			return fmt.Errorf("task %s: %w", bt.ID, err)
+		}
+		if !run {
+			fmt.Println("Skipping task id:", bt.ID, "(when is false)")
+			bt.Status = task.StatusSkipped
+			return nil
+		}
+
 		if node.Hof.Flow.Print.Level > 0 && node.Hof.Flow.Print.Before {
 			pv := c.Value.LookupPath(cue.ParsePath(node.Hof.Flow.Print.Path))
 			if node.Hof.Path == "" {
@@ -149,6 +193,23 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 
 		c.BaseTask = bt
 
+		if c.Journal != nil {
+			inputHash = hashInputs(c.Value)
+			if entry := c.Journal.Completed(bt.ID, inputHash); entry != nil && c.Resume {
+				entry, err := UnsealEntry(entry, c.Value)
+				switch {
+				case err != nil:
+					fmt.Printf("Not resuming task id: %s (its out could not be unsealed: %v)\n", bt.ID, err)
+				case !resumable(entry):
+					fmt.Println("Not resuming task id:", bt.ID, "(its out is only recorded when it encrypts its state)")
+				default:
+					fmt.Println("Skipping task id:", bt.ID, "(completed by the previous run)")
+					exports = entry.Exports
+					return resumeTask(c, bt, t, entry)
+				}
+			}
+		}
+
 		// fmt.Println("MAKETASK", taskId, c.FlowStack, c.Value.Path())
 		// fmt.Printf("%# v\n", c.Value)
 
@@ -164,7 +225,21 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 
 		value, rerr := T.Run(c)
 		bt.AddTimeEvent("run.end")
+		switch {
+		case rerr == nil:
+			bt.Status = task.StatusCompleted
+		case c.GoContext.Err() != nil:
+			bt.Status = task.StatusInterrupted
+		default:
+			bt.Status = task.StatusFailed
+		}
 		if rerr != nil {
+			bt.Error = rerr
+			// a failed task may still report what it did, such as a rollback
+			if v, ok := value.(cue.Value); ok {
+				bt.Final = v
+			}
+			mergeMessages(ctx, c)
 			return fmt.Errorf("error while running task: %v", rerr)
 		}
 		if value != nil {
@@ -175,17 +250,24 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 			//  fmt.Println("FILL:", taskId, c.Value.Path(), value)
 			//}
 			err = t.Fill(value)
-			bt.Final = t.Value()
+			// the fill only shows in t.Value() once the task returns,
+			// the journal records out from bt.Final before that
+			if v, ok := value.(cue.Value); ok {
+				bt.Final = v
+			} else {
+				bt.Final = t.Value()
+			}
 			bt.AddTimeEvent("fill.end")
 
 			// fmt.Println("FILL:", taskId, c.Value.Path(), t.Value(), value)
 			if err != nil {
 				c.Error = err
 				bt.Error = err
+				bt.Status = task.StatusFailed
 				return err
 			}
 			if cueValue, ok := value.(cue.Value); ok {
-				updateGlobalVars(c, cueValue)
+				exports = updateVars(c, bt.ID, cueValue)
 			} else {
 				return fmt.Errorf("expected cue.Value, got %T", value)
 			}
@@ -197,15 +279,7 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 			// --------------------------------
 		}
 
-		// WARNING: this is because we're making a copy of ctx
-		// TODO: fix the copying of ctx for local
-		// push all errors and warnings from c to ctx
-		for _, err := range c.FlowErrors {
-			ctx.AddError(err)
-		}
-		for _, warn := range c.FlowWarnings {
-			ctx.AddWarning(warn)
-		}
+		mergeMessages(ctx, c)
 
 		if rerr != nil {
 			rerr = fmt.Errorf("in %q\n%v\n%+v", c.Value.Path(), cuetils.ExpandCueError(rerr), value)
@@ -219,20 +293,46 @@ func makeTask(ctx *flowctx.Context, node *hof.Node[any]) (cueflow.Runner, error)
 	}), nil
 }
 
-func injectVariables(ctx *flowctx.Context, taskId string, value cue.Value, globalVars *sync.Map) (ast.Expr, error) {
-	if globalVars == nil {
-		return nil, fmt.Errorf("globalVars is nil")
+// mergeMessages pushes the errors and warnings of a task's context to ctx
+func mergeMessages(ctx, c *flowctx.Context) {
+	// WARNING: this is because we're making a copy of ctx
+	// TODO: fix the copying of ctx for local
+	for _, err := range c.FlowErrors {
+		ctx.AddError(err)
+	}
+	for _, warn := range c.FlowWarnings {
+		ctx.AddWarning(warn)
+	}
+}
+
+// injectVariables replaces the fields with a @var attribute by the value of the var.
+// Values only known after apply are passed to tofu tasks as references to variables
+// which are planned as unknown, these are returned by name for the caller to declare.
+// Other task types render the placeholder as is.
+func injectVariables(ctx *flowctx.Context, taskId string, taskType string, value cue.Value, vars *flowctx.VarScope) (ast.Expr, map[string]flowctx.UnknownValue, error) {
+	if vars == nil {
+		return nil, nil, fmt.Errorf("vars is nil")
 	}
 
 	f := value.Syntax(cue.Final())
 	expr, ok := f.(ast.Expr)
 	if !ok {
-		return nil, fmt.Errorf("failed to convert value to ast.Expr for task %s", taskId)
+		return nil, nil, fmt.Errorf("failed to convert value to ast.Expr for task %s", taskId)
 	}
 
 	// Check if the expression is valid before proceeding
 	if expr == nil {
-		return nil, fmt.Errorf("invalid or missing configuration for task %s", taskId)
+		return nil, nil, fmt.Errorf("invalid or missing configuration for task %s", taskId)
+	}
+
+	unknown := map[string]flowctx.UnknownValue{}
+	placeholder := func(u flowctx.UnknownValue) ast.Expr {
+		if taskType != mantis.MantisTaskTF {
+			return ast.NewString(u.String())
+		}
+		name := fmt.Sprintf("%s%d", mantis.MantisUnknownVarPrefix, len(unknown))
+		unknown[name] = u
+		return ast.NewString("${var." + name + "}")
 	}
 
 	// Process @preinject attributes before @runinject
@@ -243,10 +343,10 @@ func injectVariables(ctx *flowctx.Context, taskId string, value cue.Value, globa
 			for _, attr := range x.Attrs {
 				if strings.HasPrefix(attr.Text, "@var") {
 					varName := parseRunInjectAttr(attr.Text)
-					if val, ok := getNestedValue(globalVars, varName); ok {
-						x.Value = createASTNodeForValue(val)
+					if val, ok := vars.Lookup(ctx.FlowName, varName); ok {
+						x.Value = createASTNodeForValue(val, placeholder)
 					} else {
-						warningMessage := buildWarningMessage(varName, taskId, globalVars)
+						warningMessage := buildWarningMessage(varName, taskId, vars.Names(ctx.FlowName))
 						ctx.AddWarning(warningMessage)
 					}
 				}
@@ -255,42 +355,15 @@ func injectVariables(ctx *flowctx.Context, taskId string, value cue.Value, globa
 		return true
 	})
 
-	return injectedNode.(ast.Expr), nil
+	return injectedNode.(ast.Expr), unknown, nil
 }
 
-func getNestedValue(globalVars *sync.Map, varName string) (interface{}, bool) {
-	parts := strings.Split(varName, ".")
-
-	var currentValue interface{}
-	var ok bool
-
-	// Start by loading the top-level key
-	if currentValue, ok = globalVars.Load(parts[0]); !ok {
-		return nil, false
+// declareUnknown adds the tofu variables standing in for values only known after apply
+func declareUnknown(value cue.Value, unknown map[string]flowctx.UnknownValue) cue.Value {
+	for name, u := range unknown {
+		value = value.FillPath(cue.MakePath(cue.Str("config"), cue.Str("variable"), cue.Str(name)), map[string]string{"type": u.Type})
 	}
-
-	for i := 1; i < len(parts); i++ {
-		switch currMap := currentValue.(type) {
-		case map[string]interface{}:
-			currentValue, ok = currMap[parts[i]]
-			if !ok {
-				return nil, false
-			}
-		case map[string]string:
-			if currentValue, ok = currMap[parts[i]]; !ok {
-				return nil, false
-			}
-		case *sync.Map:
-			if currentValue, ok = currMap.Load(parts[i]); !ok {
-				return nil, false
-			}
-		default:
-			return nil, false
-		}
-	}
-
-	// Return the resolved value
-	return currentValue, true
+	return value
 }
 
 func parseRunInjectAttr(attrText string) string {
@@ -299,29 +372,23 @@ func parseRunInjectAttr(attrText string) string {
 	return strings.Trim(attrText, "\"")
 }
 
-func buildWarningMessage(varName string, taskId string, globalVars *sync.Map) string {
+func buildWarningMessage(varName string, taskId string, names []string) string {
 	var warningMsg strings.Builder
 
 	warningMsg.WriteString(fmt.Sprintf("var '%v' not found in task '%v'\n", varName, taskId))
 	warningMsg.WriteString("Available vars:\n")
 
-	// Sort the keys for consistent output
-	keys := make([]string, 0)
-	globalVars.Range(func(key, value interface{}) bool {
-		keys = append(keys, key.(string))
-		return true
-	})
-	sort.Strings(keys)
-
-	for _, k := range keys {
+	for _, k := range names {
 		warningMsg.WriteString(fmt.Sprintf("  %s\n", k))
 	}
 
 	return warningMsg.String()
 }
 
-func createASTNodeForValue(val interface{}) ast.Expr {
+func createASTNodeForValue(val interface{}, placeholder func(flowctx.UnknownValue) ast.Expr) ast.Expr {
 	switch v := val.(type) {
+	case flowctx.UnknownValue:
+		return placeholder(v)
 	case string:
 		return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(v)}
 	case int:
@@ -337,7 +404,7 @@ func createASTNodeForValue(val interface{}) ast.Expr {
 	case []interface{}:
 		elts := make([]ast.Expr, len(v))
 		for i, item := range v {
-			elts[i] = createASTNodeForValue(item)
+			elts[i] = createASTNodeForValue(item, placeholder)
 		}
 		return &ast.ListLit{Elts: elts}
 	case map[string]interface{}:
@@ -345,7 +412,7 @@ func createASTNodeForValue(val interface{}) ast.Expr {
 		for key, value := range v {
 			fields = append(fields, &ast.Field{
 				Label: ast.NewString(key),
-				Value: createASTNodeForValue(value),
+				Value: createASTNodeForValue(value, placeholder),
 			})
 		}
 		return &ast.StructLit{Elts: fields}
@@ -355,12 +422,12 @@ func createASTNodeForValue(val interface{}) ast.Expr {
 	}
 }
 
-func updateGlobalVars(ctx *flowctx.Context, value cue.Value) {
+func updateVars(ctx *flowctx.Context, taskId string, value cue.Value) (exports []flowctx.Export) {
 	exportsValue := value.LookupPath(cue.ParsePath(mantis.MantisTaskExports))
 	outValue := value.LookupPath(cue.ParsePath(mantis.MantisTaskOuts))
 	// Check if outputsValue is null
 	if !exportsValue.Exists() {
-		return
+		return nil
 	}
 
 	// Parse outValue into a Go object
@@ -369,6 +436,8 @@ func updateGlobalVars(ctx *flowctx.Context, value cue.Value) {
 		// Instead of returning immediately, let's try to work with the partial data
 		outData = convertCueToInterface(outValue)
 	}
+	outData = flowctx.MaskUnknowns(outData, ctx.Unknown)
+	sensitiveData := flowctx.MaskSensitive(outData, ctx.Sensitive)
 
 	if exportsValue.Exists() {
 		switch exportsValue.Kind() {
@@ -379,9 +448,20 @@ func updateGlobalVars(ctx *flowctx.Context, value cue.Value) {
 				varName, _ := outputDef.LookupPath(cue.ParsePath(mantis.MantisVar)).String()
 				jqPath, _ := outputDef.LookupPath(cue.ParsePath(mantis.MantisDataSourcePath)).String()
 				exportAs := outputDef.LookupPath(cue.ParsePath(mantis.MantisExportAs)).Kind()
+				public, _ := outputDef.LookupPath(cue.ParsePath(mantis.MantisExportPublic)).Bool()
 
 				actualValue := processOutput(ctx, varName, jqPath, outData, exportAs)
-				ctx.GlobalVars.Store(varName, actualValue)
+				sensitive := false
+				if len(ctx.Sensitive) > 0 && actualValue != nil {
+					masked, _ := queryJQ(sensitiveData, jqPath)
+					sensitive = flowctx.IsSensitive(masked)
+				}
+				taskName := ctx.TaskName(taskId)
+				if prev := ctx.Vars.Store(ctx.FlowName, taskName, varName, actualValue, public); prev != "" {
+					ctx.AddWarning(fmt.Sprintf("var '%s' is exported by both '%s' and '%s', bare @var(%s) now resolves to '%s'. Use @var(<task>.%s) to disambiguate\n",
+						varName, prev, taskName, varName, taskName, varName))
+				}
+				exports = append(exports, flowctx.Export{Name: varName, Value: actualValue, Public: public, Sensitive: sensitive})
 			}
 		default:
 			fmt.Printf("Unexpected exports kind: %v\n", exportsValue.Kind())
@@ -389,6 +469,7 @@ func updateGlobalVars(ctx *flowctx.Context, value cue.Value) {
 	} else {
 		fmt.Println("No exports defined for this task")
 	}
+	return exports
 }
 
 func processOutput(ctx *flowctx.Context, varName, jqPath string, outData interface{}, exportAs cue.Kind) interface{} {
@@ -397,6 +478,7 @@ func processOutput(ctx *flowctx.Context, varName, jqPath string, outData interfa
 		ctx.AddWarning(fmt.Sprintf("Value not found at path: %s for var: %s\n", jqPath, varName))
 		return nil
 	}
+	actualValue = flowctx.UnmaskUnknowns(actualValue)
 
 	// Determine if the query should return an array
 	if shouldReturnArray(jqPath) || exportAs == cue.ListKind {
@@ -0,0 +1,141 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+const plannedTask = `
+config: {
+	resource: null_resource: b: triggers: {
+		id:   _ @var(id)
+		ids:  _ @var(ids)
+		note: _ @var(note)
+	}
+}
+exports: [
+	{var: "id", jqpath: ".null_resource.a.id"},
+	{var: "note", jqpath: ".null_resource.a.triggers.note"},
+]
+out: null_resource: a: {
+	id: "(known after apply: string)"
+	triggers: note: "(known after apply: string)"
+}
+`
+
+func newTestContext() *flowctx.Context {
+	ctx := flowctx.New()
+	ctx.CueContext = cuecontext.New()
+	ctx.FlowName = "f"
+	return ctx
+}
+
+func TestUpdateVarsUnknown(t *testing.T) {
+	ctx := newTestContext()
+	value := ctx.CueContext.CompileString(plannedTask)
+	require.NoError(t, value.Err())
+
+	// only the id was unknown to the plan, the note is a string of the user
+	ctx.Unknown = flowctx.UnknownPaths(map[string]interface{}{
+		"null_resource": map[string]interface{}{
+			"a": map[string]interface{}{
+				"id":       flowctx.NewUnknownValue(cty.String),
+				"triggers": map[string]interface{}{"note": "(known after apply: string)"},
+			},
+		},
+	})
+	updateVars(ctx, "b", value)
+
+	id, ok := ctx.Vars.Lookup("f", "id")
+	require.True(t, ok)
+	assert.Equal(t, flowctx.UnknownValue{Type: "string"}, id)
+
+	note, ok := ctx.Vars.Lookup("f", "note")
+	require.True(t, ok)
+	assert.Equal(t, "(known after apply: string)", note)
+}
+
+func TestUpdateVarsSensitive(t *testing.T) {
+	ctx := newTestContext()
+	value := ctx.CueContext.CompileString(`
+exports: [
+	{var: "host", jqpath: ".aws_db_instance.main.address"},
+	{var: "password", jqpath: ".aws_db_instance.main.password"},
+	{var: "db", jqpath: ".aws_db_instance.main"},
+]
+out: aws_db_instance: main: {address: "db.internal", password: "hunter2"}
+`)
+	require.NoError(t, value.Err())
+	ctx.Sensitive = []string{"aws_db_instance.main.password"}
+
+	exports := updateVars(ctx, "db", value)
+	require.Len(t, exports, 3)
+	assert.False(t, exports[0].Sensitive)
+	assert.True(t, exports[1].Sensitive)
+	assert.True(t, exports[2].Sensitive, "an object holding a sensitive value")
+
+	// the values themselves are unchanged for the tasks which use them
+	assert.Equal(t, "hunter2", exports[1].Value)
+	password, ok := ctx.Vars.Lookup("f", "password")
+	require.True(t, ok)
+	assert.Equal(t, "hunter2", password)
+}
+
+func TestInjectVariablesUnknown(t *testing.T) {
+	ctx := newTestContext()
+	ctx.Vars.Store("f", "a", "id", flowctx.NewUnknownValue(cty.String), false)
+	ctx.Vars.Store("f", "a", "ids", flowctx.NewUnknownValue(cty.List(cty.String)), false)
+	ctx.Vars.Store("f", "a", "note", "(known after apply: string)", false)
+
+	value := ctx.CueContext.CompileString(plannedTask)
+	require.NoError(t, value.Err())
+	triggers := cue.ParsePath("config.resource.null_resource.b.triggers")
+
+	expr, unknown, err := injectVariables(ctx, "b", mantis.MantisTaskTF, value, ctx.Vars)
+	require.NoError(t, err)
+	injected := declareUnknown(ctx.CueContext.BuildExpr(expr), unknown)
+	require.NoError(t, injected.Err())
+
+	var got map[string]string
+	require.NoError(t, injected.LookupPath(triggers).Decode(&got))
+	assert.Equal(t, map[string]string{
+		"id":   "${var.mantis_unknown_0}",
+		"ids":  "${var.mantis_unknown_1}",
+		"note": "(known after apply: string)",
+	}, got)
+
+	var vars map[string]map[string]string
+	require.NoError(t, injected.LookupPath(cue.ParsePath("config.variable")).Decode(&vars))
+	assert.Equal(t, map[string]map[string]string{
+		"mantis_unknown_0": {"type": "string"},
+		"mantis_unknown_1": {"type": "list(string)"},
+	}, vars)
+
+	// other task types get the placeholder itself
+	expr, unknown, err = injectVariables(ctx, "b", "mantis.core.K8s", value, ctx.Vars)
+	require.NoError(t, err)
+	assert.Empty(t, unknown)
+	injected = ctx.CueContext.BuildExpr(expr)
+	require.NoError(t, injected.LookupPath(triggers).Decode(&got))
+	assert.Equal(t, "(known after apply: list(string))", got["ids"])
+}
@@ -0,0 +1,61 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ *
+ * Attribution:
+ * This work is based on code from https://github.com/hofstadter-io/hof, licensed under the Apache License 2.0.
+ */
+
+package tasker
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	cueflow "cuelang.org/go/tools/flow"
+
+	flowctx "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// evalWhen reports whether the task should run, after vars were injected.
+// Init only prepares tasks, so a condition not known yet runs the task.
+func evalWhen(ctx *flowctx.Context) (bool, error) {
+	when := ctx.Value.LookupPath(cue.ParsePath(mantis.MantisTaskWhen))
+	if !when.Exists() {
+		return true, nil
+	}
+	if ctx.Init && !when.IsConcrete() {
+		return true, nil
+	}
+	run, err := when.Bool()
+	if err != nil {
+		return false, fmt.Errorf("when must be a concrete bool: %v", err)
+	}
+	return run, nil
+}
+
+// skippedDeps reports whether t only depends on tasks which were skipped,
+// in which case it is skipped as well. A task which also depends on a task
+// which ran, such as another instance of a for_each template, still runs.
+func skippedDeps(ctx *flowctx.Context, t *cueflow.Task) bool {
+	deps := t.Dependencies()
+	if len(deps) == 0 {
+		return false
+	}
+	for _, dep := range deps {
+		v, ok := ctx.Tasks.Load(dep.Path().String())
+		if !ok || v.(*task.BaseTask).Status != task.StatusSkipped {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package tasker
+
+import (
+	gocontext "context"
+	"testing"
+
+	"cuelang.org/go/cue"
+	cueflow "cuelang.org/go/tools/flow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestSkippedDeps(t *testing.T) {
+	ctx := newTestContext()
+	root := ctx.CueContext.CompileString(`
+skipped: {
+	@task(mantis.core.TF)
+	out: "s"
+}
+ran: {
+	@task(mantis.core.TF)
+	out: "r"
+}
+both: {
+	@task(mantis.core.TF)
+	in: [skipped.out, ran.out]
+}
+one: {
+	@task(mantis.core.TF)
+	in: ran.out
+}
+none: {
+	@task(mantis.core.TF)
+}
+only: {
+	@task(mantis.core.TF)
+	in: skipped.out
+}
+// the instances of a for_each template, one of which is skipped
+ns: dev: {
+	@task(mantis.core.TF)
+	out: "dev"
+}
+ns: prod: {
+	@task(mantis.core.TF)
+	out: "prod"
+}
+instances: {
+	@task(mantis.core.TF)
+	in: [for n in ns {n.out}]
+}
+`)
+	require.NoError(t, root.Err())
+
+	skips := map[string]bool{}
+	ctrl := cueflow.New(nil, root, func(v cue.Value) (cueflow.Runner, error) {
+		if !mantis.IsTask(v, mantis.MantisTaskTF) {
+			return nil, nil
+		}
+		return cueflow.RunnerFunc(func(t *cueflow.Task) error {
+			id := t.Path().String()
+			bt := &task.BaseTask{ID: id, Status: task.StatusCompleted}
+			if id == "skipped" || id == "ns.prod" {
+				bt.Status = task.StatusSkipped
+			}
+			ctx.Tasks.Store(id, bt)
+			skips[id] = skippedDeps(ctx, t)
+			return nil
+		}), nil
+	})
+	require.NoError(t, ctrl.Run(gocontext.Background()))
+
+	assert.Equal(t, map[string]bool{
+		"skipped":   false,
+		"ran":       false,
+		"both":      false,
+		"one":       false,
+		"none":      false,
+		"only":      true,
+		"ns.dev":    false,
+		"ns.prod":   false,
+		"instances": false,
+	}, skips, "only tasks whose dependencies were all skipped are skipped")
+}
@@ -2,12 +2,15 @@ package kubernetes
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
-	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -23,6 +26,8 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/yagu"
 )
 
 type Client struct {
@@ -31,7 +36,67 @@ type Client struct {
 	mapper    *restmapper.DeferredDiscoveryRESTMapper
 }
 
-func getConfig() (*rest.Config, error) {
+// Cluster selects the cluster of a K8s task. Without any field set, the
+// in-cluster config is used, then the current context of the kubeconfig.
+type Cluster struct {
+	// Kubeconfig is the kubeconfig file, $KUBECONFIG or ~/.kube/config by default
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// Context of the kubeconfig, its current context by default
+	Context string `json:"context,omitempty"`
+
+	// Server is the URL of the API server, used with CA and Token instead
+	// of a kubeconfig, such as the endpoint of a cluster created by a TF task
+	Server string `json:"server,omitempty"`
+
+	// CA is the PEM certificate of the cluster's authority, or its
+	// base64 encoding as output by EKS
+	CA string `json:"ca,omitempty"`
+
+	// Token is the bearer token authenticating to Server
+	Token string `json:"token,omitempty"`
+
+	// Insecure skips the verification of the server certificate
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[Cluster]*Client{}
+)
+
+func getConfig(cluster Cluster) (*rest.Config, error) {
+	if cluster.Server != "" {
+		config := &rest.Config{
+			Host:        cluster.Server,
+			BearerToken: cluster.Token,
+		}
+		config.TLSClientConfig.Insecure = cluster.Insecure
+		if cluster.CA != "" {
+			ca := []byte(cluster.CA)
+			if !strings.HasPrefix(strings.TrimSpace(cluster.CA), "-----BEGIN") {
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(cluster.CA))
+				if err != nil {
+					return nil, fmt.Errorf("cluster ca is neither PEM nor base64: %v", err)
+				}
+				ca = decoded
+			}
+			config.TLSClientConfig.CAData = ca
+		}
+		return config, nil
+	}
+
+	if cluster.Kubeconfig != "" || cluster.Context != "" {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = cluster.Kubeconfig
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: cluster.Context}
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig: %v", err)
+		}
+		return config, nil
+	}
+
 	// Try in-cluster config first
 	config, err := rest.InClusterConfig()
 	if err == nil {
@@ -52,8 +117,21 @@ func getConfig() (*rest.Config, error) {
 	return config, nil
 }
 
+// NewClient returns a client of the default cluster
 func NewClient() (*Client, error) {
-	config, err := getConfig()
+	return ClientFor(Cluster{})
+}
+
+// ClientFor returns the client of a cluster, created once and shared by
+// all the tasks deploying to it
+func ClientFor(cluster Cluster) (*Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[cluster]; ok {
+		return c, nil
+	}
+
+	config, err := getConfig(cluster)
 	if err != nil {
 		return nil, err
 	}
@@ -70,88 +148,241 @@ func NewClient() (*Client, error) {
 
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
 
-	return &Client{
+	c := &Client{
 		clientset: clientset,
 		dynamic:   dynamicClient,
 		mapper:    mapper,
-	}, nil
+	}
+	clients[cluster] = c
+	return c, nil
 }
 
-func (c *Client) Apply(manifest string) error {
-	return c.applyOrDelete(manifest, false, false, false)
+// Apply applies the manifest and returns the object as returned by the server
+func (c *Client) Apply(ctx context.Context, manifest string, opts ApplyOptions) (*unstructured.Unstructured, error) {
+	return c.applyOrDelete(ctx, manifest, opts, false, false, false)
 }
 
-func (c *Client) Delete(manifest string) error {
-	return c.applyOrDelete(manifest, true, false, false)
+func (c *Client) Delete(ctx context.Context, manifest string) error {
+	_, err := c.applyOrDelete(ctx, manifest, ApplyOptions{}, true, false, false)
+	return err
 }
 
-func (c *Client) Plan(manifest string) error {
-	return c.applyOrDelete(manifest, false, false, true)
+// Plan shows the changes applying the manifest would make, and the fields
+// it sets which another manager owns
+func (c *Client) Plan(ctx context.Context, manifest string, opts ApplyOptions) error {
+	_, err := c.applyOrDelete(ctx, manifest, opts, false, false, true)
+	return err
 }
 
-func (c *Client) applyOrDelete(manifestYAML string, delete bool, dryRun bool, plan bool) error {
+func (c *Client) applyOrDelete(ctx context.Context, manifestYAML string, opts ApplyOptions, delete bool, dryRun bool, plan bool) (*unstructured.Unstructured, error) {
 	var obj map[string]interface{}
 	err := yaml.Unmarshal([]byte(manifestYAML), &obj)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal manifest: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
 	}
+	removePaths(obj, opts.IgnoreFields)
 
 	u := &unstructured.Unstructured{Object: obj}
 	gvk := u.GroupVersionKind()
 
-	// Get the GVR
-	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	resourceClient, err := c.resourceClient(u)
 	if err != nil {
-		return fmt.Errorf("failed to connect to kubernetes cluster: %w", err)
-	}
-
-	gvr := mapping.Resource
-
-	var resourceClient dynamic.ResourceInterface
-	namespace := u.GetNamespace()
-	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
-		// Cluster-scoped resource
-		resourceClient = c.dynamic.Resource(gvr)
-	} else {
-		// Namespace-scoped resource
-		if namespace == "" {
-			namespace = "default" // or any other default namespace you want to use
-		}
-		resourceClient = c.dynamic.Resource(gvr).Namespace(namespace)
+		return nil, err
 	}
 
-	ctx := context.Background()
-
 	if delete {
 		if dryRun {
 			fmt.Printf("Would delete %s/%s (dry run)\n", gvk.Kind, u.GetName())
 		} else {
 			err = resourceClient.Delete(ctx, u.GetName(), metav1.DeleteOptions{})
 			if err != nil && !k8serrors.IsNotFound(err) {
-				return fmt.Errorf("failed to delete %s/%s: %w", gvk.Kind, u.GetName(), err)
+				return nil, fmt.Errorf("failed to delete %s/%s: %w", gvk.Kind, u.GetName(), err)
 			}
 		}
 	} else {
 		if dryRun || plan {
 			if plan {
-				err = c.showDiff(resourceClient, u)
+				err = c.showDiff(ctx, resourceClient, u, opts.IgnoreFields)
+				if err != nil {
+					return nil, fmt.Errorf("failed to show diff for %s/%s: %w", gvk.Kind, u.GetName(), err)
+				}
+				err = c.showConflicts(ctx, resourceClient, u, opts)
 				if err != nil {
-					return fmt.Errorf("failed to show diff for %s/%s: %w", gvk.Kind, u.GetName(), err)
+					return nil, fmt.Errorf("failed to check field managers of %s/%s: %w", gvk.Kind, u.GetName(), err)
 				}
 			}
 		} else {
-			_, err = resourceClient.Apply(ctx, u.GetName(), u, metav1.ApplyOptions{FieldManager: "client"})
+			if err := adoptLegacyFields(ctx, resourceClient, u.GetName(), opts.fieldManager()); err != nil {
+				return nil, fmt.Errorf("failed to hand the fields of %s/%s over to %s: %w", gvk.Kind, u.GetName(), opts.fieldManager(), err)
+			}
+			applied, err := resourceClient.Apply(ctx, u.GetName(), u, metav1.ApplyOptions{
+				FieldManager: opts.fieldManager(),
+				Force:        opts.ForceConflicts,
+			})
+			if fields := conflicts(err); fields != nil {
+				return nil, fmt.Errorf("failed to apply %s/%s, other managers own these fields:\n  %s\nSet force_conflicts to take them over, or list them in ignore_fields to leave them to their manager",
+					gvk.Kind, u.GetName(), strings.Join(fields, "\n  "))
+			}
 			if err != nil {
-				return fmt.Errorf("failed to apply %s/%s: %w", gvk.Kind, u.GetName(), err)
+				return nil, fmt.Errorf("failed to apply %s/%s: %w", gvk.Kind, u.GetName(), err)
 			}
+			return applied, nil
 		}
 	}
 
-	return nil
+	return nil, nil
 }
 
-func (c *Client) showDiff(resourceClient dynamic.ResourceInterface, expected *unstructured.Unstructured) error {
-	actual, err := resourceClient.Get(context.TODO(), expected.GetName(), metav1.GetOptions{})
+// resourceClient returns the client of the resource of u, in its namespace
+// when the resource is namespaced
+func (c *Client) resourceClient(u *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := u.GroupVersionKind()
+
+	// Get the GVR
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kubernetes cluster: %w", err)
+	}
+
+	gvr := mapping.Resource
+
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		// Cluster-scoped resource
+		return c.dynamic.Resource(gvr), nil
+	}
+	// Namespace-scoped resource
+	namespace := u.GetNamespace()
+	if namespace == "" {
+		namespace = "default" // or any other default namespace you want to use
+	}
+	return c.dynamic.Resource(gvr).Namespace(namespace), nil
+}
+
+// Drift compares the live object of a manifest to the fields the manifest
+// sets, fields defaulted or added by the cluster, or ignored, are not drift. It returns
+// the paths of the fields which differ, or the object when it is missing.
+func (c *Client) Drift(ctx context.Context, manifestYAML string, ignoreFields []string) ([]string, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(manifestYAML), &obj); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	removePaths(obj, ignoreFields)
+	expected := &unstructured.Unstructured{Object: obj}
+	name := fmt.Sprintf("%s/%s", expected.GetKind(), expected.GetName())
+
+	resourceClient, err := c.resourceClient(expected)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := resourceClient.Get(ctx, expected.GetName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return []string{name + " deleted"}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", name, err)
+	}
+
+	removeFields(expected)
+	var drifted []string
+	for _, path := range driftedFields(expected.Object, actual.Object, "") {
+		drifted = append(drifted, name+" "+path)
+	}
+	return drifted, nil
+}
+
+// prunedTo returns the fields of actual which expected sets as well
+func prunedTo(actual, expected interface{}) interface{} {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return actual
+		}
+		out := make(map[string]interface{}, len(e))
+		for k, v := range e {
+			if av, ok := a[k]; ok {
+				out[k] = prunedTo(av, v)
+			}
+		}
+		return out
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return actual
+		}
+		out := make([]interface{}, len(a))
+		for i := range a {
+			out[i] = prunedTo(a[i], e[i])
+		}
+		return out
+	default:
+		return actual
+	}
+}
+
+// driftedFields returns the paths of the fields of expected which actual
+// does not have, or has with another value
+func driftedFields(expected, actual interface{}, path string) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{path}
+		}
+		var out []string
+		for _, k := range yagu.SortedKeys(e) {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			av, ok := a[k]
+			if !ok {
+				if e[k] != nil {
+					out = append(out, p)
+				}
+				continue
+			}
+			out = append(out, driftedFields(e[k], av, p)...)
+		}
+		return out
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return []string{path}
+		}
+		var out []string
+		for i := range e {
+			out = append(out, driftedFields(e[i], a[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return out
+	default:
+		if !scalarEqual(expected, actual) {
+			return []string{path}
+		}
+		return nil
+	}
+}
+
+// scalarEqual compares the leaves of two objects. Numbers compare by value,
+// as they decode as int64 or float64 depending on where they were read from.
+func scalarEqual(expected, actual interface{}) bool {
+	e, eok := number(expected)
+	a, aok := number(actual)
+	if eok || aok {
+		return eok && aok && e.Cmp(a) == 0
+	}
+	return expected == actual
+}
+
+func number(v interface{}) (*big.Rat, bool) {
+	switch v.(type) {
+	case int, int32, int64, uint, uint32, uint64, float32, float64, json.Number:
+		return new(big.Rat).SetString(fmt.Sprint(v))
+	}
+	return nil, false
+}
+
+func (c *Client) showDiff(ctx context.Context, resourceClient dynamic.ResourceInterface, expected *unstructured.Unstructured, ignoreFields []string) error {
+	actual, err := resourceClient.Get(ctx, expected.GetName(), metav1.GetOptions{})
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			fmt.Printf("Resource does not exist. It will be created.\n")
@@ -163,11 +394,14 @@ func (c *Client) showDiff(resourceClient dynamic.ResourceInterface, expected *un
 	// Remove fields that are not relevant for comparison
 	removeFields(actual)
 	removeFields(expected)
+	removePaths(actual.Object, ignoreFields)
 
-	if equality.Semantic.DeepEqual(actual, expected) {
+	// fields the manifest leaves out, such as defaults, are not changes
+	if len(driftedFields(expected.Object, actual.Object, "")) == 0 {
 		fmt.Printf("No changes detected.\n")
 		return nil
 	}
+	actual.Object = prunedTo(actual.Object, expected.Object).(map[string]interface{})
 
 	actualYAML, err := runtime.Encode(unstructured.UnstructuredJSONScheme, actual)
 	if err != nil {
@@ -199,6 +433,46 @@ func (c *Client) showDiff(resourceClient dynamic.ResourceInterface, expected *un
 	return nil
 }
 
+// showConflicts lists the fields of expected owned by other managers,
+// found with a server-side dry run of the apply
+func (c *Client) showConflicts(ctx context.Context, resourceClient dynamic.ResourceInterface, expected *unstructured.Unstructured, opts ApplyOptions) error {
+	_, err := resourceClient.Apply(ctx, expected.GetName(), expected, metav1.ApplyOptions{
+		FieldManager: opts.fieldManager(),
+		DryRun:       []string{metav1.DryRunAll},
+	})
+	fields := conflicts(err)
+	if fields == nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	// the apply takes the fields of the legacy manager over first
+	var owned []string
+	for _, f := range fields {
+		if !isLegacyConflict(f) {
+			owned = append(owned, f)
+		}
+	}
+	if len(owned) < len(fields) {
+		fmt.Printf("Fields applied by %s will be taken over by %s\n", legacyFieldManager, opts.fieldManager())
+	}
+	if fields = owned; len(fields) == 0 {
+		return nil
+	}
+
+	if opts.ForceConflicts {
+		fmt.Printf("Fields owned by other managers, taken over by force_conflicts:\n")
+	} else {
+		fmt.Printf("Fields owned by other managers, the apply will fail unless force_conflicts is set or they are listed in ignore_fields:\n")
+	}
+	for _, f := range fields {
+		fmt.Printf("  %s\n", f)
+	}
+	return nil
+}
+
 func removeFields(obj *unstructured.Unstructured) {
 	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
 	unstructured.RemoveNestedField(obj.Object, "metadata", "resourceVersion")
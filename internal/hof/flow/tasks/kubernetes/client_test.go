@@ -0,0 +1,109 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriftedFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		want     []string
+	}{
+		{
+			name:     "equal",
+			expected: map[string]interface{}{"replicas": int64(2), "name": "web"},
+			actual:   map[string]interface{}{"replicas": int64(2), "name": "web"},
+		},
+		{
+			name:     "int and float",
+			expected: map[string]interface{}{"replicas": int64(2)},
+			actual:   map[string]interface{}{"replicas": float64(2)},
+		},
+		{
+			name:     "large float",
+			expected: map[string]interface{}{"bytes": float64(1000000)},
+			actual:   map[string]interface{}{"bytes": int64(1000000)},
+		},
+		{
+			name:     "json number",
+			expected: map[string]interface{}{"ratio": json.Number("0.5")},
+			actual:   map[string]interface{}{"ratio": float64(0.5)},
+		},
+		{
+			name:     "changed number",
+			expected: map[string]interface{}{"replicas": int64(2)},
+			actual:   map[string]interface{}{"replicas": float64(2.5)},
+			want:     []string{"replicas"},
+		},
+		{
+			name:     "number and string",
+			expected: map[string]interface{}{"port": "80"},
+			actual:   map[string]interface{}{"port": int64(80)},
+			want:     []string{"port"},
+		},
+		{
+			name:     "bool and string",
+			expected: map[string]interface{}{"enabled": true},
+			actual:   map[string]interface{}{"enabled": "true"},
+			want:     []string{"enabled"},
+		},
+		{
+			name:     "fields left out of the manifest",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			actual:   map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1), "paused": false}},
+		},
+		{
+			name:     "missing field",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1), "paused": nil}},
+			actual:   map[string]interface{}{"spec": map[string]interface{}{}},
+			want:     []string{"spec.replicas"},
+		},
+		{
+			name: "lists",
+			expected: map[string]interface{}{"ports": []interface{}{
+				map[string]interface{}{"port": int64(80)},
+				map[string]interface{}{"port": int64(443)},
+			}},
+			actual: map[string]interface{}{"ports": []interface{}{
+				map[string]interface{}{"port": float64(80)},
+				map[string]interface{}{"port": int64(8443)},
+			}},
+			want: []string{"ports[1].port"},
+		},
+		{
+			name:     "list length",
+			expected: map[string]interface{}{"args": []interface{}{"a"}},
+			actual:   map[string]interface{}{"args": []interface{}{"a", "b"}},
+			want:     []string{"args"},
+		},
+		{
+			name:     "object replaced",
+			expected: map[string]interface{}{"data": map[string]interface{}{"a": "b"}},
+			actual:   map[string]interface{}{"data": "a=b"},
+			want:     []string{"data"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, driftedFields(tt.expected, tt.actual, ""))
+		})
+	}
+}
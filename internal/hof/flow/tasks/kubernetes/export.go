@@ -0,0 +1,395 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// exportSkipped are the resources, resource.group, maintained by the cluster
+// itself, which are never exported
+var exportSkipped = map[string]bool{
+	"events":                          true,
+	"events.events.k8s.io":            true,
+	"endpoints":                       true,
+	"endpointslices.discovery.k8s.io": true,
+	"controllerrevisions.apps":        true,
+	"leases.coordination.k8s.io":      true,
+	"pods.metrics.k8s.io":             true,
+}
+
+// exportedAnnotations prefixes the annotations set by kubectl and controllers
+var exportedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/",
+	"pv.kubernetes.io/",
+	"volume.kubernetes.io/",
+	"volume.beta.kubernetes.io/",
+}
+
+// fieldDefault is a field the API server sets to value when a manifest
+// leaves it out, a path such as spec.ports[*].protocol
+type fieldDefault struct {
+	path  string
+	value interface{}
+}
+
+var podSpecDefaults = []fieldDefault{
+	{"dnsPolicy", "ClusterFirst"},
+	{"restartPolicy", "Always"},
+	{"schedulerName", "default-scheduler"},
+	{"securityContext", map[string]interface{}{}},
+	{"terminationGracePeriodSeconds", 30},
+	{"enableServiceLinks", true},
+	{"preemptionPolicy", "PreemptLowerPriority"},
+	{"priority", 0},
+}
+
+var containerDefaults = []fieldDefault{
+	{"terminationMessagePath", "/dev/termination-log"},
+	{"terminationMessagePolicy", "File"},
+	{"resources", map[string]interface{}{}},
+	{"ports[*].protocol", "TCP"},
+}
+
+var kindDefaults = map[string][]fieldDefault{
+	"Deployment": {
+		{"spec.progressDeadlineSeconds", 600},
+		{"spec.revisionHistoryLimit", 10},
+		{"spec.strategy", map[string]interface{}{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{"maxSurge": "25%", "maxUnavailable": "25%"},
+		}},
+	},
+	"StatefulSet": {
+		{"spec.podManagementPolicy", "OrderedReady"},
+		{"spec.revisionHistoryLimit", 10},
+		{"spec.updateStrategy", map[string]interface{}{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{"partition": 0},
+		}},
+		{"spec.persistentVolumeClaimRetentionPolicy", map[string]interface{}{"whenDeleted": "Retain", "whenScaled": "Retain"}},
+		{"spec.volumeClaimTemplates[*].spec.volumeMode", "Filesystem"},
+	},
+	"DaemonSet": {
+		{"spec.revisionHistoryLimit", 10},
+		{"spec.updateStrategy", map[string]interface{}{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{"maxSurge": 0, "maxUnavailable": 1},
+		}},
+	},
+	"Job": {
+		{"spec.backoffLimit", 6},
+		{"spec.completionMode", "NonIndexed"},
+		{"spec.completions", 1},
+		{"spec.parallelism", 1},
+		{"spec.suspend", false},
+		{"spec.podReplacementPolicy", "TerminatingOrFailed"},
+	},
+	"CronJob": {
+		{"spec.concurrencyPolicy", "Allow"},
+		{"spec.failedJobsHistoryLimit", 1},
+		{"spec.successfulJobsHistoryLimit", 3},
+		{"spec.suspend", false},
+	},
+	"Service": {
+		{"spec.type", "ClusterIP"},
+		{"spec.sessionAffinity", "None"},
+		{"spec.internalTrafficPolicy", "Cluster"},
+		{"spec.ipFamilyPolicy", "SingleStack"},
+		{"spec.ports[*].protocol", "TCP"},
+	},
+	"PersistentVolumeClaim": {
+		{"spec.volumeMode", "Filesystem"},
+		{"metadata.finalizers", []interface{}{"kubernetes.io/pvc-protection"}},
+	},
+}
+
+// podSpecPaths are the paths of the PodSpec and pod template of a kind
+var podSpecPaths = map[string]string{
+	"Pod":                   "spec",
+	"Deployment":            "spec.template.spec",
+	"StatefulSet":           "spec.template.spec",
+	"DaemonSet":             "spec.template.spec",
+	"ReplicaSet":            "spec.template.spec",
+	"ReplicationController": "spec.template.spec",
+	"Job":                   "spec.template.spec",
+	"CronJob":               "spec.jobTemplate.spec.template.spec",
+}
+
+// Export returns the objects of the namespaced resources of a namespace
+// matching a label selector, cleaned with CleanLive. Objects owned by
+// another object, such as the pods of a Deployment, and those the cluster
+// creates in every namespace are left out. Unless secrets is set, Secrets
+// are left out as well, since exporting them writes their data in plain
+// text, and are returned as withheld.
+func (c *Client) Export(ctx context.Context, namespace, selector string, secrets bool) (objects, withheld []*unstructured.Unstructured, err error) {
+	lists, err := c.clientset.Discovery().ServerPreferredNamespacedResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, nil, fmt.Errorf("failed to discover the resources of the cluster: %w", err)
+	}
+
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, res := range list.APIResources {
+			name := res.Name
+			if gv.Group != "" {
+				name += "." + gv.Group
+			}
+			if exportSkipped[name] || strings.Contains(res.Name, "/") || !hasVerbs(res.Verbs, "list", "get", "create") {
+				continue
+			}
+			items, err := c.dynamic.Resource(gv.WithResource(res.Name)).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to list %s: %w", name, err)
+			}
+			exported, secretsLeft := exportItems(items.Items, secrets)
+			objects = append(objects, exported...)
+			withheld = append(withheld, secretsLeft...)
+		}
+	}
+
+	sortObjects(objects)
+	sortObjects(withheld)
+	return objects, withheld, nil
+}
+
+// exportItems cleans the listed items which are exported, returning apart
+// the Secrets left out when secrets is not set
+func exportItems(items []unstructured.Unstructured, secrets bool) (objects, withheld []*unstructured.Unstructured) {
+	for i := range items {
+		obj := &items[i]
+		if len(obj.GetOwnerReferences()) > 0 || clusterCreated(obj) {
+			continue
+		}
+		if obj.GetKind() == "Secret" && !secrets {
+			withheld = append(withheld, obj)
+			continue
+		}
+		CleanLive(obj)
+		objects = append(objects, obj)
+	}
+	return objects, withheld
+}
+
+func sortObjects(objects []*unstructured.Unstructured) {
+	sort.Slice(objects, func(i, j int) bool {
+		if objects[i].GetKind() != objects[j].GetKind() {
+			return objects[i].GetKind() < objects[j].GetKind()
+		}
+		return objects[i].GetName() < objects[j].GetName()
+	})
+}
+
+func hasVerbs(verbs metav1.Verbs, want ...string) bool {
+	for _, w := range want {
+		found := false
+		for _, v := range verbs {
+			if v == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// clusterCreated reports objects the cluster creates in every namespace
+func clusterCreated(obj *unstructured.Unstructured) bool {
+	switch obj.GetKind() {
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "ServiceAccount":
+		return obj.GetName() == "default"
+	case "Secret":
+		t, _, _ := unstructured.NestedString(obj.Object, "type")
+		return t == "kubernetes.io/service-account-token" || strings.HasPrefix(t, "helm.sh/")
+	}
+	return false
+}
+
+// CleanLive removes from a live object the fields populated by the server:
+// those removeFields strips when comparing, managedFields and the
+// annotations of kubectl and controllers, and the fields holding the
+// default the server would set, so that the object reads like a manifest
+// which applies without changes.
+func CleanLive(obj *unstructured.Unstructured) {
+	removeFields(obj)
+	for _, f := range []string{"managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", f)
+	}
+
+	annotations := obj.GetAnnotations()
+	for k := range annotations {
+		for _, prefix := range exportedAnnotations {
+			if strings.HasPrefix(k, prefix) {
+				delete(annotations, k)
+			}
+		}
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+
+	kind := obj.GetKind()
+	for _, d := range kindDefaults[kind] {
+		removeDefault(obj.Object, splitPath(d.path), d.value)
+	}
+	if path, ok := podSpecPaths[kind]; ok {
+		cleanPodSpec(obj.Object, path)
+	}
+
+	switch kind {
+	case "Namespace":
+		unstructured.RemoveNestedField(obj.Object, "spec")
+		labels := obj.GetLabels()
+		delete(labels, "kubernetes.io/metadata.name")
+		if len(labels) == 0 {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "labels")
+		} else {
+			obj.SetLabels(labels)
+		}
+	case "Service":
+		// allocated addresses, unless the service is headless
+		if ip, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); ip != "None" {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+		unstructured.RemoveNestedField(obj.Object, "spec", "ipFamilies")
+		ports, _, _ := unstructured.NestedSlice(obj.Object, "spec", "ports")
+		for _, p := range ports {
+			if port, ok := p.(map[string]interface{}); ok && jsonEqual(port["targetPort"], port["port"]) {
+				delete(port, "targetPort")
+			}
+		}
+		if len(ports) > 0 {
+			unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+		}
+	case "Job":
+		// the selector and labels generated for the job's pods
+		if manual, _, _ := unstructured.NestedBool(obj.Object, "spec", "manualSelector"); !manual {
+			unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+			for _, l := range []string{"controller-uid", "job-name", "batch.kubernetes.io/controller-uid", "batch.kubernetes.io/job-name"} {
+				unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "labels", l)
+			}
+		}
+	case "StatefulSet":
+		templates, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates")
+		for _, t := range templates {
+			if t, ok := t.(map[string]interface{}); ok {
+				delete(t, "status")
+				removeDefault(t, []string{"metadata", "creationTimestamp"}, nil)
+			}
+		}
+		if len(templates) > 0 {
+			unstructured.SetNestedSlice(obj.Object, templates, "spec", "volumeClaimTemplates")
+		}
+	}
+}
+
+// cleanPodSpec removes the defaults of the PodSpec at path, and the empty
+// creation timestamps of the templates above it
+func cleanPodSpec(obj map[string]interface{}, path string) {
+	sels := splitPath(path)
+	for i := range sels {
+		if sels[i] == "spec" && i > 0 {
+			removeDefault(obj, append(append([]string{}, sels[:i]...), "metadata", "creationTimestamp"), nil)
+		}
+	}
+
+	for _, d := range podSpecDefaults {
+		removeDefault(obj, append(append([]string{}, sels...), splitPath(d.path)...), d.value)
+	}
+	for _, list := range podSpecContainers {
+		for _, d := range containerDefaults {
+			removeDefault(obj, append(append([]string{}, sels...), append([]string{list, "*"}, splitPath(d.path)...)...), d.value)
+		}
+		containers, _, _ := unstructured.NestedSlice(obj, append(append([]string{}, sels...), list)...)
+		for _, c := range containers {
+			if c, ok := c.(map[string]interface{}); ok {
+				image, _ := c["image"].(string)
+				removeDefault(c, []string{"imagePullPolicy"}, defaultPullPolicy(image))
+			}
+		}
+		if len(containers) > 0 {
+			unstructured.SetNestedSlice(obj, containers, append(append([]string{}, sels...), list)...)
+		}
+	}
+}
+
+// defaultPullPolicy is the imagePullPolicy set for an image
+func defaultPullPolicy(image string) string {
+	if strings.Contains(image, "@") {
+		return "IfNotPresent"
+	}
+	if repo := imageRepo(image); repo == image || strings.HasSuffix(image, ":latest") {
+		return "Always"
+	}
+	return "IfNotPresent"
+}
+
+// removeDefault removes the field at path when it holds value, * selecting
+// every element of a list
+func removeDefault(node interface{}, path []string, value interface{}) {
+	if len(path) == 0 {
+		return
+	}
+	key, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[key]
+		if !ok {
+			return
+		}
+		if len(rest) == 0 {
+			if jsonEqual(v, value) {
+				delete(n, key)
+			}
+			return
+		}
+		removeDefault(v, rest, value)
+	case []interface{}:
+		if key != "*" {
+			return
+		}
+		for _, e := range n {
+			removeDefault(e, rest, value)
+		}
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	return err == nil && string(x) == string(y)
+}
@@ -0,0 +1,174 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// liveDeployment is a Deployment as read from a cluster after kubectl apply
+const liveDeployment = `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {
+    "name": "web",
+    "namespace": "shop",
+    "labels": {"app": "web"},
+    "annotations": {
+      "deployment.kubernetes.io/revision": "3",
+      "kubectl.kubernetes.io/last-applied-configuration": "{}",
+      "team": "shop"
+    },
+    "uid": "6f1c", "resourceVersion": "8812", "generation": 3,
+    "creationTimestamp": "2024-05-01T10:00:00Z",
+    "managedFields": [{"manager": "kubectl-client-side-apply"}]
+  },
+  "spec": {
+    "replicas": 2,
+    "progressDeadlineSeconds": 600,
+    "revisionHistoryLimit": 5,
+    "selector": {"matchLabels": {"app": "web"}},
+    "strategy": {"type": "RollingUpdate", "rollingUpdate": {"maxSurge": "25%", "maxUnavailable": "25%"}},
+    "template": {
+      "metadata": {"creationTimestamp": null, "labels": {"app": "web"}},
+      "spec": {
+        "containers": [{
+          "name": "web",
+          "image": "nginx:1.25",
+          "imagePullPolicy": "IfNotPresent",
+          "ports": [{"containerPort": 80, "protocol": "TCP"}],
+          "resources": {},
+          "terminationMessagePath": "/dev/termination-log",
+          "terminationMessagePolicy": "File"
+        }, {
+          "name": "agent",
+          "image": "agent",
+          "imagePullPolicy": "IfNotPresent",
+          "resources": {"limits": {"cpu": "100m"}}
+        }],
+        "dnsPolicy": "ClusterFirst",
+        "restartPolicy": "Always",
+        "schedulerName": "default-scheduler",
+        "securityContext": {},
+        "terminationGracePeriodSeconds": 60
+      }
+    }
+  },
+  "status": {"replicas": 2}
+}`
+
+const liveService = `{
+  "apiVersion": "v1",
+  "kind": "Service",
+  "metadata": {"name": "web", "namespace": "shop", "uid": "a1"},
+  "spec": {
+    "clusterIP": "10.0.12.7",
+    "clusterIPs": ["10.0.12.7"],
+    "internalTrafficPolicy": "Cluster",
+    "ipFamilies": ["IPv4"],
+    "ipFamilyPolicy": "SingleStack",
+    "ports": [{"name": "http", "port": 80, "protocol": "TCP", "targetPort": 80}, {"port": 9090, "protocol": "UDP", "targetPort": "metrics"}],
+    "selector": {"app": "web"},
+    "sessionAffinity": "None",
+    "type": "ClusterIP"
+  },
+  "status": {"loadBalancer": {}}
+}`
+
+func cleaned(t *testing.T, live string) (cleaned, original map[string]interface{}) {
+	t.Helper()
+	obj := &unstructured.Unstructured{}
+	require.NoError(t, obj.UnmarshalJSON([]byte(live)))
+	original = obj.DeepCopy().Object
+	CleanLive(obj)
+	return obj.Object, original
+}
+
+func TestCleanLiveDeployment(t *testing.T) {
+	obj, live := cleaned(t, liveDeployment)
+
+	var expected map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"name": "web", "namespace": "shop", "labels": {"app": "web"}, "annotations": {"team": "shop"}},
+  "spec": {
+    "replicas": 2,
+    "revisionHistoryLimit": 5,
+    "selector": {"matchLabels": {"app": "web"}},
+    "template": {
+      "metadata": {"labels": {"app": "web"}},
+      "spec": {
+        "containers": [
+          {"name": "web", "image": "nginx:1.25", "ports": [{"containerPort": 80}]},
+          {"name": "agent", "image": "agent", "imagePullPolicy": "IfNotPresent", "resources": {"limits": {"cpu": "100m"}}}
+        ],
+        "terminationGracePeriodSeconds": 60
+      }
+    }
+  }
+}`), &expected))
+	assert.True(t, jsonEqual(expected, obj), "cleaned: %v", obj)
+
+	// applying the cleaned object changes nothing
+	assert.Empty(t, driftedFields(obj, live, ""))
+}
+
+func TestCleanLiveService(t *testing.T) {
+	obj, live := cleaned(t, liveService)
+
+	assert.Equal(t, map[string]interface{}{
+		"ports": []interface{}{
+			map[string]interface{}{"name": "http", "port": int64(80)},
+			map[string]interface{}{"port": int64(9090), "protocol": "UDP", "targetPort": "metrics"},
+		},
+		"selector": map[string]interface{}{"app": "web"},
+	}, obj["spec"])
+	assert.Empty(t, driftedFields(obj, live, ""))
+}
+
+func TestExportItems(t *testing.T) {
+	var items []unstructured.Unstructured
+	for _, live := range []string{
+		liveService,
+		`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "db", "namespace": "shop"}, "type": "Opaque", "data": {"password": "aHVudGVyMg=="}}`,
+		`{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "sa-token", "namespace": "shop"}, "type": "kubernetes.io/service-account-token"}`,
+		`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "kube-root-ca.crt", "namespace": "shop"}}`,
+		`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web-1", "namespace": "shop", "ownerReferences": [{"kind": "ReplicaSet", "name": "web"}]}}`,
+	} {
+		obj := unstructured.Unstructured{}
+		require.NoError(t, obj.UnmarshalJSON([]byte(live)))
+		items = append(items, obj)
+	}
+	names := func(objects []*unstructured.Unstructured) []string {
+		var names []string
+		for _, obj := range objects {
+			names = append(names, obj.GetKind()+"/"+obj.GetName())
+		}
+		return names
+	}
+
+	objects, withheld := exportItems(items, false)
+	assert.Equal(t, []string{"Service/web"}, names(objects))
+	assert.Equal(t, []string{"Secret/db"}, names(withheld))
+
+	objects, withheld = exportItems(items, true)
+	assert.Equal(t, []string{"Service/web", "Secret/db"}, names(objects))
+	assert.Empty(t, withheld)
+}
@@ -0,0 +1,199 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// defaultFieldManager is used when no field manager is set
+const defaultFieldManager = "mantis"
+
+// legacyFieldManager applied the objects of all K8s tasks before tasks had
+// a field manager of their own
+const legacyFieldManager = "client"
+
+// ApplyOptions controls the server-side apply of a K8s task
+type ApplyOptions struct {
+	// FieldManager owns the fields applied, mantis/<flow>/<task> for tasks
+	FieldManager string
+
+	// ForceConflicts takes over the fields owned by other managers
+	ForceConflicts bool
+
+	// IgnoreFields are paths such as spec.replicas removed from the manifest,
+	// so that they are neither applied nor compared
+	IgnoreFields []string
+}
+
+func (o ApplyOptions) fieldManager() string {
+	if o.FieldManager == "" {
+		return defaultFieldManager
+	}
+	return o.FieldManager
+}
+
+// removePaths removes the fields at paths from obj. A path is a list of keys
+// separated by dots, with [n] selecting an element of a list, such as
+// spec.template.spec.containers[0].image, and ["key"] a key holding dots.
+func removePaths(obj map[string]interface{}, paths []string) {
+	for _, path := range paths {
+		removePath(obj, splitPath(path))
+	}
+}
+
+func removePath(node interface{}, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	key, rest := path[0], path[1:]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			delete(n, key)
+			return
+		}
+		removePath(n[key], rest)
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(n) {
+			return
+		}
+		// elements of a list can not be removed without shifting the
+		// others, only fields within them can
+		removePath(n[i], rest)
+	}
+}
+
+// splitPath splits a path into its keys. Keys holding dots, such as those of
+// labels and annotations, are quoted within brackets:
+// metadata.annotations["kubectl.kubernetes.io/restartedAt"]
+func splitPath(path string) []string {
+	var out []string
+	for path != "" {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+		case strings.HasPrefix(path, `["`):
+			end := strings.Index(path[2:], `"]`)
+			if end < 0 {
+				return append(out, path)
+			}
+			out = append(out, path[2:2+end])
+			path = path[2+end+2:]
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return append(out, path)
+			}
+			out = append(out, path[1:end])
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			out = append(out, path[:end])
+			path = path[end:]
+		}
+	}
+	return out
+}
+
+// conflicts returns the fields another manager owns, from the error of an
+// apply without force, and nil when err is not a conflict
+func conflicts(err error) []string {
+	if !k8serrors.IsConflict(err) {
+		return nil
+	}
+	status, ok := err.(k8serrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return []string{err.Error()}
+	}
+	var out []string
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s: %s", strings.TrimPrefix(cause.Field, "."), cause.Message))
+	}
+	if len(out) == 0 {
+		out = append(out, err.Error())
+	}
+	return out
+}
+
+// adoptLegacyFields hands the fields of an object applied by the legacy
+// field manager over to manager, before its first apply under manager.
+// Applying over them would otherwise conflict, and the legacy manager would
+// keep owning fields the task no longer sets, which are then never removed.
+func adoptLegacyFields(ctx context.Context, resourceClient dynamic.ResourceInterface, name, manager string) error {
+	live, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	patch := legacyFieldsPatch(live.GetManagedFields(), manager)
+	if patch == nil {
+		return nil
+	}
+	_, err = resourceClient.Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// legacyFieldsPatch returns the JSON patch renaming the apply entry of the
+// legacy field manager to manager, as kubectl does when it upgrades objects
+// applied client-side, and nil when there is nothing to hand over
+func legacyFieldsPatch(entries []metav1.ManagedFieldsEntry, manager string) []byte {
+	if manager == legacyFieldManager {
+		return nil
+	}
+	legacy := -1
+	for i, e := range entries {
+		if e.Operation != metav1.ManagedFieldsOperationApply {
+			continue
+		}
+		switch e.Manager {
+		case manager:
+			return nil
+		case legacyFieldManager:
+			legacy = i
+		}
+	}
+	if legacy < 0 {
+		return nil
+	}
+	path := fmt.Sprintf("/metadata/managedFields/%d/manager", legacy)
+	patch, _ := json.Marshal([]map[string]string{
+		{"op": "test", "path": path, "value": legacyFieldManager},
+		{"op": "replace", "path": path, "value": manager},
+	})
+	return patch
+}
+
+// isLegacyConflict tells whether a field listed by conflicts is owned by
+// the legacy field manager, which the apply takes it over from
+func isLegacyConflict(field string) bool {
+	return strings.Contains(field, fmt.Sprintf("conflict with %q", legacyFieldManager))
+}
@@ -0,0 +1,154 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := map[string][]string{
+		"spec.replicas":                          {"spec", "replicas"},
+		"spec.template.spec.containers[0].image": {"spec", "template", "spec", "containers", "0", "image"},
+		"data[1][2]":                             {"data", "1", "2"},
+		`metadata.annotations["app.io/name"]`:    {"metadata", "annotations", "app.io/name"},
+		`metadata.labels["a.b"].x`:               {"metadata", "labels", "a.b", "x"},
+		`metadata.labels["a.b`:                   {"metadata", "labels", `["a.b`},
+		"spec[0":                                 {"spec", "[0"},
+		"":                                       nil,
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, splitPath(path), path)
+	}
+}
+
+func TestRemovePaths(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"kubectl.kubernetes.io/restartedAt": "now", "team": "infra"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "web:1"},
+						map[string]interface{}{"name": "sidecar", "image": "proxy:1"},
+					},
+				},
+			},
+		},
+	}
+	removePaths(obj, []string{
+		"spec.replicas",
+		"spec.template.spec.containers[1].image",
+		"spec.template.spec.containers[5].image",
+		"spec.template.spec.containers[x].image",
+		"spec.template.spec.containers[0]",
+		"status.replicas",
+		"spec.replicas.count",
+		`metadata.annotations["kubectl.kubernetes.io/restartedAt"]`,
+	})
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{"team": "infra"},
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "web:1"},
+						map[string]interface{}{"name": "sidecar"},
+					},
+				},
+			},
+		},
+	}, obj)
+}
+
+func TestConflicts(t *testing.T) {
+	assert.Nil(t, conflicts(nil))
+	assert.Nil(t, conflicts(assert.AnError))
+
+	err := k8serrors.NewApplyConflict([]metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".spec.replicas", Message: `conflict with "hpa" using apps/v1`},
+		{Type: metav1.CauseTypeFieldValueInvalid, Field: ".spec", Message: "ignored"},
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.x", Message: `conflict with "client" using v1`},
+	}, "Apply failed with 2 conflicts")
+	assert.Equal(t, []string{
+		`spec.replicas: conflict with "hpa" using apps/v1`,
+		`data.x: conflict with "client" using v1`,
+	}, conflicts(err))
+
+	err = k8serrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "app", assert.AnError)
+	assert.Equal(t, []string{err.Error()}, conflicts(err))
+
+	assert.True(t, isLegacyConflict(`data.x: conflict with "client" using v1`))
+	assert.False(t, isLegacyConflict(`spec.replicas: conflict with "hpa" using apps/v1`))
+}
+
+func TestLegacyFieldsPatch(t *testing.T) {
+	apply := func(manager string) metav1.ManagedFieldsEntry {
+		return metav1.ManagedFieldsEntry{Manager: manager, Operation: metav1.ManagedFieldsOperationApply}
+	}
+	update := metav1.ManagedFieldsEntry{Manager: "kubectl-edit", Operation: metav1.ManagedFieldsOperationUpdate}
+
+	assert.JSONEq(t, `[
+		{"op": "test", "path": "/metadata/managedFields/1/manager", "value": "client"},
+		{"op": "replace", "path": "/metadata/managedFields/1/manager", "value": "mantis/f/app"}
+	]`, string(legacyFieldsPatch([]metav1.ManagedFieldsEntry{update, apply("client")}, "mantis/f/app")))
+
+	assert.Nil(t, legacyFieldsPatch([]metav1.ManagedFieldsEntry{apply("client"), apply("mantis/f/app")}, "mantis/f/app"), "already applied under the new manager")
+	assert.Nil(t, legacyFieldsPatch([]metav1.ManagedFieldsEntry{apply("client")}, "client"))
+	assert.Nil(t, legacyFieldsPatch([]metav1.ManagedFieldsEntry{update, apply("helm")}, "mantis/f/app"))
+	assert.Nil(t, legacyFieldsPatch([]metav1.ManagedFieldsEntry{{Manager: "client", Operation: metav1.ManagedFieldsOperationUpdate}}, "mantis/f/app"))
+}
+
+func TestAdoptLegacyFields(t *testing.T) {
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+		"data":       map[string]interface{}{"x": "1"},
+	}}
+	cm.SetManagedFields([]metav1.ManagedFieldsEntry{{
+		Manager:    "client",
+		Operation:  metav1.ManagedFieldsOperationApply,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:x":{}}}`)},
+	}})
+	client, _ := newFakeClient(t, cm)
+	resourceClient, err := client.resourceClient(cm)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, adoptLegacyFields(ctx, resourceClient, "app", "mantis/f/app"))
+	live, err := resourceClient.Get(ctx, "app", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, live.GetManagedFields(), 1)
+	assert.Equal(t, "mantis/f/app", live.GetManagedFields()[0].Manager)
+	assert.JSONEq(t, `{"f:data":{"f:x":{}}}`, string(live.GetManagedFields()[0].FieldsV1.Raw))
+
+	// once handed over, and for objects which do not exist yet, it is a no-op
+	require.NoError(t, adoptLegacyFields(ctx, resourceClient, "app", "mantis/f/app"))
+	require.NoError(t, adoptLegacyFields(ctx, resourceClient, "missing", "mantis/f/app"))
+}
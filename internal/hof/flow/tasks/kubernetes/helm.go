@@ -0,0 +1,392 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/kylelemons/godebug/diff"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// helmBinary is the helm CLI releases are managed with
+var helmBinary = "helm"
+
+// HelmTask installs, upgrades or uninstalls a Helm release, for charts
+// consumed as they are rather than converted to CUE
+type HelmTask struct{}
+
+func NewHelmTask(val cue.Value) (hofcontext.Runner, error) {
+	return &HelmTask{}, nil
+}
+
+// HelmRelease is the config of a Helm task
+type HelmRelease struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+
+	// Chart is a local path, an oci:// reference, or a chart name of Repo
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	Repo    string `json:"repo,omitempty"`
+
+	Values map[string]interface{} `json:"values,omitempty"`
+
+	CreateNamespace bool `json:"create_namespace,omitempty"`
+
+	// Timeout of the operations waiting for resources, such as 5m
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// HelmOut is the out of a Helm task
+type HelmOut struct {
+	Release    string `json:"release"`
+	Namespace  string `json:"namespace"`
+	Revision   int    `json:"revision"`
+	Status     string `json:"status"`
+	Chart      string `json:"chart"`
+	Version    string `json:"version"`
+	AppVersion string `json:"app_version,omitempty"`
+	Notes      string `json:"notes,omitempty"`
+}
+
+// helmReleaseJSON holds the fields read from helm's JSON output of a release
+type helmReleaseJSON struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Manifest  string `json:"manifest"`
+	Info      struct {
+		Status string `json:"status"`
+		Notes  string `json:"notes"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+func (r helmReleaseJSON) out() HelmOut {
+	return HelmOut{
+		Release:    r.Name,
+		Namespace:  r.Namespace,
+		Revision:   r.Version,
+		Status:     r.Info.Status,
+		Chart:      r.Chart.Metadata.Name,
+		Version:    r.Chart.Metadata.Version,
+		AppVersion: r.Chart.Metadata.AppVersion,
+		Notes:      r.Info.Notes,
+	}
+}
+
+func (t *HelmTask) Run(ctx *hofcontext.Context) (any, error) {
+	v := ctx.Value
+
+	// Nothing to do for init for Helm, charts are fetched when used
+	if ctx.Init {
+		return v, nil
+	}
+
+	rel, err := helmRelease(v.LookupPath(cue.ParsePath("config")))
+	if err != nil {
+		return nil, err
+	}
+
+	var cluster Cluster
+	if cv := v.LookupPath(cue.ParsePath(mantis.MantisTaskCluster)); cv.Exists() {
+		if err := cv.Decode(&cluster); err != nil {
+			return nil, fmt.Errorf("invalid cluster: %v", err)
+		}
+	}
+	wait, _ := v.LookupPath(cue.ParsePath(mantis.MantisTaskWait)).Bool()
+	atomic, _ := v.LookupPath(cue.ParsePath(mantis.MantisTaskRollback)).Bool()
+
+	if ctx.Plan {
+		err := helmPlan(ctx.GoContext, cluster, rel)
+		if err != nil {
+			return nil, fmt.Errorf("plan failed. Check if Kubernetes cluster is accessible: %v", err)
+		}
+
+	} else if ctx.Apply {
+		args := []string{}
+		if wait {
+			args = append(args, "--wait")
+		}
+		// helm rolls back a failed upgrade, or uninstalls a failed install
+		if atomic {
+			args = append(args, "--atomic")
+		}
+		release, err := helmUpgrade(ctx.GoContext, cluster, rel, args...)
+		if err != nil {
+			return nil, fmt.Errorf("apply failed. Check if Kubernetes cluster is accessible: %v", err)
+		}
+		fmt.Printf("Release %s is %s at revision %d\n", release.Name, release.Info.Status, release.Version)
+		return v.FillPath(cue.ParsePath(mantis.MantisTaskOuts), release.out()), nil
+
+	} else if ctx.Destroy {
+		args := []string{"uninstall", rel.Release, "--namespace", rel.Namespace}
+		if wait {
+			args = append(args, "--wait")
+		}
+		if rel.Timeout != "" {
+			args = append(args, "--timeout", rel.Timeout)
+		}
+		_, err := helmCommand(ctx.GoContext, cluster, args...)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return nil, fmt.Errorf("destroy failed. Check if Kubernetes cluster is accessible: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("unknown command. Need to use one of plan/apply/destroy")
+	}
+	fmt.Println("Operation completed successfully")
+	return v, nil
+}
+
+// helmRelease decodes the config of a Helm task
+func helmRelease(config cue.Value) (HelmRelease, error) {
+	var rel HelmRelease
+	if err := config.Decode(&rel); err != nil {
+		return rel, fmt.Errorf("invalid Helm release config: %v", err)
+	}
+	if rel.Release == "" || rel.Chart == "" {
+		return rel, fmt.Errorf("a Helm task needs a release and a chart")
+	}
+	if rel.Namespace == "" {
+		rel.Namespace = "default"
+	}
+	return rel, nil
+}
+
+// HelmTemplate renders the manifests of the release of a Helm task config
+// with helm template, without contacting a cluster
+func HelmTemplate(ctx context.Context, config cue.Value) ([]byte, error) {
+	rel, err := helmRelease(config)
+	if err != nil {
+		return nil, err
+	}
+	values, err := writeHelmValues(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(values)
+
+	args := []string{
+		"template", rel.Release, rel.Chart,
+		"--namespace", rel.Namespace,
+		"--values", values,
+	}
+	return helmCommand(ctx, Cluster{}, append(args, rel.chartFlags()...)...)
+}
+
+// writeHelmValues writes the values of a release to a temporary file,
+// which the caller removes
+func writeHelmValues(rel HelmRelease) (string, error) {
+	values, err := os.CreateTemp("", "mantis-helm-values-*.json")
+	if err != nil {
+		return "", err
+	}
+	vals := rel.Values
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	if err := json.NewEncoder(values).Encode(vals); err != nil {
+		values.Close()
+		os.Remove(values.Name())
+		return "", err
+	}
+	values.Close()
+	return values.Name(), nil
+}
+
+// chartFlags returns the flags of helm selecting the chart of a release
+func (rel HelmRelease) chartFlags() []string {
+	var flags []string
+	if rel.Version != "" {
+		flags = append(flags, "--version", rel.Version)
+	}
+	if rel.Repo != "" {
+		flags = append(flags, "--repo", rel.Repo)
+	}
+	return flags
+}
+
+// helmPlan prints the diff of the manifest of the current release and the
+// one an upgrade would render, from a dry run against the cluster
+func helmPlan(ctx context.Context, cluster Cluster, rel HelmRelease) error {
+	current, err := helmCommand(ctx, cluster, "get", "manifest", rel.Release, "--namespace", rel.Namespace)
+	installed := true
+	if err != nil {
+		if !strings.Contains(err.Error(), "not found") {
+			return err
+		}
+		installed = false
+	}
+
+	release, err := helmUpgrade(ctx, cluster, rel, "--dry-run")
+	if err != nil {
+		return err
+	}
+
+	if !installed {
+		fmt.Printf("Release %s does not exist. Chart %s %s will be installed.\n", rel.Release, release.Chart.Metadata.Name, release.Chart.Metadata.Version)
+	}
+	printManifestDiff(string(current), release.Manifest)
+	return nil
+}
+
+// printManifestDiff prints the changed lines of a manifest, under the
+// # Source: comment helm heads each rendered template with
+func printManifestDiff(current, next string) {
+	if strings.TrimSpace(current) == strings.TrimSpace(next) {
+		fmt.Printf("No changes detected.\n")
+		return
+	}
+	source, shown := "", ""
+	for _, line := range strings.Split(diff.Diff(current, next), "\n") {
+		text := strings.TrimLeft(line, " +-")
+		if strings.HasPrefix(text, "# Source:") {
+			source = text
+		}
+		switch {
+		case strings.HasPrefix(line, "+"), strings.HasPrefix(line, "-"):
+			if source != shown {
+				fmt.Println(source)
+				shown = source
+			}
+			if strings.HasPrefix(line, "+") {
+				fmt.Printf("\033[32m%s\033[0m\n", line) // Green for additions
+			} else {
+				fmt.Printf("\033[31m%s\033[0m\n", line) // Red for deletions
+			}
+		}
+	}
+}
+
+// helmUpgrade installs or upgrades a release with extra args, such as
+// --dry-run, and returns the release
+func helmUpgrade(ctx context.Context, cluster Cluster, rel HelmRelease, extra ...string) (*helmReleaseJSON, error) {
+	values, err := writeHelmValues(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(values)
+
+	args := []string{
+		"upgrade", rel.Release, rel.Chart, "--install",
+		"--namespace", rel.Namespace,
+		"--values", values,
+		"--output", "json",
+	}
+	args = append(args, rel.chartFlags()...)
+	if rel.CreateNamespace {
+		args = append(args, "--create-namespace")
+	}
+	if rel.Timeout != "" {
+		args = append(args, "--timeout", rel.Timeout)
+	}
+	out, err := helmCommand(ctx, cluster, append(args, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var release helmReleaseJSON
+	if err := json.Unmarshal(out, &release); err != nil {
+		return nil, fmt.Errorf("unexpected output of helm upgrade: %v", err)
+	}
+	return &release, nil
+}
+
+// helmCommand runs helm against a cluster and returns its output, or its
+// error message as error
+func helmCommand(ctx context.Context, cluster Cluster, args ...string) ([]byte, error) {
+	flags, cleanup, err := helmClusterFlags(cluster)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, helmBinary, append(args, flags...)...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("helm %s: %s", args[0], strings.TrimPrefix(msg, "Error: "))
+		}
+		return nil, fmt.Errorf("helm %s: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// helmClusterFlags returns the flags of helm selecting a cluster. A cluster
+// given by its server is written, with its CA and token, to a kubeconfig
+// removed by cleanup, so that the token is not visible in helm's arguments.
+func helmClusterFlags(cluster Cluster) (flags []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if cluster.Server == "" {
+		if cluster.Kubeconfig != "" {
+			flags = append(flags, "--kubeconfig", cluster.Kubeconfig)
+		}
+		if cluster.Context != "" {
+			flags = append(flags, "--kube-context", cluster.Context)
+		}
+		return flags, cleanup, nil
+	}
+
+	config, err := getConfig(cluster)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	kubeconfig := clientcmdapi.NewConfig()
+	kubeconfig.Clusters["mantis"] = &clientcmdapi.Cluster{
+		Server:                   config.Host,
+		CertificateAuthorityData: config.TLSClientConfig.CAData,
+		InsecureSkipTLSVerify:    config.TLSClientConfig.Insecure,
+	}
+	kubeconfig.AuthInfos["mantis"] = &clientcmdapi.AuthInfo{Token: config.BearerToken}
+	kubeconfig.Contexts["mantis"] = &clientcmdapi.Context{Cluster: "mantis", AuthInfo: "mantis"}
+	kubeconfig.CurrentContext = "mantis"
+	data, err := clientcmd.Write(*kubeconfig)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	// CreateTemp creates the file readable by its owner only
+	f, err := os.CreateTemp("", "mantis-helm-kubeconfig-*.yaml")
+	if err != nil {
+		return nil, cleanup, err
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	return []string{"--kubeconfig", f.Name()}, cleanup, nil
+}
@@ -0,0 +1,305 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// stubHelm replaces helm with a script logging its arguments to log, which
+// answers get manifest with the file manifest, not found without it,
+// upgrade with the file release.json and template with a ConfigMap, copying
+// their values to values.json
+func stubHelm(t *testing.T) (dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	script := `#!/bin/sh
+dir="` + dir + `"
+echo "$@" >> "$dir/log"
+case "$1" in
+get|uninstall)
+	if [ ! -f "$dir/manifest" ]; then
+		echo "Error: release: not found" >&2
+		exit 1
+	fi
+	[ "$1" = get ] && cat "$dir/manifest"
+	;;
+upgrade|template)
+	cmd="$1"
+	while [ $# -gt 0 ]; do
+		[ "$1" = --values ] && cp "$2" "$dir/values.json"
+		shift
+	done
+	if [ "$cmd" = upgrade ]; then
+		cat "$dir/release.json"
+	else
+		printf 'kind: ConfigMap\nmetadata:\n  name: web\n'
+	fi
+	;;
+esac
+exit 0
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helm"), []byte(script), 0755))
+	binary := helmBinary
+	helmBinary = filepath.Join(dir, "helm")
+	t.Cleanup(func() { helmBinary = binary })
+
+	release := `{
+  "name": "web", "namespace": "app", "version": 2,
+  "manifest": "---\n# Source: web/templates/cm.yaml\nkind: ConfigMap\ndata:\n  color: blue\n",
+  "info": {"status": "deployed", "notes": "visit web"},
+  "chart": {"metadata": {"name": "web", "version": "1.2.0", "appVersion": "2.0"}}
+}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "release.json"), []byte(release), 0644))
+	return dir
+}
+
+// helmCalls returns the arguments of each helm call of the stub
+func helmCalls(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "log"))
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// captureStdout returns what f prints
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func runHelmTask(t *testing.T, command string) (cue.Value, string, error) {
+	t.Helper()
+	ctx := hofcontext.New()
+	ctx.FlowName = "f"
+	ctx.BaseTask = &task.BaseTask{ID: "f.web"}
+	ctx.GoContext = context.Background()
+	switch command {
+	case "plan":
+		ctx.Plan = true
+	case "apply":
+		ctx.Apply = true
+	case "destroy":
+		ctx.Destroy = true
+	}
+	ctx.Value = cuecontext.New().CompileString(`
+wait: true
+rollback_on_failure: true
+config: {
+	release:   "web"
+	namespace: "app"
+	chart:     "./charts/web"
+	version:   "1.2.0"
+	values: replicas: 2
+}
+`)
+	require.NoError(t, ctx.Value.Err())
+
+	var v cue.Value
+	var err error
+	out := captureStdout(t, func() {
+		var res any
+		res, err = (&HelmTask{}).Run(ctx)
+		if err == nil {
+			v = res.(cue.Value)
+		}
+	})
+	return v, out, err
+}
+
+func TestPrintManifestDiff(t *testing.T) {
+	current := `---
+# Source: web/templates/cm.yaml
+kind: ConfigMap
+data:
+  color: blue
+---
+# Source: web/templates/svc.yaml
+kind: Service
+`
+	assert.Equal(t, "No changes detected.\n", captureStdout(t, func() { printManifestDiff(current, current+"\n") }))
+
+	next := strings.Replace(current, "color: blue", "color: green", 1)
+	assert.Equal(t, "# Source: web/templates/cm.yaml\n"+
+		"\033[31m-  color: blue\033[0m\n"+
+		"\033[32m+  color: green\033[0m\n", captureStdout(t, func() { printManifestDiff(current, next) }))
+}
+
+func TestHelmClusterFlags(t *testing.T) {
+	flags, cleanup, err := helmClusterFlags(Cluster{Kubeconfig: "/etc/kube.yaml", Context: "prod"})
+	require.NoError(t, err)
+	cleanup()
+	assert.Equal(t, []string{"--kubeconfig", "/etc/kube.yaml", "--kube-context", "prod"}, flags)
+
+	flags, cleanup, err = helmClusterFlags(Cluster{
+		Server: "https://10.0.0.1",
+		CA:     "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+		Token:  "s3cret",
+	})
+	require.NoError(t, err)
+	require.Len(t, flags, 2)
+	assert.Equal(t, "--kubeconfig", flags[0])
+	assert.NotContains(t, strings.Join(flags, " "), "s3cret")
+
+	info, err := os.Stat(flags[1])
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	config, err := clientcmd.LoadFromFile(flags[1])
+	require.NoError(t, err)
+	kubeContext := config.Contexts[config.CurrentContext]
+	require.NotNil(t, kubeContext)
+	assert.Equal(t, "https://10.0.0.1", config.Clusters[kubeContext.Cluster].Server)
+	assert.Contains(t, string(config.Clusters[kubeContext.Cluster].CertificateAuthorityData), "MIIB")
+	assert.Equal(t, "s3cret", config.AuthInfos[kubeContext.AuthInfo].Token)
+
+	cleanup()
+	_, err = os.Stat(flags[1])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHelmTaskApply(t *testing.T) {
+	dir := stubHelm(t)
+
+	v, out, err := runHelmTask(t, "apply")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Release web is deployed at revision 2")
+
+	calls := helmCalls(t, dir)
+	require.Len(t, calls, 1)
+	assert.True(t, strings.HasPrefix(calls[0], "upgrade web ./charts/web --install --namespace app --values "), calls[0])
+	assert.True(t, strings.HasSuffix(calls[0], " --output json --version 1.2.0 --wait --atomic"), calls[0])
+
+	values, err := os.ReadFile(filepath.Join(dir, "values.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"replicas": 2}`, string(values))
+
+	var rel HelmOut
+	require.NoError(t, v.LookupPath(cue.ParsePath(mantis.MantisTaskOuts)).Decode(&rel))
+	assert.Equal(t, HelmOut{
+		Release:    "web",
+		Namespace:  "app",
+		Revision:   2,
+		Status:     "deployed",
+		Chart:      "web",
+		Version:    "1.2.0",
+		AppVersion: "2.0",
+		Notes:      "visit web",
+	}, rel)
+}
+
+func TestHelmTaskPlan(t *testing.T) {
+	dir := stubHelm(t)
+
+	_, out, err := runHelmTask(t, "plan")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Release web does not exist. Chart web 1.2.0 will be installed.")
+	assert.Contains(t, out, "+  color: blue")
+
+	calls := helmCalls(t, dir)
+	require.Len(t, calls, 2)
+	assert.Equal(t, "get manifest web --namespace app", calls[0])
+	assert.True(t, strings.HasSuffix(calls[1], " --dry-run"), calls[1])
+	assert.NotContains(t, calls[1], "--atomic")
+
+	// an installed release with the same manifest has no changes
+	var release helmReleaseJSON
+	data, err := os.ReadFile(filepath.Join(dir, "release.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &release))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest"), []byte(release.Manifest), 0644))
+
+	_, out, err = runHelmTask(t, "plan")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "will be installed")
+	assert.Contains(t, out, "No changes detected.")
+}
+
+func TestHelmTaskDestroy(t *testing.T) {
+	dir := stubHelm(t)
+
+	// a release which is not installed is already destroyed
+	_, _, err := runHelmTask(t, "destroy")
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest"), nil, 0644))
+	_, out, err := runHelmTask(t, "destroy")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Operation completed successfully")
+	assert.Equal(t, []string{
+		"uninstall web --namespace app --wait",
+		"uninstall web --namespace app --wait",
+	}, helmCalls(t, dir))
+}
+
+func TestHelmTaskError(t *testing.T) {
+	dir := stubHelm(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helm"), []byte("#!/bin/sh\necho 'Error: chart not found' >&2\nexit 1\n"), 0755))
+
+	_, _, err := runHelmTask(t, "apply")
+	assert.EqualError(t, err, "apply failed. Check if Kubernetes cluster is accessible: helm upgrade: chart not found")
+}
+
+func TestHelmTemplate(t *testing.T) {
+	dir := stubHelm(t)
+
+	config := cuecontext.New().CompileString(`
+release:   "web"
+namespace: "app"
+chart:     "./charts/web"
+version:   "1.2.0"
+values: replicas: 2
+`)
+	manifest, err := HelmTemplate(context.Background(), config)
+	require.NoError(t, err)
+	assert.Equal(t, "kind: ConfigMap\nmetadata:\n  name: web\n", string(manifest))
+
+	calls := helmCalls(t, dir)
+	require.Len(t, calls, 1)
+	assert.True(t, strings.HasPrefix(calls[0], "template web ./charts/web --namespace app --values "), calls[0])
+	assert.True(t, strings.HasSuffix(calls[0], " --version 1.2.0"), "no cluster is selected: %s", calls[0])
+	values, err := os.ReadFile(filepath.Join(dir, "values.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"replicas": 2}`, string(values))
+
+	_, err = HelmTemplate(context.Background(), cuecontext.New().CompileString(`release: "web"`))
+	assert.ErrorContains(t, err, "needs a release and a chart")
+}
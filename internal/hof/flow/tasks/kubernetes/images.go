@@ -0,0 +1,213 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/repos/oci"
+)
+
+// podSpecContainers are the lists of containers of a PodSpec
+var podSpecContainers = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// ImageLock records the digest each image reference was pinned to, so that
+// later runs and renders pin the same digests even when a tag moves. Remove
+// an image from the file to pin it again.
+type ImageLock struct {
+	Images map[string]string `json:"images"`
+
+	path    string
+	mu      sync.Mutex
+	changed bool
+
+	// resolve returns the digest of an image, oci.Digest by default
+	resolve func(image string) (string, error)
+}
+
+var (
+	imageLocks   = map[string]*ImageLock{}
+	imageLocksMu sync.Mutex
+)
+
+// ImageLockFor returns the lock file at path, loaded once and shared by
+// the tasks of a run
+func ImageLockFor(path string) (*ImageLock, error) {
+	imageLocksMu.Lock()
+	defer imageLocksMu.Unlock()
+
+	if l, ok := imageLocks[path]; ok {
+		return l, nil
+	}
+	l, err := LoadImageLock(path)
+	if err != nil {
+		return nil, err
+	}
+	imageLocks[path] = l
+	return l, nil
+}
+
+// LoadImageLock reads the lock file at path, a missing file being empty
+func LoadImageLock(path string) (*ImageLock, error) {
+	l := &ImageLock{Images: map[string]string{}, path: path, resolve: oci.Digest}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("invalid image lock file %s: %v", path, err)
+	}
+	if l.Images == nil {
+		l.Images = map[string]string{}
+	}
+	return l, nil
+}
+
+// Save writes the lock file when images were pinned since it was loaded
+func (l *ImageLock) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(l.path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	l.changed = false
+	return nil
+}
+
+// Pin returns image as repo@digest, with the digest of the lock file or
+// else resolved from the registry and added to the lock file. Images
+// already referenced by digest are returned as is.
+func (l *ImageLock) Pin(image string) (string, error) {
+	if strings.Contains(image, "@") {
+		return image, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	digest, ok := l.Images[image]
+	if !ok {
+		var err error
+		if digest, err = l.resolve(image); err != nil {
+			return "", err
+		}
+		l.Images[image] = digest
+		l.changed = true
+	}
+	return imageRepo(image) + "@" + digest, nil
+}
+
+// PinImages replaces the image of every container of the PodSpecs found in
+// a manifest, whatever its kind, by the reference returned by pin. It
+// returns the pinned images by their original reference.
+func PinImages(manifest map[string]interface{}, pin func(image string) (string, error)) (map[string]string, error) {
+	pinned := map[string]string{}
+	if err := pinImages(manifest, pin, pinned); err != nil {
+		return nil, err
+	}
+	return pinned, nil
+}
+
+func pinImages(v interface{}, pin func(string) (string, error), pinned map[string]string) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, key := range podSpecContainers {
+			containers, _ := v[key].([]interface{})
+			for _, c := range containers {
+				container, _ := c.(map[string]interface{})
+				image, ok := container["image"].(string)
+				if !ok {
+					continue
+				}
+				ref, err := pin(image)
+				if err != nil {
+					return err
+				}
+				container["image"] = ref
+				if ref != image {
+					pinned[image] = ref
+				}
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := pinImages(v[k], pin, pinned); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := pinImages(e, pin, pinned); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// imageRepo strips the tag of an image reference, the port of a registry
+// host being no tag
+func imageRepo(image string) string {
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+// PinManifest decodes the manifest of a K8s task and pins its images with
+// the lock file at lockPath. With save, the lock file is written when images
+// were resolved, otherwise they are only locked in memory.
+func PinManifest(config cue.Value, lockPath string, save bool) (map[string]interface{}, map[string]string, error) {
+	var manifest map[string]interface{}
+	if err := config.Decode(&manifest); err != nil {
+		return nil, nil, err
+	}
+	lock, err := ImageLockFor(lockPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	pinned, err := PinImages(manifest, lock.Pin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !save {
+		return manifest, pinned, nil
+	}
+	if err := lock.Save(); err != nil {
+		return nil, nil, fmt.Errorf("failed to save the image lock file: %v", err)
+	}
+	return manifest, pinned, nil
+}
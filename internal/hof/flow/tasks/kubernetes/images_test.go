@@ -0,0 +1,158 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+
+package kubernetes
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pushImage pushes a random image to ref and returns its digest
+func pushImage(t *testing.T, ref string) string {
+	t.Helper()
+	img, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, crane.Push(img, ref))
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	return digest.String()
+}
+
+func deployment(images ...string) map[string]interface{} {
+	containers := []interface{}{}
+	for _, image := range images {
+		containers = append(containers, map[string]interface{}{"name": "c", "image": image})
+	}
+	return map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"initContainers": containers[:1],
+					"containers":     containers[1:],
+				},
+			},
+		},
+	}
+}
+
+func podImages(manifest map[string]interface{}) []string {
+	spec := manifest["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})
+	var images []string
+	for _, key := range []string{"initContainers", "containers"} {
+		for _, c := range spec[key].([]interface{}) {
+			images = append(images, c.(map[string]interface{})["image"].(string))
+		}
+	}
+	return images
+}
+
+func TestPinImages(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	app, migrate := host+"/app:v1", host+"/migrate:v1"
+	appDigest, migrateDigest := pushImage(t, app), pushImage(t, migrate)
+	pinnedRef := host + "/sidecar@sha256:" + strings.Repeat("a", 64)
+
+	lockPath := filepath.Join(t.TempDir(), "images.lock.json")
+	lock, err := LoadImageLock(lockPath)
+	require.NoError(t, err)
+
+	manifest := deployment(migrate, app, pinnedRef)
+	pinned, err := PinImages(manifest, lock.Pin)
+	require.NoError(t, err)
+	require.NoError(t, lock.Save())
+
+	assert.Equal(t, []string{
+		host + "/migrate@" + migrateDigest,
+		host + "/app@" + appDigest,
+		pinnedRef,
+	}, podImages(manifest))
+	assert.Equal(t, map[string]string{
+		app:     host + "/app@" + appDigest,
+		migrate: host + "/migrate@" + migrateDigest,
+	}, pinned)
+
+	// a moved tag keeps the digest of the lock file
+	pushImage(t, app)
+	lock, err = LoadImageLock(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{app: appDigest, migrate: migrateDigest}, lock.Images)
+
+	manifest = deployment(migrate, app)
+	_, err = PinImages(manifest, lock.Pin)
+	require.NoError(t, err)
+	assert.Equal(t, []string{host + "/migrate@" + migrateDigest, host + "/app@" + appDigest}, podImages(manifest))
+}
+
+func TestPinManifestSave(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	app := host + "/app:v1"
+	digest := pushImage(t, app)
+
+	config := cuecontext.New().Encode(deployment(app, app))
+	lockPath := filepath.Join(t.TempDir(), "images.lock.json")
+
+	// a plan or render pins the images without writing the lock file
+	manifest, pinned, err := PinManifest(config, lockPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{host + "/app@" + digest, host + "/app@" + digest}, podImages(manifest))
+	assert.Equal(t, map[string]string{app: host + "/app@" + digest}, pinned)
+	assert.NoFileExists(t, lockPath)
+
+	_, _, err = PinManifest(config, lockPath, true)
+	require.NoError(t, err)
+	lock, err := LoadImageLock(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{app: digest}, lock.Images)
+}
+
+func TestPinImagesUnknownTag(t *testing.T) {
+	srv := httptest.NewServer(registry.New())
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	lock, err := LoadImageLock(filepath.Join(t.TempDir(), "images.lock.json"))
+	require.NoError(t, err)
+
+	_, err = PinImages(deployment(host+"/missing:v1", host+"/missing:v1"), lock.Pin)
+	assert.ErrorContains(t, err, host+"/missing:v1")
+	assert.Empty(t, lock.Images)
+}
+
+func TestImageRepo(t *testing.T) {
+	for image, repo := range map[string]string{
+		"nginx":                       "nginx",
+		"nginx:1.25":                  "nginx",
+		"localhost:5000/app":          "localhost:5000/app",
+		"localhost:5000/team/app:v1":  "localhost:5000/team/app",
+		"ghcr.io/org/app:1.2.3-alpha": "ghcr.io/org/app",
+	} {
+		assert.Equal(t, repo, imageRepo(image), image)
+	}
+}
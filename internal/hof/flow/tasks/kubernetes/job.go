@@ -0,0 +1,314 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cuelang.org/go/cue"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+const (
+	// defaultJobTTL is how long a finished job and its pods are kept
+	defaultJobTTL = 3600
+
+	// maxJobNameLength keeps the job-name label of its pods valid
+	maxJobNameLength = 63
+
+	// failedLogLines are the last lines of the logs shown when a job fails
+	failedLogLines = 20
+)
+
+// K8sJobTask runs a Job to completion, such as a schema migration before
+// a rollout, and fails the flow when the Job fails
+type K8sJobTask struct{}
+
+func NewK8sJobTask(val cue.Value) (hofcontext.Runner, error) {
+	return &K8sJobTask{}, nil
+}
+
+// JobOut is the out of a K8sJob task
+type JobOut struct {
+	Job       string `json:"job"`
+	Namespace string `json:"namespace"`
+	Status    string `json:"status"`
+	Logs      string `json:"logs"`
+
+	// Values parsed from the logs with the patterns of log_values
+	Values map[string]string `json:"values,omitempty"`
+}
+
+func (t *K8sJobTask) Run(ctx *hofcontext.Context) (any, error) {
+	v := ctx.Value
+
+	// a Job only runs on apply
+	if !ctx.Apply {
+		if ctx.Plan {
+			fmt.Printf("Job %s will run on apply\n", ctx.BaseTask.ID)
+		}
+		return v, nil
+	}
+
+	var job batchv1.Job
+	data, err := v.LookupPath(cue.ParsePath("config")).MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the job from CUE: %v", err)
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("invalid job: %v", err)
+	}
+
+	opts := JobOptions{TTLSeconds: defaultJobTTL}
+	if ttl := v.LookupPath(cue.ParsePath(mantis.MantisJobTTL)); ttl.Exists() {
+		seconds, err := ttl.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", mantis.MantisJobTTL, err)
+		}
+		opts.TTLSeconds = int32(seconds)
+	}
+	if lv := v.LookupPath(cue.ParsePath(mantis.MantisJobLogValues)); lv.Exists() {
+		var patterns map[string]string
+		if err := lv.Decode(&patterns); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", mantis.MantisJobLogValues, err)
+		}
+		opts.LogValues = map[string]*regexp.Regexp{}
+		for name, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s.%s: %v", mantis.MantisJobLogValues, name, err)
+			}
+			opts.LogValues[name] = re
+		}
+	}
+
+	var cluster Cluster
+	if cv := v.LookupPath(cue.ParsePath(mantis.MantisTaskCluster)); cv.Exists() {
+		if err := cv.Decode(&cluster); err != nil {
+			return nil, fmt.Errorf("invalid cluster: %v", err)
+		}
+	}
+	client, err := ClientFor(cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
+	}
+
+	runner := NewJobRunner(client.clientset)
+	runner.Stdout = ctx.Stdout
+	out, runErr := runner.Run(ctx.GoContext, &job, opts)
+	if out == nil {
+		return nil, runErr
+	}
+
+	// a failed job still reports its status and logs
+	var outValue map[string]interface{}
+	data, _ = json.Marshal(out)
+	if err := json.Unmarshal(data, &outValue); err != nil {
+		return nil, err
+	}
+	return v.FillPath(cue.ParsePath(mantis.MantisTaskOuts), outValue), runErr
+}
+
+// JobOptions controls how a Job is run
+type JobOptions struct {
+	// TTLSeconds after which the finished Job and its pods are removed,
+	// unless the Job sets spec.ttlSecondsAfterFinished itself
+	TTLSeconds int32
+
+	// LogValues are parsed from the logs, the first group of the last
+	// match of each pattern, or the whole match without a group
+	LogValues map[string]*regexp.Regexp
+}
+
+// JobRunner creates Jobs, waits for them to finish and captures their logs
+type JobRunner struct {
+	Client   kubernetes.Interface
+	Interval time.Duration
+	Stdout   io.Writer
+
+	// Suffix makes the name of each run of a Job unique
+	Suffix func() string
+}
+
+func NewJobRunner(client kubernetes.Interface) *JobRunner {
+	return &JobRunner{
+		Client:   client,
+		Interval: waitInterval,
+		Stdout:   os.Stdout,
+		Suffix:   func() string { return utilrand.String(5) },
+	}
+}
+
+// Run creates job under a name unique to this run, waits for it to complete
+// or fail and returns its logs. It fails when the job fails.
+func (r *JobRunner) Run(ctx context.Context, job *batchv1.Job, opts JobOptions) (*JobOut, error) {
+	base := job.Name
+	if base == "" {
+		base = strings.TrimSuffix(job.GenerateName, "-")
+	}
+	if base == "" {
+		return nil, fmt.Errorf("the job needs metadata.name")
+	}
+	job.Name = UniqueJobName(base, r.Suffix())
+	job.GenerateName = ""
+	if job.Namespace == "" {
+		job.Namespace = "default"
+	}
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		ttl := opts.TTLSeconds
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+
+	jobs := r.Client.BatchV1().Jobs(job.Namespace)
+	created, err := jobs.Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	fmt.Fprintf(r.Stdout, "Created job %s/%s\n", created.Namespace, created.Name)
+
+	status, message, err := r.wait(ctx, created)
+	if err != nil {
+		// the job is not needed once the flow stopped waiting for it
+		propagation := metav1.DeletePropagationBackground
+		jobs.Delete(context.Background(), created.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		return nil, err
+	}
+
+	// the pods may not have produced logs, the status still tells how the
+	// job ended
+	logs, err := r.logs(ctx, created)
+	if err != nil {
+		fmt.Fprintf(r.Stdout, "warning: %v\n", err)
+	}
+	fmt.Fprint(r.Stdout, logs)
+
+	out := &JobOut{
+		Job:       created.Name,
+		Namespace: created.Namespace,
+		Status:    status,
+		Logs:      logs,
+		Values:    parseLogValues(logs, opts.LogValues),
+	}
+	if status == string(batchv1.JobFailed) {
+		return out, fmt.Errorf("job %s/%s failed: %s\n%s", created.Namespace, created.Name, message, lastLines(logs, failedLogLines))
+	}
+	return out, nil
+}
+
+// wait polls the job until its Complete or Failed condition is true
+func (r *JobRunner) wait(ctx context.Context, job *batchv1.Job) (string, string, error) {
+	jobs := r.Client.BatchV1().Jobs(job.Namespace)
+	for {
+		current, err := jobs.Get(ctx, job.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get job %s/%s: %w", job.Namespace, job.Name, err)
+		}
+		for _, c := range current.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			if c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed {
+				return string(c.Type), c.Message, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", "", fmt.Errorf("job %s/%s did not finish: %w", job.Namespace, job.Name, ctx.Err())
+		case <-time.After(r.Interval):
+		}
+	}
+}
+
+// logs returns the logs of every container of the pods of job, oldest pod
+// first, so that retried pods follow the attempts which failed
+func (r *JobRunner) logs(ctx context.Context, job *batchv1.Job) (string, error) {
+	pods := r.Client.CoreV1().Pods(job.Namespace)
+	list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + job.Name})
+	if err != nil {
+		return "", fmt.Errorf("failed to list the pods of job %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].CreationTimestamp.Before(&list.Items[j].CreationTimestamp)
+	})
+
+	var sb strings.Builder
+	for _, pod := range list.Items {
+		for _, container := range pod.Spec.Containers {
+			if len(list.Items) > 1 || len(pod.Spec.Containers) > 1 {
+				fmt.Fprintf(&sb, "--- %s/%s ---\n", pod.Name, container.Name)
+			}
+			data, err := pods.GetLogs(pod.Name, &corev1.PodLogOptions{Container: container.Name}).DoRaw(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to get the logs of %s/%s: %w", pod.Name, container.Name, err)
+			}
+			sb.Write(data)
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				sb.WriteByte('\n')
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// UniqueJobName appends suffix to base, shortening base so that the name
+// stays a valid label value
+func UniqueJobName(base, suffix string) string {
+	if max := maxJobNameLength - len(suffix) - 1; len(base) > max {
+		base = strings.TrimRight(base[:max], "-.")
+	}
+	return base + "-" + suffix
+}
+
+func parseLogValues(logs string, patterns map[string]*regexp.Regexp) map[string]string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	for name, re := range patterns {
+		matches := re.FindAllStringSubmatch(logs, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		last := matches[len(matches)-1]
+		if len(last) > 1 {
+			values[name] = last[1]
+		} else {
+			values[name] = last[0]
+		}
+	}
+	return values
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,162 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newJobRunner returns a runner whose job migrate-run1 finishes with the
+// given condition as soon as it is created, with one pod
+func newJobRunner(t *testing.T, condition batchv1.JobConditionType) (*JobRunner, *fake.Clientset) {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "migrate-run1-abcde",
+			Namespace: "app",
+			Labels:    map[string]string{"job-name": "migrate-run1"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "migrate"}}},
+	})
+	client.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		job := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type:    condition,
+			Status:  corev1.ConditionTrue,
+			Message: "BackoffLimitExceeded",
+		}}
+		// let the tracker store the job with its status
+		return false, nil, nil
+	})
+
+	runner := NewJobRunner(client)
+	runner.Interval = time.Millisecond
+	runner.Stdout = io.Discard
+	runner.Suffix = func() string { return "run1" }
+	return runner, client
+}
+
+func newJob() *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "app"},
+	}
+}
+
+func TestJobRunnerComplete(t *testing.T) {
+	runner, client := newJobRunner(t, batchv1.JobComplete)
+
+	out, err := runner.Run(context.Background(), newJob(), JobOptions{
+		TTLSeconds: 600,
+		LogValues: map[string]*regexp.Regexp{
+			"word":  regexp.MustCompile(`fake (\w+)`),
+			"whole": regexp.MustCompile(`fake \w+`),
+			"none":  regexp.MustCompile(`missing`),
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "migrate-run1", out.Job)
+	assert.Equal(t, "app", out.Namespace)
+	assert.Equal(t, string(batchv1.JobComplete), out.Status)
+	// the fake clientset returns "fake logs" as the logs of every container
+	assert.Equal(t, "fake logs\n", out.Logs)
+	assert.Equal(t, map[string]string{"word": "logs", "whole": "fake logs"}, out.Values)
+
+	job, err := client.BatchV1().Jobs("app").Get(context.Background(), "migrate-run1", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, job.Spec.TTLSecondsAfterFinished)
+	assert.Equal(t, int32(600), *job.Spec.TTLSecondsAfterFinished)
+}
+
+func TestJobRunnerKeepsJobTTL(t *testing.T) {
+	runner, client := newJobRunner(t, batchv1.JobComplete)
+
+	job := newJob()
+	ttl := int32(30)
+	job.Spec.TTLSecondsAfterFinished = &ttl
+	_, err := runner.Run(context.Background(), job, JobOptions{TTLSeconds: 600})
+	require.NoError(t, err)
+
+	created, err := client.BatchV1().Jobs("app").Get(context.Background(), "migrate-run1", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(30), *created.Spec.TTLSecondsAfterFinished)
+}
+
+func TestJobRunnerFailed(t *testing.T) {
+	runner, _ := newJobRunner(t, batchv1.JobFailed)
+
+	out, err := runner.Run(context.Background(), newJob(), JobOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job app/migrate-run1 failed: BackoffLimitExceeded")
+	assert.Contains(t, err.Error(), "fake logs")
+	require.NotNil(t, out)
+	assert.Equal(t, string(batchv1.JobFailed), out.Status)
+}
+
+func TestJobRunnerFailedWithoutLogs(t *testing.T) {
+	runner, client := newJobRunner(t, batchv1.JobFailed)
+	client.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("pods are gone")
+	})
+	var stdout strings.Builder
+	runner.Stdout = &stdout
+
+	out, err := runner.Run(context.Background(), newJob(), JobOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job app/migrate-run1 failed: BackoffLimitExceeded", "the failure is not replaced by the logs error")
+	assert.Contains(t, stdout.String(), "warning: failed to list the pods of job app/migrate-run1: pods are gone")
+	require.NotNil(t, out)
+	assert.Equal(t, string(batchv1.JobFailed), out.Status)
+	assert.Empty(t, out.Logs)
+}
+
+func TestJobRunnerInterrupted(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	runner := NewJobRunner(client)
+	runner.Interval = time.Millisecond
+	runner.Stdout = io.Discard
+	runner.Suffix = func() string { return "run1" }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := runner.Run(ctx, newJob(), JobOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "did not finish")
+
+	// the job is removed once the flow stopped waiting for it
+	jobs, err := client.BatchV1().Jobs("app").List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, jobs.Items)
+}
+
+func TestUniqueJobName(t *testing.T) {
+	assert.Equal(t, "migrate-x1y2z", UniqueJobName("migrate", "x1y2z"))
+
+	name := UniqueJobName(strings.Repeat("a", 60)+"-b", "x1y2z")
+	assert.LessOrEqual(t, len(name), maxJobNameLength)
+	assert.True(t, strings.HasSuffix(name, "-x1y2z"))
+}
@@ -14,10 +14,16 @@
 package kubernetes
 
 import (
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/pkg/encoding/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
 	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
@@ -44,29 +50,91 @@ func (t *K8sTask) Run(ctx *hofcontext.Context) (any, error) {
 		return nil, fmt.Errorf("failed to extract manifests from CUE: %v", err)
 	}
 
-	// Initialize Kubernetes client
-	client, err := NewClient()
+	// Pin image tags to digests, the same ones on every run once locked.
+	// Only an apply locks the digests of new images.
+	var pinned map[string]string
+	if pin, _ := v.LookupPath(cue.ParsePath(mantis.MantisTaskPinImages)).Bool(); pin {
+		lockPath := filepath.Join(ctx.FlowDir, mantis.MantisImageLockPath)
+		obj, images, err := PinManifest(configValue, lockPath, ctx.Apply)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pin images: %v", err)
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		manifest, pinned = string(data), images
+		refs := make([]string, 0, len(pinned))
+		for image := range pinned {
+			refs = append(refs, image)
+		}
+		sort.Strings(refs)
+		for _, image := range refs {
+			fmt.Printf("Pinned image %s to %s\n", image, pinned[image])
+		}
+	}
+
+	// Initialize Kubernetes client of the task's cluster
+	var cluster Cluster
+	if cv := v.LookupPath(cue.ParsePath(mantis.MantisTaskCluster)); cv.Exists() {
+		if err := cv.Decode(&cluster); err != nil {
+			return nil, fmt.Errorf("invalid cluster: %v", err)
+		}
+	}
+	client, err := ClientFor(cluster)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
 	}
 
+	opts, err := applyOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	if ctx.Plan {
 		// Perform a dry-run to simulate changes
-		err = client.Plan(string(manifest))
+		err = client.Plan(ctx.GoContext, string(manifest), opts)
 		if err != nil {
 			return nil, fmt.Errorf("plan failed. Check if Kubernetes cluster is accessible: %v", err)
 		}
 
 	} else if ctx.Apply {
+		wait, _ := v.LookupPath(cue.ParsePath(mantis.MantisTaskWait)).Bool()
+		restore, _ := v.LookupPath(cue.ParsePath(mantis.MantisTaskRollback)).Bool()
+
+		// the object before the apply, restored if the rollout fails
+		var snapshot *unstructured.Unstructured
+		if restore {
+			if snapshot, err = client.Live(ctx.GoContext, manifest); err != nil {
+				return nil, fmt.Errorf("failed to snapshot the object before apply: %v", err)
+			}
+		}
+
 		// Apply the changes to the cluster
-		err = client.Apply(manifest)
+		applied, err := client.Apply(ctx.GoContext, manifest, opts)
 		if err != nil {
 			return nil, fmt.Errorf("apply failed. Check if Kubernetes cluster is accessible: %v", err)
 		}
+		if wait || restore {
+			ready, err := client.WaitReady(ctx.GoContext, applied)
+			if err != nil {
+				if restore {
+					return rollback(ctx, client, manifest, snapshot, opts, err)
+				}
+				return nil, err
+			}
+			applied = ready
+		}
+		fmt.Println("Operation completed successfully")
+		out := appliedOut(applied)
+		if len(pinned) > 0 {
+			out["images"] = pinned
+		}
+		return v.FillPath(cue.ParsePath(mantis.MantisTaskOuts), out), nil
 
 	} else if ctx.Destroy {
 		// Delete the specified resources
-		err = client.Delete(manifest)
+		err = client.Delete(ctx.GoContext, manifest)
 		if err != nil {
 			return nil, fmt.Errorf("destroy failed. Check if Kubernetes cluster is accessible: %v", err)
 		}
@@ -77,3 +145,50 @@ func (t *K8sTask) Run(ctx *hofcontext.Context) (any, error) {
 	newV := v.FillPath(cue.ParsePath(mantis.MantisTaskOuts), "Resource applied successfully")
 	return newV, nil
 }
+
+// applyOptions reads field_manager, force_conflicts and ignore_fields of
+// a task. The field manager defaults to mantis/<flow>/<task>.
+func applyOptions(ctx *hofcontext.Context) (ApplyOptions, error) {
+	v := ctx.Value
+	opts := ApplyOptions{
+		FieldManager: fmt.Sprintf("%s/%s/%s", defaultFieldManager, ctx.FlowName, ctx.BaseTask.ID),
+	}
+	if fm := v.LookupPath(cue.ParsePath(mantis.MantisTaskFieldManager)); fm.Exists() {
+		name, err := fm.String()
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s: %v", mantis.MantisTaskFieldManager, err)
+		}
+		opts.FieldManager = name
+	}
+	if fc := v.LookupPath(cue.ParsePath(mantis.MantisTaskForceConflicts)); fc.Exists() {
+		force, err := fc.Bool()
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s: %v", mantis.MantisTaskForceConflicts, err)
+		}
+		opts.ForceConflicts = force
+	}
+	if ig := v.LookupPath(cue.ParsePath(mantis.MantisTaskIgnoreFields)); ig.Exists() {
+		if err := ig.Decode(&opts.IgnoreFields); err != nil {
+			return opts, fmt.Errorf("invalid %s: %v", mantis.MantisTaskIgnoreFields, err)
+		}
+	}
+	return opts, nil
+}
+
+// appliedOut is the out of a K8s task, the applied object as returned by the
+// server under its lowercased kind, so that exports read for instance
+// .service.status.loadBalancer.ingress[0].hostname. The data of Secrets is
+// left out, since out ends up in the run journal and in rendered output.
+func appliedOut(obj *unstructured.Unstructured) map[string]interface{} {
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	if obj.GetKind() == "Secret" && obj.GroupVersionKind().Group == "" {
+		unstructured.RemoveNestedField(obj.Object, "data")
+		unstructured.RemoveNestedField(obj.Object, "stringData")
+		// set by kubectl apply, with the data it applied
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations", corev1.LastAppliedConfigAnnotation)
+	}
+	return map[string]interface{}{
+		strings.ToLower(obj.GetKind()): obj.Object,
+	}
+}
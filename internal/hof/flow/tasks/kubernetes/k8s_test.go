@@ -0,0 +1,62 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAppliedOut(t *testing.T) {
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":          "db",
+			"managedFields": []interface{}{map[string]interface{}{"manager": "mantis"}},
+			"annotations": map[string]interface{}{
+				corev1.LastAppliedConfigAnnotation: `{"data":{"password":"aHVudGVyMg=="}}`,
+				"team":                             "infra",
+			},
+		},
+		"type":       "Opaque",
+		"data":       map[string]interface{}{"password": "aHVudGVyMg=="},
+		"stringData": map[string]interface{}{"user": "admin"},
+	}}
+
+	assert.Equal(t, map[string]interface{}{
+		"secret": map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":        "db",
+				"annotations": map[string]interface{}{"team": "infra"},
+			},
+			"type": "Opaque",
+		},
+	}, appliedOut(secret))
+	assert.Contains(t, secret.Object, "data", "the applied object is left as is")
+
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "web"},
+		"status": map[string]interface{}{
+			"loadBalancer": map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"hostname": "lb"}}},
+		},
+	}}
+	assert.Equal(t, map[string]interface{}{"service": service.Object}, appliedOut(service))
+}
@@ -0,0 +1,311 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cuelang.org/go/cue"
+	"gopkg.in/yaml.v3"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// rollbackTimeout bounds a rollback, which runs after the task's own
+// timeout may have expired
+const rollbackTimeout = time.Minute
+
+// Rollback statuses reported in out
+const (
+	RollbackRestored = "restored"
+	RollbackDeleted  = "deleted"
+	RollbackFailed   = "failed"
+)
+
+// Rollback is the outcome of rollback_on_failure, reported in out.rollback
+type Rollback struct {
+	// Reason is why the rollout failed
+	Reason string `json:"reason"`
+
+	// Status is restored when the fields of the task were put back as
+	// they were before, deleted when the task had created the object,
+	// or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Live returns the object of a manifest as it is in the cluster,
+// nil when it does not exist
+func (c *Client) Live(ctx context.Context, manifestYAML string) (*unstructured.Unstructured, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(manifestYAML), &obj); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	u := &unstructured.Unstructured{Object: obj}
+	resourceClient, err := c.resourceClient(u)
+	if err != nil {
+		return nil, err
+	}
+	live, err := resourceClient.Get(ctx, u.GetName(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
+
+// Restore puts back the fields of snapshot, the object of manifest before
+// it was applied, which the task's manager owned then or took over in the
+// failed apply, and removes those it added. Fields of other managers are
+// left alone. The object is deleted when there was none. It returns the
+// rollback status and the restored object.
+func (c *Client) Restore(ctx context.Context, manifestYAML string, snapshot *unstructured.Unstructured, opts ApplyOptions) (string, *unstructured.Unstructured, error) {
+	if snapshot == nil {
+		if err := c.Delete(ctx, manifestYAML); err != nil {
+			return RollbackFailed, nil, err
+		}
+		return RollbackDeleted, nil, nil
+	}
+
+	resourceClient, err := c.resourceClient(snapshot)
+	if err != nil {
+		return RollbackFailed, nil, err
+	}
+	live, err := resourceClient.Get(ctx, snapshot.GetName(), metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return RollbackFailed, nil, fmt.Errorf("failed to get %s/%s: %w", snapshot.GetKind(), snapshot.GetName(), err)
+	}
+	if k8serrors.IsNotFound(err) {
+		live = nil
+	}
+	restore, err := restoreObject(snapshot, live, opts.fieldManager())
+	if err != nil {
+		return RollbackFailed, nil, err
+	}
+	removePaths(restore.Object, opts.IgnoreFields)
+
+	// applying fewer fields under the task's manager removes those it owns
+	// and no longer sets, forcing takes back those it owned in the snapshot
+	restored, err := resourceClient.Apply(ctx, restore.GetName(), restore, metav1.ApplyOptions{
+		FieldManager: opts.fieldManager(),
+		Force:        true,
+	})
+	if err != nil {
+		return RollbackFailed, nil, fmt.Errorf("failed to re-apply %s/%s: %w", restore.GetKind(), restore.GetName(), err)
+	}
+	return RollbackRestored, restored, nil
+}
+
+// restoreObject returns the object manager applies to roll back to
+// snapshot: the values in snapshot of the fields manager owned in it, or
+// owns in live after the failed apply. The fields only the failed apply set
+// are left out, so that applying it removes them.
+func restoreObject(snapshot, live *unstructured.Unstructured, manager string) (*unstructured.Unstructured, error) {
+	fields, err := ownedFields(snapshot, manager)
+	if err != nil {
+		return nil, err
+	}
+	if live != nil {
+		applied, err := ownedFields(live, manager)
+		if err != nil {
+			return nil, err
+		}
+		mergeFields(fields, applied)
+	}
+
+	obj := map[string]interface{}{}
+	if len(fields) > 0 {
+		obj = extractFields(snapshot.Object, fields).(map[string]interface{})
+	}
+	restore := &unstructured.Unstructured{Object: obj}
+	restore.SetAPIVersion(snapshot.GetAPIVersion())
+	restore.SetKind(snapshot.GetKind())
+	restore.SetName(snapshot.GetName())
+	restore.SetNamespace(snapshot.GetNamespace())
+	removeFields(restore)
+	unstructured.RemoveNestedField(restore.Object, "metadata", "managedFields")
+	return restore, nil
+}
+
+// ownedFields returns the fields manager applied to obj, in the FieldsV1
+// format of managedFields
+func ownedFields(obj *unstructured.Unstructured, manager string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	for _, e := range obj.GetManagedFields() {
+		if e.Manager != manager || e.Operation != metav1.ManagedFieldsOperationApply || e.Subresource != "" || e.FieldsV1 == nil {
+			continue
+		}
+		var owned map[string]interface{}
+		if err := json.Unmarshal(e.FieldsV1.Raw, &owned); err != nil {
+			return nil, fmt.Errorf("invalid managed fields of %s: %w", manager, err)
+		}
+		mergeFields(fields, owned)
+	}
+	return fields, nil
+}
+
+// mergeFields adds the FieldsV1 fields of from to into
+func mergeFields(into, from map[string]interface{}) {
+	for k, v := range from {
+		sub, _ := v.(map[string]interface{})
+		existing, ok := into[k].(map[string]interface{})
+		if !ok {
+			existing = map[string]interface{}{}
+			into[k] = existing
+		}
+		mergeFields(existing, sub)
+	}
+}
+
+// extractFields returns the part of value selected by fields, in the
+// FieldsV1 format: f:<name> selects a key of a map, k:<json> the element of
+// a list with those keys, v:<json> the element equal to it and i:<n> the
+// element at n. A field without children, atomic, is selected whole.
+func extractFields(value interface{}, fields map[string]interface{}) interface{} {
+	children := map[string]interface{}{}
+	for k, v := range fields {
+		if k != "." {
+			children[k] = v
+		}
+	}
+	if len(children) == 0 {
+		// "." alone owns a map or list without its entries
+		if _, ok := fields["."]; ok {
+			switch value.(type) {
+			case map[string]interface{}:
+				return map[string]interface{}{}
+			case []interface{}:
+				return []interface{}{}
+			}
+		}
+		return runtime.DeepCopyJSONValue(value)
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		for k, sub := range children {
+			name, ok := strings.CutPrefix(k, "f:")
+			if !ok {
+				continue
+			}
+			if v, ok := value[name]; ok {
+				sub, _ := sub.(map[string]interface{})
+				out[name] = extractFields(v, sub)
+			}
+		}
+		return out
+	case []interface{}:
+		var out []interface{}
+		for i, elem := range value {
+			for k, sub := range children {
+				if !listElementMatches(k, i, elem) {
+					continue
+				}
+				sub, _ := sub.(map[string]interface{})
+				extracted := extractFields(elem, sub)
+				// the keys identify the element to merge it into
+				if m, ok := extracted.(map[string]interface{}); ok && strings.HasPrefix(k, "k:") {
+					var keys map[string]interface{}
+					json.Unmarshal([]byte(k[2:]), &keys)
+					for name, v := range keys {
+						m[name] = v
+					}
+				}
+				out = append(out, extracted)
+				break
+			}
+		}
+		return out
+	}
+	return runtime.DeepCopyJSONValue(value)
+}
+
+// listElementMatches tells whether elem, at index i of its list, is the
+// element selected by the FieldsV1 key k
+func listElementMatches(k string, i int, elem interface{}) bool {
+	switch {
+	case strings.HasPrefix(k, "k:"):
+		var keys map[string]interface{}
+		m, ok := elem.(map[string]interface{})
+		if !ok || json.Unmarshal([]byte(k[2:]), &keys) != nil {
+			return false
+		}
+		for name, v := range keys {
+			if !reflect.DeepEqual(normalizeJSON(m[name]), normalizeJSON(v)) {
+				return false
+			}
+		}
+		return true
+	case strings.HasPrefix(k, "v:"):
+		var v interface{}
+		if json.Unmarshal([]byte(k[2:]), &v) != nil {
+			return false
+		}
+		return reflect.DeepEqual(normalizeJSON(elem), normalizeJSON(v))
+	case strings.HasPrefix(k, "i:"):
+		return k[2:] == strconv.Itoa(i)
+	}
+	return false
+}
+
+// normalizeJSON converts a value to what it decodes to from JSON, so that
+// the int64 of unstructured objects compares equal to float64
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// rollback restores the object of a task whose rollout failed with reason.
+// The task fails either way, with the outcome in out.rollback and in the
+// errors of the flow.
+func rollback(ctx *hofcontext.Context, client *Client, manifest string, snapshot *unstructured.Unstructured, opts ApplyOptions, reason error) (any, error) {
+	rctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	fmt.Printf("Rolling back %s: %v\n", ctx.BaseTask.ID, reason)
+	rb := Rollback{Reason: reason.Error()}
+	status, restored, err := client.Restore(rctx, manifest, snapshot, opts)
+	rb.Status = status
+	if err != nil {
+		rb.Error = err.Error()
+	}
+
+	out := map[string]interface{}{}
+	if restored != nil {
+		out = appliedOut(restored)
+	}
+	out["rollback"] = rb
+
+	msg := fmt.Sprintf("%s: rollout failed: %v, rollback %s", ctx.BaseTask.ID, reason, rb.Status)
+	if rb.Error != "" {
+		msg += ": " + rb.Error
+	}
+	ctx.AddError(msg)
+	return ctx.Value.FillPath(cue.ParsePath(mantis.MantisTaskOuts), out), fmt.Errorf("%s", msg)
+}
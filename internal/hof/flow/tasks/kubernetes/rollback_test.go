@@ -0,0 +1,278 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+)
+
+var deployments = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+// applyReactor stores applied objects as they are, standing in for the
+// server-side apply the fake client lacks. rollout sets the status the
+// controllers report for an applied object.
+func applyReactor(dyn *dynamicfake.FakeDynamicClient, rollout func(*unstructured.Unstructured)) {
+	dyn.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		rollout(obj)
+
+		gvr, ns := action.GetResource(), action.GetNamespace()
+		_, err := dyn.Tracker().Get(gvr, ns, patch.GetName())
+		if k8serrors.IsNotFound(err) {
+			err = dyn.Tracker().Create(gvr, obj, ns)
+		} else if err == nil {
+			err = dyn.Tracker().Update(gvr, obj, ns)
+		}
+		return true, obj, err
+	})
+}
+
+// rolloutImage completes the rollout of deployments, except for those
+// running image, which exceed their progress deadline
+func rolloutImage(image string) func(*unstructured.Unstructured) {
+	return func(obj *unstructured.Unstructured) {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		if containers[0].(map[string]interface{})["image"] == image {
+			unstructured.SetNestedField(obj.Object, map[string]interface{}{
+				"replicas":          int64(1),
+				"updatedReplicas":   int64(1),
+				"availableReplicas": int64(0),
+				"conditions": []interface{}{map[string]interface{}{
+					"type":   "Progressing",
+					"status": "False",
+					"reason": "ProgressDeadlineExceeded",
+				}},
+			}, "status")
+			return
+		}
+		unstructured.SetNestedField(obj.Object, map[string]interface{}{
+			"replicas":          int64(1),
+			"updatedReplicas":   int64(1),
+			"availableReplicas": int64(1),
+		}, "status")
+	}
+}
+
+// webFields are the fields of webDeployment applied by its task
+const webFields = `{
+  "f:spec": {
+    "f:replicas": {},
+    "f:template": {"f:spec": {"f:containers": {
+      "k:{\"name\":\"web\"}": {".": {}, "f:image": {}, "f:name": {}}
+    }}}
+  }
+}`
+
+func managedFields(manager string, operation metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  operation,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func webDeployment(image string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "app"},
+		"spec": map[string]interface{}{
+			"replicas": int64(1),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "web", "image": image}},
+				},
+			},
+		},
+	}}
+	obj.SetManagedFields([]metav1.ManagedFieldsEntry{managedFields("mantis/f/f.web", metav1.ManagedFieldsOperationApply, webFields)})
+	rolloutImage("")(obj)
+	return obj
+}
+
+// runRollbackTask applies web:2, whose rollout fails, with a K8s task
+// deploying to client
+func runRollbackTask(t *testing.T, client *Client) (cue.Value, error) {
+	cluster := Cluster{Server: "https://" + t.Name()}
+	clientsMu.Lock()
+	clients[cluster] = client
+	clientsMu.Unlock()
+	t.Cleanup(func() {
+		clientsMu.Lock()
+		delete(clients, cluster)
+		clientsMu.Unlock()
+	})
+
+	ctx := hofcontext.New()
+	ctx.FlowName = "f"
+	ctx.BaseTask = &task.BaseTask{ID: "f.web"}
+	ctx.Apply = true
+	ctx.Value = cuecontext.New().CompileString(`
+cluster: server: "https://` + t.Name() + `"
+rollback_on_failure: true
+config: {
+	apiVersion: "apps/v1"
+	kind:       "Deployment"
+	metadata: {name: "web", namespace: "app"}
+	spec: {
+		replicas: 1
+		template: spec: containers: [{name: "web", image: "web:2"}]
+	}
+}
+`)
+	require.NoError(t, ctx.Value.Err())
+
+	v, err := (&K8sTask{}).Run(ctx)
+	require.Len(t, ctx.FlowErrors, 1)
+	return v.(cue.Value), err
+}
+
+func TestRollbackRestored(t *testing.T) {
+	client, dyn := newFakeClient(t, webDeployment("web:1"))
+	applyReactor(dyn, rolloutImage("web:2"))
+
+	v, err := runRollbackTask(t, client)
+	assert.EqualError(t, err, "f.web: rollout failed: Deployment/web exceeded its progress deadline, rollback restored")
+
+	status, _ := v.LookupPath(cue.ParsePath("out.rollback.status")).String()
+	assert.Equal(t, RollbackRestored, status)
+	image, _ := v.LookupPath(cue.ParsePath("out.deployment.spec.template.spec.containers[0].image")).String()
+	assert.Equal(t, "web:1", image)
+
+	live, err := dyn.Tracker().Get(deployments, "app", "web")
+	require.NoError(t, err)
+	containers, _, _ := unstructured.NestedSlice(live.(*unstructured.Unstructured).Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, "web:1", containers[0].(map[string]interface{})["image"])
+}
+
+func TestRollbackDeleted(t *testing.T) {
+	client, dyn := newFakeClient(t)
+	applyReactor(dyn, rolloutImage("web:2"))
+
+	v, err := runRollbackTask(t, client)
+	assert.ErrorContains(t, err, "rollback deleted")
+
+	status, _ := v.LookupPath(cue.ParsePath("out.rollback.status")).String()
+	assert.Equal(t, RollbackDeleted, status)
+	_, err = dyn.Tracker().Get(deployments, "app", "web")
+	assert.True(t, k8serrors.IsNotFound(err), "the deployment created by the task is deleted")
+}
+
+// sharedDeployment is web:1 scaled by an autoscaler, with a label and an
+// annotation of another manager
+func sharedDeployment() *unstructured.Unstructured {
+	obj := webDeployment("web:1")
+	obj.SetLabels(map[string]string{"app": "web", "team": "payments"})
+	obj.SetAnnotations(map[string]string{"note": "keep"})
+	unstructured.SetNestedField(obj.Object, int64(3), "spec", "replicas")
+	return obj
+}
+
+func TestRestoreObject(t *testing.T) {
+	const manager = "mantis/f/f.web"
+	other := managedFields("kubectl", metav1.ManagedFieldsOperationApply, `{
+  "f:metadata": {"f:labels": {"f:team": {}}, "f:annotations": {"f:note": {}}}
+}`)
+	hpa := managedFields("hpa", metav1.ManagedFieldsOperationUpdate, `{"f:spec": {"f:replicas": {}}}`)
+
+	// the failed apply added a label and an env var, and took the replicas
+	// over from the autoscaler
+	live := sharedDeployment()
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{other, managedFields(manager, metav1.ManagedFieldsOperationApply, `{
+  "f:metadata": {"f:labels": {"f:app": {}, "f:version": {}}},
+  "f:spec": {
+    "f:replicas": {},
+    "f:template": {"f:spec": {"f:containers": {
+      "k:{\"name\":\"web\"}": {".": {}, "f:env": {}, "f:image": {}, "f:name": {}}
+    }}}
+  }
+}`)})
+
+	t.Run("owned", func(t *testing.T) {
+		snapshot := sharedDeployment()
+		snapshot.SetManagedFields([]metav1.ManagedFieldsEntry{
+			other, hpa,
+			managedFields(manager, metav1.ManagedFieldsOperationApply, `{
+  "f:metadata": {"f:labels": {"f:app": {}}},
+  "f:spec": {"f:template": {"f:spec": {"f:containers": {
+    "k:{\"name\":\"web\"}": {".": {}, "f:image": {}, "f:name": {}}
+  }}}}
+}`),
+		})
+
+		restore, err := restoreObject(snapshot, live, manager)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "web",
+				"namespace": "app",
+				"labels":    map[string]interface{}{"app": "web"},
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(3),
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{map[string]interface{}{"name": "web", "image": "web:1"}},
+					},
+				},
+			},
+		}, restore.Object, "the fields of other managers are left out, the added ones removed")
+	})
+
+	t.Run("not owned", func(t *testing.T) {
+		snapshot := sharedDeployment()
+		snapshot.SetManagedFields([]metav1.ManagedFieldsEntry{other, hpa})
+
+		restore, err := restoreObject(snapshot, live, manager)
+		require.NoError(t, err)
+		containers, _, _ := unstructured.NestedSlice(restore.Object, "spec", "template", "spec", "containers")
+		assert.Equal(t, []interface{}{map[string]interface{}{"name": "web", "image": "web:1"}}, containers, "the env var added is removed")
+		assert.Equal(t, map[string]string{"app": "web"}, restore.GetLabels(), "the label added is removed")
+		assert.Nil(t, restore.GetAnnotations())
+
+		// without the live object, nothing the task owns is restored
+		restore, err = restoreObject(snapshot, nil, manager)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "app"},
+		}, restore.Object)
+	})
+}
@@ -0,0 +1,251 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"cuelang.org/go/cue"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// secretHashAnnotation prefixes the annotation of out.annotations, to set on
+// the pod templates consuming a secret so that they roll out when it changes
+const secretHashAnnotation = "checksum.mantis.io/"
+
+// K8sSecretTask applies a Secret whose values are decrypted from a SOPS
+// file or a file encrypted with the encryption config of TF tasks. Values
+// are only decrypted on apply, and never make it to out, the plan, the run
+// journal or rendered manifests.
+type K8sSecretTask struct{}
+
+func NewK8sSecretTask(val cue.Value) (hofcontext.Runner, error) {
+	return &K8sSecretTask{}, nil
+}
+
+// SecretSource is the source of a K8sSecret task, SOPS or File
+type SecretSource struct {
+	// SOPS is a flat SOPS file, decrypted by the sops CLI
+	SOPS string `json:"sops,omitempty"`
+
+	// File is written by mantis secret encrypt and decrypted with the state
+	// method of Encryption, an encryption block of a TF task
+	File       string                 `json:"file,omitempty"`
+	Encryption map[string]interface{} `json:"encryption,omitempty"`
+
+	// Keys only keeps these values of the file
+	Keys []string `json:"keys,omitempty"`
+}
+
+// SecretOut is the out of a K8sSecret task
+type SecretOut struct {
+	Secret    string `json:"secret"`
+	Namespace string `json:"namespace"`
+
+	// Keys of the Secret, known once applied
+	Keys []string `json:"keys,omitempty"`
+
+	// Hash of the encrypted file, which changes with the values
+	Hash string `json:"hash"`
+
+	// Annotations holding Hash, for the pod templates consuming the Secret:
+	// spec: template: metadata: annotations: db.out.annotations
+	Annotations map[string]string `json:"annotations"`
+}
+
+func (t *K8sSecretTask) Run(ctx *hofcontext.Context) (any, error) {
+	v := ctx.Value
+
+	// Nothing to do for init for Secrets
+	if ctx.Init {
+		return v, nil
+	}
+
+	var manifest map[string]interface{}
+	if err := v.LookupPath(cue.ParsePath("config")).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid Secret config: %v", err)
+	}
+	if manifest == nil {
+		manifest = map[string]interface{}{}
+	}
+	if _, ok := manifest["data"]; ok {
+		return nil, fmt.Errorf("the values of a K8sSecret task come from its %s, not config.data", mantis.MantisSecretSource)
+	}
+	if _, ok := manifest["stringData"]; ok {
+		return nil, fmt.Errorf("the values of a K8sSecret task come from its %s, not config.stringData", mantis.MantisSecretSource)
+	}
+	manifest["apiVersion"], manifest["kind"] = "v1", "Secret"
+	metadata, _ := manifest["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("a K8sSecret task needs config.metadata.name")
+	}
+	namespace, _ := metadata["namespace"].(string)
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	var src SecretSource
+	if err := v.LookupPath(cue.ParsePath(mantis.MantisSecretSource)).Decode(&src); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", mantis.MantisSecretSource, err)
+	}
+	path := src.SOPS
+	if (src.SOPS == "") == (src.File == "") {
+		return nil, fmt.Errorf("%s needs one of sops or file", mantis.MantisSecretSource)
+	} else if src.File != "" {
+		if src.Encryption == nil {
+			return nil, fmt.Errorf("%s.file needs the encryption config it was encrypted with", mantis.MantisSecretSource)
+		}
+		path = src.File
+	}
+
+	var cluster Cluster
+	if cv := v.LookupPath(cue.ParsePath(mantis.MantisTaskCluster)); cv.Exists() {
+		if err := cv.Decode(&cluster); err != nil {
+			return nil, fmt.Errorf("invalid cluster: %v", err)
+		}
+	}
+
+	if ctx.Plan {
+		out, _, err := readSecret(name, namespace, path)
+		if err != nil {
+			return nil, err
+		}
+		// the values are not decrypted to be diffed, the hash tells them apart
+		fmt.Printf("Secret %s/%s will be applied from %s, hash %s\n", namespace, name, path, out.Hash)
+		return v.FillPath(cue.ParsePath(mantis.MantisTaskOuts), out), nil
+
+	} else if ctx.Apply {
+		out, encrypted, err := readSecret(name, namespace, path)
+		if err != nil {
+			return nil, err
+		}
+		values, err := decryptSecret(ctx.GoContext, src, encrypted)
+		if err != nil {
+			return nil, err
+		}
+		data := make(map[string]interface{}, len(values))
+		for k, val := range values {
+			data[k] = base64.StdEncoding.EncodeToString([]byte(val))
+			out.Keys = append(out.Keys, k)
+		}
+		sort.Strings(out.Keys)
+		manifest["data"] = data
+
+		client, err := ClientFor(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
+		}
+		opts, err := applyOptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := client.Apply(ctx.GoContext, string(doc), opts); err != nil {
+			return nil, fmt.Errorf("apply failed. Check if Kubernetes cluster is accessible: %v", err)
+		}
+		fmt.Printf("Applied Secret %s/%s with keys %v\n", namespace, name, out.Keys)
+		return v.FillPath(cue.ParsePath(mantis.MantisTaskOuts), out), nil
+
+	} else if ctx.Destroy {
+		client, err := ClientFor(cluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
+		}
+		doc, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Delete(ctx.GoContext, string(doc)); err != nil {
+			return nil, fmt.Errorf("destroy failed. Check if Kubernetes cluster is accessible: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("unknown command. Need to use one of plan/apply/destroy")
+	}
+	fmt.Println("Operation completed successfully")
+	return v, nil
+}
+
+// readSecret reads the encrypted file of a Secret, returning the out of
+// its task, which holds the hash of the file
+func readSecret(name, namespace, path string) (SecretOut, []byte, error) {
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		return SecretOut{}, nil, err
+	}
+	sum := sha256.Sum256(encrypted)
+	hash := hex.EncodeToString(sum[:])
+	return SecretOut{
+		Secret:      name,
+		Namespace:   namespace,
+		Hash:        hash,
+		Annotations: map[string]string{secretHashAnnotation + truncate(name, 63): hash},
+	}, encrypted, nil
+}
+
+// decryptSecret returns the values of the encrypted file of a source,
+// restricted to its keys when set
+func decryptSecret(ctx context.Context, src SecretSource, encrypted []byte) (map[string]string, error) {
+	var values map[string]interface{}
+	if src.SOPS != "" {
+		var err error
+		if values, err = mantis.DecryptSOPS(ctx, src.SOPS); err != nil {
+			return nil, err
+		}
+	} else {
+		cfg, err := json.Marshal(src.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		decrypted, err := mantis.DecryptSecrets(cfg, encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %v", src.File, err)
+		}
+		values = make(map[string]interface{}, len(decrypted))
+		for k, v := range decrypted {
+			values[k] = v
+		}
+	}
+
+	if len(src.Keys) > 0 {
+		kept := make(map[string]interface{}, len(src.Keys))
+		for _, k := range src.Keys {
+			val, ok := values[k]
+			if !ok {
+				return nil, fmt.Errorf("key %s is not in the secrets file", k)
+			}
+			kept[k] = val
+		}
+		values = kept
+	}
+	return mantis.SecretValues(values)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+var secrets = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+
+// secretEncryption is the encryption block of the source of the task
+const secretEncryption = `{
+	key_provider: pbkdf2: k: passphrase: "correct horse battery staple"
+	method: aes_gcm: m: keys: "${key_provider.pbkdf2.k}"
+	state: method: "${method.aes_gcm.m}"
+}`
+
+// encryptedSecret writes values encrypted with secretEncryption
+func encryptedSecret(t *testing.T, values map[string]string) string {
+	t.Helper()
+	cfg, err := cuecontext.New().CompileString(secretEncryption).MarshalJSON()
+	require.NoError(t, err)
+	data, err := mantis.EncryptSecrets(cfg, values)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "db.enc")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	return path
+}
+
+// runSecretTask runs command with a K8sSecret task applying the values of
+// the file at path to client, returning the task and what it printed
+func runSecretTask(t *testing.T, client *Client, command, path string) (cue.Value, string, error) {
+	t.Helper()
+	cluster := Cluster{Server: "https://" + t.Name()}
+	clientsMu.Lock()
+	clients[cluster] = client
+	clientsMu.Unlock()
+	t.Cleanup(func() {
+		clientsMu.Lock()
+		delete(clients, cluster)
+		clientsMu.Unlock()
+	})
+
+	ctx := hofcontext.New()
+	ctx.FlowName = "f"
+	ctx.BaseTask = &task.BaseTask{ID: "f.db"}
+	ctx.GoContext = context.Background()
+	switch command {
+	case "plan":
+		ctx.Plan = true
+	case "apply":
+		ctx.Apply = true
+	case "destroy":
+		ctx.Destroy = true
+	}
+	ctx.Value = cuecontext.New().CompileString(`
+cluster: server: "https://` + t.Name() + `"
+config: metadata: {name: "db", namespace: "app"}
+source: {
+	file:       "` + path + `"
+	encryption: ` + secretEncryption + `
+}
+`)
+	require.NoError(t, ctx.Value.Err())
+
+	var v cue.Value
+	var err error
+	out := captureStdout(t, func() {
+		var res any
+		res, err = (&K8sSecretTask{}).Run(ctx)
+		if err == nil {
+			v = res.(cue.Value)
+		}
+	})
+	return v, out, err
+}
+
+func TestSecretTaskValuesNotLeaked(t *testing.T) {
+	path := encryptedSecret(t, map[string]string{"user": "admin", "password": "hunter2"})
+	client, dyn := newFakeClient(t)
+	applyReactor(dyn, func(*unstructured.Unstructured) {})
+
+	for _, command := range []string{"plan", "apply"} {
+		v, printed, err := runSecretTask(t, client, command, path)
+		require.NoError(t, err, command)
+		assert.NotContains(t, printed, "hunter2", command)
+
+		out, err := v.LookupPath(cue.ParsePath(mantis.MantisTaskOuts)).MarshalJSON()
+		require.NoError(t, err, command)
+		assert.NotContains(t, string(out), "hunter2", command)
+		assert.NotContains(t, string(out), base64.StdEncoding.EncodeToString([]byte("hunter2")), command)
+	}
+
+	// the Secret applied holds the values
+	obj, err := dyn.Resource(secrets).Namespace("app").Get(context.Background(), "db", metav1.GetOptions{})
+	require.NoError(t, err)
+	password, _, _ := unstructured.NestedString(obj.Object, "data", "password")
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("hunter2")), password)
+}
+
+func TestSecretTaskOut(t *testing.T) {
+	path := encryptedSecret(t, map[string]string{"user": "admin", "password": "hunter2"})
+	client, dyn := newFakeClient(t)
+	applyReactor(dyn, func(*unstructured.Unstructured) {})
+
+	plan, _, err := runSecretTask(t, client, "plan", path)
+	require.NoError(t, err)
+	var planned SecretOut
+	require.NoError(t, plan.LookupPath(cue.ParsePath(mantis.MantisTaskOuts)).Decode(&planned))
+	assert.Empty(t, planned.Keys)
+
+	apply, _, err := runSecretTask(t, client, "apply", path)
+	require.NoError(t, err)
+	var applied SecretOut
+	require.NoError(t, apply.LookupPath(cue.ParsePath(mantis.MantisTaskOuts)).Decode(&applied))
+	assert.Equal(t, []string{"password", "user"}, applied.Keys)
+	assert.Equal(t, planned.Hash, applied.Hash)
+	assert.Equal(t, map[string]string{secretHashAnnotation + "db": applied.Hash}, applied.Annotations)
+
+	// new values change the hash
+	other := encryptedSecret(t, map[string]string{"user": "admin", "password": "hunter3"})
+	plan, _, err = runSecretTask(t, client, "plan", other)
+	require.NoError(t, err)
+	var changed SecretOut
+	require.NoError(t, plan.LookupPath(cue.ParsePath(mantis.MantisTaskOuts)).Decode(&changed))
+	assert.NotEqual(t, applied.Hash, changed.Hash)
+}
+
+func TestSecretTaskDestroyWithoutFile(t *testing.T) {
+	path := encryptedSecret(t, map[string]string{"password": "hunter2"})
+	client, dyn := newFakeClient(t)
+	applyReactor(dyn, func(*unstructured.Unstructured) {})
+	_, _, err := runSecretTask(t, client, "apply", path)
+	require.NoError(t, err)
+
+	// the encrypted file is not needed to delete the Secret
+	require.NoError(t, os.Remove(path))
+	_, _, err = runSecretTask(t, client, "plan", path)
+	assert.Error(t, err)
+	_, _, err = runSecretTask(t, client, "destroy", path)
+	require.NoError(t, err)
+	_, err = dyn.Resource(secrets).Namespace("app").Get(context.Background(), "db", metav1.GetOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// waitInterval is how often an object is fetched until it is ready
+var waitInterval = 2 * time.Second
+
+// defaultWaitTimeout bounds a wait when the task has no timeout
+var defaultWaitTimeout = 5 * time.Minute
+
+// WaitReady fetches obj until it is ready and returns it, or fails when the
+// rollout of obj failed or ctx is done first. Without a deadline on ctx, such
+// as the timeout of the task, the wait is bounded by defaultWaitTimeout.
+func (c *Client) WaitReady(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resourceClient, err := c.resourceClient(obj)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultWaitTimeout)
+		defer cancel()
+	}
+	for {
+		ready, reason, err := isReady(obj)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s %w", obj.GetKind(), obj.GetName(), err)
+		}
+		if ready {
+			return obj, nil
+		}
+		fmt.Printf("Waiting for %s/%s: %s\n", obj.GetKind(), obj.GetName(), reason)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s/%s is not ready, %s: %w", obj.GetKind(), obj.GetName(), reason, ctx.Err())
+		case <-time.After(waitInterval):
+		}
+		cur, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		obj = cur
+	}
+}
+
+// isReady tells whether the controllers of an object caught up with its
+// spec, following kubectl rollout status for workloads, or the reason why
+// not. It fails when the rollout can not complete. Objects without a notion
+// of readiness are ready once applied.
+func isReady(obj *unstructured.Unstructured) (bool, string, error) {
+	generation := obj.GetGeneration()
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < generation {
+		return false, "spec not observed yet", nil
+	}
+
+	status := func(field string) int64 {
+		n, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+		return n
+	}
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+
+	switch obj.GetKind() {
+	case "Service":
+		if typ, _, _ := unstructured.NestedString(obj.Object, "spec", "type"); typ != "LoadBalancer" {
+			return true, "", nil
+		}
+		if ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress"); len(ingress) == 0 {
+			return false, "load balancer not provisioned", nil
+		}
+		return true, "", nil
+	case "PersistentVolumeClaim":
+		if phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase"); phase != "Bound" {
+			return false, "claim not bound", nil
+		}
+		return true, "", nil
+	case "Deployment":
+		if reason, message := condition(obj, "Progressing"); reason == "ProgressDeadlineExceeded" {
+			return false, "", conditionError("exceeded its progress deadline", message)
+		}
+		if updated := status("updatedReplicas"); updated < replicas {
+			return false, fmt.Sprintf("%d/%d replicas updated", updated, replicas), nil
+		}
+		if total, updated := status("replicas"), status("updatedReplicas"); total > updated {
+			return false, fmt.Sprintf("%d old replicas pending termination", total-updated), nil
+		}
+		if available := status("availableReplicas"); available < replicas {
+			return false, fmt.Sprintf("%d/%d replicas available", available, replicas), nil
+		}
+		if s := conditionStatus(obj, "Available"); s != "" && s != "True" {
+			return false, "Available condition is " + s, nil
+		}
+		return true, "", nil
+	case "StatefulSet":
+		if ready := status("readyReplicas"); ready < replicas {
+			return false, fmt.Sprintf("%d/%d replicas ready", ready, replicas), nil
+		}
+		if typ, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type"); typ == "OnDelete" {
+			return true, "", nil
+		}
+		partition, _, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition")
+		if updated := status("updatedReplicas"); updated < replicas-partition {
+			return false, fmt.Sprintf("%d/%d replicas updated", updated, replicas-partition), nil
+		}
+		current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if partition == 0 && current != update {
+			return false, "rolling update not complete", nil
+		}
+		return true, "", nil
+	case "ReplicaSet":
+		if available := status("availableReplicas"); available < replicas {
+			return false, fmt.Sprintf("%d/%d replicas available", available, replicas), nil
+		}
+		return true, "", nil
+	case "DaemonSet":
+		desired := status("desiredNumberScheduled")
+		if updated := status("updatedNumberScheduled"); updated < desired {
+			return false, fmt.Sprintf("%d/%d pods updated", updated, desired), nil
+		}
+		if available := status("numberAvailable"); available < desired {
+			return false, fmt.Sprintf("%d/%d pods available", available, desired), nil
+		}
+		return true, "", nil
+	case "Job":
+		if conditionStatus(obj, "Failed") == "True" {
+			reason, _ := condition(obj, "Failed")
+			return false, "", conditionError("failed", reason)
+		}
+		if conditionStatus(obj, "Complete") != "True" {
+			return false, "job not complete", nil
+		}
+		return true, "", nil
+	}
+
+	// custom resources commonly report a Ready condition
+	if s := conditionStatus(obj, "Ready"); s != "" && s != "True" {
+		return false, "Ready condition is " + s, nil
+	}
+	return true, "", nil
+}
+
+// conditionStatus returns the status of a condition of obj, empty when
+// obj does not report the condition
+func conditionStatus(obj *unstructured.Unstructured, typ string) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if ok && cond["type"] == typ {
+			status, _ := cond["status"].(string)
+			return status
+		}
+	}
+	return ""
+}
+
+// condition returns the reason and message of a condition of obj
+func condition(obj *unstructured.Unstructured, typ string) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if ok && cond["type"] == typ {
+			reason, _ := cond["reason"].(string)
+			message, _ := cond["message"].(string)
+			return reason, message
+		}
+	}
+	return "", ""
+}
+
+func conditionError(what, detail string) error {
+	if detail == "" {
+		return errors.New(what)
+	}
+	return fmt.Errorf("%s: %s", what, detail)
+}
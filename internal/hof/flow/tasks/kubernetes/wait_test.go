@@ -0,0 +1,216 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/restmapper"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeClient returns a client of a fake cluster serving core and apps
+// objects, which holds objects
+func newFakeClient(t *testing.T, objects ...runtime.Object) (*Client, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	interval := waitInterval
+	waitInterval = time.Millisecond
+	t.Cleanup(func() { waitInterval = interval })
+
+	disco := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	disco.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+			{Name: "secrets", Kind: "Secret", Namespaced: true},
+			{Name: "services", Kind: "Service", Namespaced: true},
+		}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true},
+		}},
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+	return &Client{
+		dynamic: dyn,
+		mapper:  restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco)),
+	}, dyn
+}
+
+// newDeployment returns a deployment web whose rollout is complete,
+// with available of its replicas available
+func newDeployment(replicas, available int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "app"},
+		"spec":       map[string]interface{}{"replicas": replicas},
+		"status": map[string]interface{}{
+			"replicas":          replicas,
+			"updatedReplicas":   replicas,
+			"availableReplicas": available,
+		},
+	}}
+}
+
+func TestWaitReady(t *testing.T) {
+	client, _ := newFakeClient(t, newDeployment(2, 2))
+
+	ready, err := client.WaitReady(context.Background(), newDeployment(2, 0))
+	require.NoError(t, err)
+	replicas, _, _ := unstructured.NestedInt64(ready.Object, "status", "availableReplicas")
+	assert.Equal(t, int64(2), replicas)
+}
+
+func TestWaitReadyDeleted(t *testing.T) {
+	client, _ := newFakeClient(t)
+
+	_, err := client.WaitReady(context.Background(), newDeployment(2, 0))
+	assert.ErrorContains(t, err, "failed to get Deployment/web")
+}
+
+func TestWaitReadyTimeout(t *testing.T) {
+	client, _ := newFakeClient(t, newDeployment(2, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := client.WaitReady(ctx, newDeployment(2, 0))
+	assert.ErrorContains(t, err, "Deployment/web is not ready, 1/2 replicas available")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitReadyDefaultTimeout(t *testing.T) {
+	client, _ := newFakeClient(t, newDeployment(2, 1))
+	timeout := defaultWaitTimeout
+	defaultWaitTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { defaultWaitTimeout = timeout })
+
+	_, err := client.WaitReady(context.Background(), newDeployment(2, 0))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWaitReadyProgressDeadline(t *testing.T) {
+	stuck := newDeployment(2, 1)
+	unstructured.SetNestedSlice(stuck.Object, []interface{}{map[string]interface{}{
+		"type":    "Progressing",
+		"status":  "False",
+		"reason":  "ProgressDeadlineExceeded",
+		"message": `ReplicaSet "web-5d9c" has timed out progressing.`,
+	}}, "status", "conditions")
+	client, _ := newFakeClient(t, stuck)
+
+	_, err := client.WaitReady(context.Background(), newDeployment(2, 0))
+	assert.EqualError(t, err, `Deployment/web exceeded its progress deadline: ReplicaSet "web-5d9c" has timed out progressing.`)
+}
+
+func TestIsReady(t *testing.T) {
+	obj := func(kind string, spec, status map[string]interface{}) *unstructured.Unstructured {
+		o := map[string]interface{}{"kind": kind, "metadata": map[string]interface{}{"name": "x", "generation": int64(2)}}
+		if spec != nil {
+			o["spec"] = spec
+		}
+		if status != nil {
+			o["status"] = status
+		}
+		return &unstructured.Unstructured{Object: o}
+	}
+	conditions := func(conds ...map[string]interface{}) []interface{} {
+		out := make([]interface{}, len(conds))
+		for i, c := range conds {
+			out[i] = c
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		ready  bool
+		reason string
+		err    string
+	}{
+		{"configmap", obj("ConfigMap", nil, nil), true, "", ""},
+		{"spec not observed", obj("Deployment", nil, map[string]interface{}{"observedGeneration": int64(1)}), false, "spec not observed yet", ""},
+		{"deployment not updated", obj("Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+			"replicas": int64(3), "updatedReplicas": int64(1), "readyReplicas": int64(3), "availableReplicas": int64(3),
+		}), false, "1/3 replicas updated", ""},
+		{"deployment old replicas", obj("Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+			"replicas": int64(4), "updatedReplicas": int64(3), "availableReplicas": int64(3),
+		}), false, "1 old replicas pending termination", ""},
+		{"deployment not available", obj("Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+			"replicas": int64(3), "updatedReplicas": int64(3), "readyReplicas": int64(3), "availableReplicas": int64(2),
+		}), false, "2/3 replicas available", ""},
+		{"deployment available condition", obj("Deployment", nil, map[string]interface{}{
+			"replicas": int64(1), "updatedReplicas": int64(1), "availableReplicas": int64(1),
+			"conditions": conditions(map[string]interface{}{"type": "Available", "status": "False"}),
+		}), false, "Available condition is False", ""},
+		{"deployment ready", obj("Deployment", nil, map[string]interface{}{
+			"replicas": int64(1), "updatedReplicas": int64(1), "availableReplicas": int64(1),
+			"conditions": conditions(map[string]interface{}{"type": "Available", "status": "True"}),
+		}), true, "", ""},
+		{"deployment scaled to zero", obj("Deployment", map[string]interface{}{"replicas": int64(0)}, nil), true, "", ""},
+		{"deployment deadline", obj("Deployment", nil, map[string]interface{}{
+			"conditions": conditions(map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}),
+		}), false, "", "exceeded its progress deadline"},
+		{"statefulset not ready", obj("StatefulSet", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+			"readyReplicas": int64(1),
+		}), false, "1/2 replicas ready", ""},
+		{"statefulset rolling", obj("StatefulSet", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+			"readyReplicas": int64(2), "updatedReplicas": int64(2), "currentRevision": "a", "updateRevision": "b",
+		}), false, "rolling update not complete", ""},
+		{"statefulset partition", obj("StatefulSet", map[string]interface{}{"replicas": int64(3), "updateStrategy": map[string]interface{}{
+			"type": "RollingUpdate", "rollingUpdate": map[string]interface{}{"partition": int64(2)},
+		}}, map[string]interface{}{
+			"readyReplicas": int64(3), "updatedReplicas": int64(1), "currentRevision": "a", "updateRevision": "b",
+		}), true, "", ""},
+		{"statefulset on delete", obj("StatefulSet", map[string]interface{}{"replicas": int64(1), "updateStrategy": map[string]interface{}{"type": "OnDelete"}}, map[string]interface{}{
+			"readyReplicas": int64(1), "currentRevision": "a", "updateRevision": "b",
+		}), true, "", ""},
+		{"daemonset not updated", obj("DaemonSet", nil, map[string]interface{}{
+			"desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(2), "numberAvailable": int64(3),
+		}), false, "2/3 pods updated", ""},
+		{"daemonset not available", obj("DaemonSet", nil, map[string]interface{}{
+			"desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(2),
+		}), false, "2/3 pods available", ""},
+		{"replicaset", obj("ReplicaSet", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{"availableReplicas": int64(2)}), true, "", ""},
+		{"job failed", obj("Job", nil, map[string]interface{}{
+			"conditions": conditions(map[string]interface{}{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"}),
+		}), false, "", "failed: BackoffLimitExceeded"},
+		{"job running", obj("Job", nil, nil), false, "job not complete", ""},
+		{"load balancer", obj("Service", map[string]interface{}{"type": "LoadBalancer"}, nil), false, "load balancer not provisioned", ""},
+		{"custom resource", obj("Certificate", nil, map[string]interface{}{
+			"conditions": conditions(map[string]interface{}{"type": "Ready", "status": "False"}),
+		}), false, "Ready condition is False", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, reason, err := isReady(tt.obj)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.ready, ready)
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"os"
 	"regexp"
 	"strings"
-	"sync"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/load"
@@ -50,19 +49,19 @@ func FormatValue(value interface{}) string {
 		return fmt.Sprintf("%v", v)
 	}
 }
-func populateTemplate(template string, vars *sync.Map) (string, error) {
-	// Define a regex pattern to match @var(id_here)
-	re := regexp.MustCompile(`@var\((\w+)\)`)
+func populateTemplate(template string, flow string, vars *hofcontext.VarScope) (string, error) {
+	// Define a regex pattern to match @var(id_here), @var(task.id_here) or @var(flow.task.id_here)
+	re := regexp.MustCompile(`@var\(([\w.]+)\)`)
 
-	// Function to replace each match with the corresponding value from the sync.Map
+	// Function to replace each match with the corresponding value from the var scope
 	result := re.ReplaceAllStringFunc(template, func(match string) string {
 		// Extract the variable name using capturing groups
 		matches := re.FindStringSubmatch(match)
 		if len(matches) > 1 {
 			varName := matches[1] // This will be the captured variable name
 
-			// Load the value from sync.Map
-			if value, exists := vars.Load(varName); exists {
+			// Resolve the value in the flow's var scope
+			if value, exists := vars.Lookup(flow, varName); exists {
 				// Use the FormatValue function to handle the formatting
 				return FormatValue(value)
 			}
@@ -92,7 +91,7 @@ func (T *LocalEvaluator) Run(ctx *hofcontext.Context) (interface{}, error) {
 			return err
 		}
 
-		populatedCueExpr, err := populateTemplate(exprStr, ctx.GlobalVars)
+		populatedCueExpr, err := populateTemplate(exprStr, ctx.FlowName, ctx.Vars)
 		if err != nil {
 			return fmt.Errorf("failed to update variables in CUE file: %w", err)
 		}
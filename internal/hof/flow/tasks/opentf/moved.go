@@ -0,0 +1,97 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ *
+ * Attribution:
+ * This work is based on code from https://github.com/hofstadter-io/hof, licensed under the Apache License 2.0.
+ */
+
+package opentf
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+// movedStatePath returns the state file of the task, following its moved
+// declaration when the task was renamed or restructured:
+//
+//	moved: {from: "old.task.path"}
+//	moved: [{from: "older.path"}, {from: "old.task.path"}]
+//
+// Apply and destroy move the old state over before running, plan only
+// reads it, like tofu does for moved blocks within a configuration.
+func movedStatePath(ctx *hofcontext.Context) (string, error) {
+	id := ctx.BaseTask.ID
+
+	from, err := movedFrom(ctx.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s in task %s: %v", mantis.MantisTaskMoved, id, err)
+	}
+	if len(from) == 0 {
+		return createStatePath(id), nil
+	}
+
+	source, blocked := mantis.PendingMove(".", id, from)
+	for _, b := range blocked {
+		ctx.AddWarning(fmt.Sprintf("state of task %s was not moved to %s, which already has state. Remove one of them with mantis state mv-task or by hand\n", b, id))
+	}
+	if source == "" {
+		return createStatePath(id), nil
+	}
+
+	if ctx.Plan {
+		fmt.Printf("Task %s: state will be moved from %s\n", id, source)
+		return createStatePath(source), nil
+	}
+
+	if err := mantis.MoveTaskState(".", source, id); err != nil {
+		return "", fmt.Errorf("failed to move state of task %s to %s: %v", source, id, err)
+	}
+	fmt.Printf("Task %s: moved state from %s\n", id, source)
+	return createStatePath(id), nil
+}
+
+func movedFrom(val cue.Value) ([]string, error) {
+	moved := val.LookupPath(cue.ParsePath(mantis.MantisTaskMoved))
+	if !moved.Exists() {
+		return nil, nil
+	}
+
+	var decls []cue.Value
+	switch moved.Kind() {
+	case cue.StructKind:
+		decls = append(decls, moved)
+	case cue.ListKind:
+		iter, err := moved.List()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			decls = append(decls, iter.Value())
+		}
+	default:
+		return nil, fmt.Errorf("must be a struct or a list of structs with a from field")
+	}
+
+	var from []string
+	for _, d := range decls {
+		f, err := d.LookupPath(cue.ParsePath("from")).String()
+		if err != nil {
+			return nil, err
+		}
+		from = append(from, f)
+	}
+	return from, nil
+}
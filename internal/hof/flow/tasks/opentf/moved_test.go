@@ -0,0 +1,90 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package opentf
+
+import (
+	"os"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/hof/flow/task"
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis"
+)
+
+func TestMovedFrom(t *testing.T) {
+	ctx := cuecontext.New()
+
+	from, err := movedFrom(ctx.CompileString(`config: {}`))
+	require.NoError(t, err)
+	assert.Empty(t, from)
+
+	from, err = movedFrom(ctx.CompileString(`moved: from: "get_subnets"`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"get_subnets"}, from)
+
+	from, err = movedFrom(ctx.CompileString(`moved: [{from: "a"}, {from: "b"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, from)
+
+	_, err = movedFrom(ctx.CompileString(`moved: "a"`))
+	assert.ErrorContains(t, err, "must be a struct or a list")
+
+	_, err = movedFrom(ctx.CompileString(`moved: to: "a"`))
+	assert.Error(t, err)
+}
+
+func TestMovedStatePath(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	require.NoError(t, os.MkdirAll("mantis_state", 0755))
+	require.NoError(t, os.WriteFile(mantis.TaskStatePath("get_subnets"), []byte("state"), 0644))
+
+	newCtx := func(plan bool) *hofcontext.Context {
+		ctx := hofcontext.New()
+		ctx.Value = cuecontext.New().CompileString(`moved: from: "get_subnets"`)
+		ctx.BaseTask = &task.BaseTask{ID: "network.subnets"}
+		ctx.Plan = plan
+		ctx.Apply = !plan
+		return ctx
+	}
+
+	// plan reads the old state and leaves it in place
+	path, err := movedStatePath(newCtx(true))
+	require.NoError(t, err)
+	assert.Equal(t, mantis.TaskStatePath("get_subnets"), path)
+	assert.FileExists(t, mantis.TaskStatePath("get_subnets"))
+
+	// apply moves it over
+	path, err = movedStatePath(newCtx(false))
+	require.NoError(t, err)
+	assert.Equal(t, mantis.TaskStatePath("network.subnets"), path)
+	assert.NoFileExists(t, mantis.TaskStatePath("get_subnets"))
+	assert.FileExists(t, mantis.TaskStatePath("network.subnets"))
+
+	// and once moved, the declaration is a no-op
+	require.NoError(t, os.WriteFile(mantis.TaskStatePath("get_subnets"), []byte("stale"), 0644))
+	ctx := newCtx(false)
+	path, err = movedStatePath(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, mantis.TaskStatePath("network.subnets"), path)
+	assert.FileExists(t, mantis.TaskStatePath("get_subnets"))
+	require.Len(t, ctx.FlowWarnings, 1)
+	assert.Contains(t, ctx.FlowWarnings[0], "state of task get_subnets was not moved")
+}
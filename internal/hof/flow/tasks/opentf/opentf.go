@@ -16,8 +16,9 @@
 package opentf
 
 import (
-	"context"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 
 	"cuelang.org/go/cue"
@@ -76,6 +77,15 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 		return nil, fmt.Errorf("failed to load CLI configuration: %v", diags.Err())
 	}
 
+	// Providers are installed once into a cache shared by every task,
+	// then linked into each task's working directory
+	if config.PluginCacheDir == "" {
+		config.PluginCacheDir = mantis.MantisPluginCacheDir
+	}
+	if err := os.MkdirAll(config.PluginCacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create plugin cache directory: %v", err)
+	}
+
 	// Initialize services
 	services := disco.NewWithCredentialsSource(nil) // Simplified for example
 
@@ -95,9 +105,12 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 	// Initialize the backends.
 	backendInit.Init(services)
 
-	var std_ctx context.Context
-	backendStatePath :=
-		fmt.Sprintf("./terraform/back_%s.tfstate", ctx.BaseTask.ID)
+	backendStatePath := mantis.TaskBackendStatePath(ctx.BaseTask.ID)
+
+	workDir, err := mantis.EnsureTaskWorkDir(ctx.BaseTask.ID)
+	if err != nil {
+		return nil, err
+	}
 
 	taskPath := ctx.BaseTask.ID
 	configDetails := &configs.MantisConfig{
@@ -105,9 +118,23 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 		Content:          scriptBytes,
 		Format:           "json",
 		BackendStatePath: backendStatePath,
+		WorkingDir:       workDir,
+	}
+
+	// point tofu diagnostics at the task's CUE source rather than the generated JSON
+	if sourceMap, err := newSourceMap(ctx.BaseTask.Orig.LookupPath(cue.ParsePath("config")), scriptBytes, configDetails.Filename()); err == nil {
+		configDetails.SourceMap = sourceMap
 	}
 	// Initialize commands
-	commandsFactory := utils.InitCommandsWrapper(std_ctx, "", streams, config, services, providerSrc, providerDevOverrides, unmanagedProviders, configDetails)
+	commandsFactory := utils.InitCommandsWrapper(ctx.GoContext, "", streams, config, services, providerSrc, providerDevOverrides, unmanagedProviders, configDetails)
+
+	var statePath string
+	if ctx.Plan || ctx.Apply || ctx.Destroy {
+		if statePath, err = movedStatePath(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	if ctx.Plan {
 		// Retrieve the 'plan' command from the commandsFactory using the appropriate key
 		planCommandFactory, exists := commandsFactory["plan"]
@@ -130,7 +157,7 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 		// Create and populate the Apply arguments
 		planArgs := &arguments.Plan{
 			State: &arguments.State{
-				StatePath: createStatePath(ctx.BaseTask.ID),
+				StatePath: statePath,
 			},
 		}
 
@@ -146,13 +173,18 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 		parsedVariables := sync.Map{}
 		// Create a new TFContext with the parsedVariables
 		tfContext := hofcontext.NewTFContext(&parsedVariables)
+		tfContext.Unknown = unknownVariables(script)
 
-		_, err = planCommand.RunAPI(rawArgs, tfContext)
+		op, err := planCommand.RunAPI(rawArgs, tfContext)
 		if err != nil {
 			return nil, fmt.Errorf("failed to execute apply command with exit status %d", err)
 		}
+		changes := !op.PlanEmpty
+		ctx.BaseTask.Changes = &changes
 		var parsedVariablesMap map[string]interface{}
 		parsedVariablesMap, _ = convertCtyToGo(&parsedVariables)
+		ctx.Unknown = hofcontext.UnknownPaths(parsedVariablesMap)
+		ctx.Sensitive = tfContext.Sensitive()
 		// fmt.Printf("Parsed Variables: %+v\n", parsedVariablesMap)
 		// v.FillPath(cue.ParsePath("out"), parsedVariables)
 		// Attempt to fill the path with the new value
@@ -189,7 +221,7 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 		// Create and populate the Apply arguments
 		applyArgs := &arguments.Apply{
 			State: &arguments.State{
-				StatePath: createStatePath(ctx.BaseTask.ID),
+				StatePath: statePath,
 			},
 		}
 		// Execute the PlanCommand with the configuration file path
@@ -213,6 +245,7 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 		}
 		var parsedVariablesMap map[string]interface{}
 		parsedVariablesMap, _ = convertCtyToGo(&parsedVariables)
+		ctx.Sensitive = tfContext.Sensitive()
 		// fmt.Printf("Parsed Variables: %+v\n", parsedVariablesMap)
 		// v.FillPath(cue.ParsePath("out"), parsedVariables)
 		// Attempt to fill the path with the new value
@@ -245,7 +278,15 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 			return nil, fmt.Errorf("error asserting command type to *command.PlanCommand")
 		}
 
+		// start from the provider versions pinned across all tasks
+		if err := mantis.SeedTaskLocks(".", configDetails.WorkingDir); err != nil {
+			return nil, fmt.Errorf("failed to seed provider locks: %v", err)
+		}
+
+		// tasks initialize in parallel, but install into the same cache
+		unlock := mantis.LockPluginCache(config.PluginCacheDir)
 		retval := initCommand.Run([]string{"-reconfigure"})
+		unlock()
 		if retval < 0 {
 			return nil, fmt.Errorf("error Initializing")
 		}
@@ -259,8 +300,24 @@ func (t *TFTask) Run(ctx *hofcontext.Context) (any, error) {
 	return nil, nil
 }
 
+// unknownVariables lists the variables which the tasker declared
+// for values of other tasks which are only known after apply
+func unknownVariables(config cue.Value) []string {
+	iter, err := config.LookupPath(cue.ParsePath("variable")).Fields()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for iter.Next() {
+		if name := iter.Selector().Unquoted(); strings.HasPrefix(name, mantis.MantisUnknownVarPrefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func createStatePath(taskID string) string {
-	return fmt.Sprintf(mantis.MantisStateFilePath, taskID)
+	return mantis.TaskStatePath(taskID)
 }
 
 func convertCtyToGo(input *sync.Map) (map[string]interface{}, error) {
@@ -339,6 +396,9 @@ func convertSlice(slice []interface{}) ([]interface{}, error) {
 }
 
 func ctyValueToGo(v cty.Value) (interface{}, error) {
+	if !v.IsKnown() {
+		return hofcontext.NewUnknownValue(v.Type()), nil
+	}
 	if v.IsNull() {
 		return nil, nil
 	}
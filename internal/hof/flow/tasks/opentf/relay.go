@@ -73,8 +73,8 @@ func (t *RelayTask) Run(ctx *hofcontext.Context) (any, error) {
 			if err := value.Decode(&extracted); err != nil {
 				return nil, fmt.Errorf("failed to decode value for %s: %v", varName, err)
 			}
-			// add them to the GlobalVars
-			ctx.GlobalVars.Store(varName, extracted)
+			// add them to the flow's var scope
+			ctx.Vars.Store(ctx.FlowName, ctx.TaskName(ctx.BaseTask.ID), varName, extracted, false)
 		} else {
 			return nil, fmt.Errorf("variable %s not found in script", varName)
 		}
@@ -0,0 +1,193 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package opentf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/token"
+
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+// sourceMap maps byte ranges of the JSON generated from a task config
+// back to the CUE positions the values were defined at.
+type sourceMap struct {
+	filename string
+	entries  []sourceEntry
+	sources  map[string][]byte
+}
+
+type sourceEntry struct {
+	start, end int
+	pos        token.Pos
+}
+
+var _ tfdiags.SourceMapper = (*sourceMap)(nil)
+
+// newSourceMap walks the generated JSON, which tofu parses as filename,
+// and resolves the CUE position of every object member and list element.
+func newSourceMap(config cue.Value, content []byte, filename string) (*sourceMap, error) {
+	m := &sourceMap{
+		filename: filename,
+		sources:  make(map[string][]byte),
+	}
+
+	w := &jsonWalker{
+		content: content,
+		dec:     json.NewDecoder(bytes.NewReader(content)),
+		add: func(start, end int, path []cue.Selector) {
+			pos := lookupPos(config, path)
+			if !pos.IsValid() || pos.Filename() == "" {
+				return
+			}
+			m.entries = append(m.entries, sourceEntry{start: start, end: end, pos: pos})
+			if _, ok := m.sources[pos.Filename()]; !ok {
+				if src, err := os.ReadFile(pos.Filename()); err == nil {
+					m.sources[pos.Filename()] = src
+				}
+			}
+		},
+	}
+	w.dec.UseNumber()
+	if err := w.walk(nil, w.offset()); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// lookupPos returns the position of the value at path, falling back
+// to its closest parent when the value has no position of its own.
+func lookupPos(config cue.Value, path []cue.Selector) token.Pos {
+	for i := len(path); i >= 0; i-- {
+		pos := config.LookupPath(cue.MakePath(path[:i]...)).Pos()
+		if pos.IsValid() {
+			return pos
+		}
+	}
+	return token.NoPos
+}
+
+func (m *sourceMap) MapRange(rng tfdiags.SourceRange) (tfdiags.SourceRange, bool) {
+	if rng.Filename != m.filename {
+		return rng, false
+	}
+
+	// the innermost value containing the start of the range
+	var found *sourceEntry
+	for i := range m.entries {
+		e := &m.entries[i]
+		if e.start <= rng.Start.Byte && rng.Start.Byte < e.end {
+			if found == nil || e.end-e.start < found.end-found.start {
+				found = e
+			}
+		}
+	}
+	if found == nil {
+		return rng, false
+	}
+
+	pos := found.pos
+	start := tfdiags.SourcePos{Line: pos.Line(), Column: pos.Column(), Byte: pos.Offset()}
+	end := start
+
+	// highlight up to the end of the line in the CUE source
+	if src, ok := m.sources[pos.Filename()]; ok && pos.Offset() < len(src) {
+		n := bytes.IndexByte(src[pos.Offset():], '\n')
+		if n < 0 {
+			n = len(src) - pos.Offset()
+		}
+		n = len(bytes.TrimRight(src[pos.Offset():pos.Offset()+n], " \t\r"))
+		end.Byte += n
+		end.Column += n
+	}
+
+	return tfdiags.SourceRange{Filename: pos.Filename(), Start: start, End: end}, true
+}
+
+func (m *sourceMap) Sources() map[string][]byte {
+	return m.sources
+}
+
+// jsonWalker tracks the byte offsets of values while decoding JSON tokens.
+type jsonWalker struct {
+	content []byte
+	dec     *json.Decoder
+	add     func(start, end int, path []cue.Selector)
+}
+
+// offset returns the start of the next token
+func (w *jsonWalker) offset() int {
+	off := int(w.dec.InputOffset())
+	for off < len(w.content) {
+		switch w.content[off] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+// walk decodes one value, start is the offset of its member name, if any
+func (w *jsonWalker) walk(path []cue.Selector, start int) error {
+	tok, err := w.dec.Token()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for w.dec.More() {
+			keyStart := w.offset()
+			key, err := w.dec.Token()
+			if err != nil {
+				return err
+			}
+			label, _ := key.(string)
+			if err := w.walk(appendSelector(path, cue.Str(label)), keyStart); err != nil {
+				return err
+			}
+		}
+		if _, err := w.dec.Token(); err != nil {
+			return err
+		}
+	case json.Delim('['):
+		for i := 0; w.dec.More(); i++ {
+			if err := w.walk(appendSelector(path, cue.Index(i)), w.offset()); err != nil {
+				return err
+			}
+		}
+		if _, err := w.dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	w.add(start, int(w.dec.InputOffset()), path)
+	return nil
+}
+
+func appendSelector(path []cue.Selector, sel cue.Selector) []cue.Selector {
+	p := make([]cue.Selector, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, sel)
+}
@@ -0,0 +1,87 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package opentf
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/tfdiags"
+)
+
+func TestJSONWalker(t *testing.T) {
+	content := []byte(`{"a": {"b": [1, {"c": "x"}]}, "d": true}`)
+
+	spans := map[string]string{}
+	w := &jsonWalker{
+		content: content,
+		add: func(start, end int, path []cue.Selector) {
+			spans[cue.MakePath(path...).String()] = string(content[start:end])
+		},
+	}
+	w.dec = json.NewDecoder(bytes.NewReader(content))
+	require.NoError(t, w.walk(nil, w.offset()))
+
+	assert.Equal(t, map[string]string{
+		"":         string(content),
+		"a":        `"a": {"b": [1, {"c": "x"}]}`,
+		"a.b":      `"b": [1, {"c": "x"}]`,
+		"a.b[0]":   `1`,
+		"a.b[1]":   `{"c": "x"}`,
+		"a.b[1].c": `"c": "x"`,
+		"d":        `"d": true`,
+	}, spans)
+}
+
+func TestSourceMap(t *testing.T) {
+	src := "resource: null_resource: a: {\n\ttriggers: x: \"1\"\n\tcount: 2\n}\n"
+	file := filepath.Join(t.TempDir(), "main.tf.cue")
+	require.NoError(t, os.WriteFile(file, []byte(src), 0644))
+
+	config := cuecontext.New().CompileString(src, cue.Filename(file))
+	require.NoError(t, config.Err())
+	content, err := config.MarshalJSON()
+	require.NoError(t, err)
+
+	m, err := newSourceMap(config, content, "mantis.json")
+	require.NoError(t, err)
+	assert.Contains(t, m.Sources(), file)
+
+	at := func(needle string) tfdiags.SourceRange {
+		off := bytes.Index(content, []byte(needle))
+		require.GreaterOrEqual(t, off, 0, needle)
+		return tfdiags.SourceRange{Filename: "mantis.json", Start: tfdiags.SourcePos{Byte: off}}
+	}
+
+	rng, ok := m.MapRange(at(`"count"`))
+	require.True(t, ok)
+	assert.Equal(t, file, rng.Filename)
+	assert.Equal(t, 3, rng.Start.Line)
+	assert.Equal(t, "count: 2", src[rng.Start.Byte:rng.End.Byte])
+
+	rng, ok = m.MapRange(at(`"1"`))
+	require.True(t, ok)
+	assert.Equal(t, 2, rng.Start.Line)
+
+	// ranges of other files are left alone
+	_, ok = m.MapRange(tfdiags.SourceRange{Filename: "main.tf"})
+	assert.False(t, ok)
+}
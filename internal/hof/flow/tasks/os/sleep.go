@@ -60,7 +60,15 @@ func (T *Sleep) Run(ctx *hofcontext.Context) (interface{}, error) {
 		return nil, ferr
 	}
 
-	time.Sleep(D)
+	if ctx.GoContext != nil {
+		select {
+		case <-time.After(D):
+		case <-ctx.GoContext.Done():
+			return nil, ctx.GoContext.Err()
+		}
+	} else {
+		time.Sleep(D)
+	}
 
 	var res interface{}
 	func() {
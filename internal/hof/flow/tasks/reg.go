@@ -48,6 +48,9 @@ func RegisterDefaults(context *hofcontext.Context) {
 	context.Register("db.Call", db.NewCall)
 	context.Register("mantis.core.TF", opentf.NewTFTask)
 	context.Register("mantis.core.K8s", kubernetes.NewK8sTask)
+	context.Register("mantis.core.K8sJob", kubernetes.NewK8sJobTask)
+	context.Register("mantis.core.Helm", kubernetes.NewHelmTask)
+	context.Register("mantis.core.K8sSecret", kubernetes.NewK8sSecretTask)
 	context.Register("mantis.core.Eval", mantis.NewLocalEvaluator)
 	context.Register("mantis.core.Relay", opentf.NewRelayTask)
 	context.Register("hof.Template", hof.NewHofTemplate)
@@ -0,0 +1,252 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/terraform-svchost/disco"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/backend"
+	backendInit "github.com/opentofu/opentofu/internal/backend/init"
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
+	"github.com/opentofu/opentofu/internal/states/statemgr"
+)
+
+// AdoptOptions selects the Terraform state adopted by a task
+type AdoptOptions struct {
+	// Task is the ID of the TF task taking over the resources
+	Task string
+
+	// From is a state file, - for stdin, or the directory of the
+	// Terraform root module whose backend holds the state
+	From string
+
+	// Workspace of the root module in From, default when empty
+	Workspace string
+
+	// TaskConfig is the JSON of the task's config. Its encryption block,
+	// merged with TF_ENCRYPTION, encrypts the state of the task.
+	TaskConfig []byte
+
+	// Addresses keeps only the resources they contain, such as
+	// module.vpc or aws_subnet.private, so that one root module can
+	// be split across tasks. Everything is adopted when empty.
+	Addresses []string
+}
+
+// AdoptState copies resources of an existing Terraform state into the state
+// of a task, next to the resources it already manages. The source is left
+// untouched. It returns the addresses of the adopted resource instances.
+func AdoptState(root string, opts AdoptOptions) ([]string, error) {
+	var targets []addrs.Targetable
+	for _, a := range opts.Addresses {
+		target, diags := addrs.ParseTargetStr(a)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid address %q: %v", a, diags.Err())
+		}
+		targets = append(targets, target.Subject)
+	}
+
+	taskConfig := opts.TaskConfig
+	if len(taskConfig) == 0 {
+		taskConfig = []byte("{}")
+	}
+	enc, err := TaskEncryption(taskConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := readSourceState(opts.From, opts.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, fmt.Errorf("no state found in %s", opts.From)
+	}
+
+	path := filepath.Join(root, TaskStatePath(opts.Task))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	mgr := statemgr.NewFilesystem(path, enc.State())
+	mgr.SetBackupPath(path + ".backup")
+	if err := mgr.RefreshState(); err != nil {
+		return nil, err
+	}
+	state := mgr.State()
+	if state == nil {
+		state = states.NewState()
+	}
+
+	var adopted []string
+	for _, ms := range source.Modules {
+		for _, rs := range ms.Resources {
+			for key, is := range rs.Instances {
+				addr := rs.Addr.Instance(key)
+				if !contains(targets, addr) {
+					continue
+				}
+				if state.ResourceInstance(addr) != nil {
+					return nil, fmt.Errorf("%s is already in the state of task %s", addr, opts.Task)
+				}
+
+				dst := state.EnsureModule(ms.Addr)
+				if is.Current != nil {
+					dst.SetResourceInstanceCurrent(addr.Resource, is.Current.DeepCopy(), rs.ProviderConfig)
+				}
+				for dk, obj := range is.Deposed {
+					dst.SetResourceInstanceDeposed(addr.Resource, dk, obj.DeepCopy(), rs.ProviderConfig)
+				}
+				adopted = append(adopted, addr.String())
+			}
+		}
+	}
+	if len(adopted) == 0 {
+		return nil, fmt.Errorf("no resources of %s match %v", opts.From, opts.Addresses)
+	}
+
+	if err := mgr.WriteState(state); err != nil {
+		return nil, err
+	}
+	if err := mgr.PersistState(nil); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(adopted)
+	return adopted, nil
+}
+
+func contains(targets []addrs.Targetable, addr addrs.AbsResourceInstance) bool {
+	if len(targets) == 0 {
+		return true
+	}
+	for _, t := range targets {
+		if t.TargetContains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSourceState reads the state to adopt from a file, stdin, or the
+// backend configured by a Terraform root module. A state file is decrypted
+// with TF_ENCRYPTION, the state of a root module with its own encryption
+// block merged with TF_ENCRYPTION, as tofu would.
+func readSourceState(from, workspace string) (*states.State, error) {
+	if workspace == "" {
+		workspace = backend.DefaultStateName
+	}
+
+	if from == "-" {
+		enc, err := envEncryption(nil)
+		if err != nil {
+			return nil, err
+		}
+		return readStateFile(os.Stdin, enc.State())
+	}
+
+	info, err := os.Stat(from)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		enc, err := envEncryption(nil)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(from)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readStateFile(f, enc.State())
+	}
+
+	mod, diags := configs.NewParser(nil).LoadConfigDir(from)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to load the root module in %s: %v", from, diags.Error())
+	}
+	enc, err := envEncryption(mod.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption of the root module in %s: %w", from, err)
+	}
+
+	// without a backend block, the state is local to the module
+	if mod.Backend == nil {
+		path := filepath.Join(from, "terraform.tfstate")
+		if workspace != backend.DefaultStateName {
+			path = filepath.Join(from, "terraform.tfstate.d", workspace, "terraform.tfstate")
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readStateFile(f, enc.State())
+	}
+
+	b, err := configureBackend(mod.Backend, enc.State())
+	if err != nil {
+		return nil, err
+	}
+	mgr, err := b.StateMgr(workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workspace %q of the %s backend: %v", workspace, mod.Backend.Type, err)
+	}
+	if err := mgr.RefreshState(); err != nil {
+		return nil, fmt.Errorf("failed to read state from the %s backend: %v", mod.Backend.Type, err)
+	}
+	return mgr.State(), nil
+}
+
+func readStateFile(r io.Reader, enc encryption.StateEncryption) (*states.State, error) {
+	sf, err := statefile.Read(r, enc)
+	if err != nil {
+		return nil, err
+	}
+	return sf.State, nil
+}
+
+// configureBackend sets up the backend of a root module like tofu init
+// does, without storing its configuration in a working directory
+func configureBackend(c *configs.Backend, enc encryption.StateEncryption) (backend.Backend, error) {
+	backendInit.Init(disco.New())
+	f := backendInit.Backend(c.Type)
+	if f == nil {
+		return nil, fmt.Errorf("unknown backend %q", c.Type)
+	}
+	b := f(enc)
+
+	val, hclDiags := hcldec.Decode(c.Config, b.ConfigSchema().NoneRequired().DecoderSpec(), nil)
+	if hclDiags.HasErrors() {
+		return nil, fmt.Errorf("invalid %s backend configuration: %v", c.Type, hclDiags.Error())
+	}
+	val, diags := b.PrepareConfig(val)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid %s backend configuration: %v", c.Type, diags.Err())
+	}
+	if diags := b.Configure(val); diags.HasErrors() {
+		return nil, fmt.Errorf("failed to configure the %s backend: %v", c.Type, diags.Err())
+	}
+	return b, nil
+}
@@ -0,0 +1,132 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/states"
+	"github.com/opentofu/opentofu/internal/states/statefile"
+)
+
+// writeSourceState writes a state file with a subnet in module.vpc and a
+// bucket in the root module, encrypted by enc
+func writeSourceState(t *testing.T, path string, enc encryption.StateEncryption) {
+	state := states.BuildState(func(s *states.SyncState) {
+		provider := addrs.AbsProviderConfig{
+			Module:   addrs.RootModule,
+			Provider: addrs.NewDefaultProvider("aws"),
+		}
+		vpc := addrs.RootModuleInstance.Child("vpc", addrs.NoKey)
+		s.SetResourceInstanceCurrent(
+			addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "aws_subnet", Name: "private"}.Instance(addrs.IntKey(0)).Absolute(vpc),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"id":"subnet-1"}`)},
+			provider,
+		)
+		s.SetResourceInstanceCurrent(
+			addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "aws_s3_bucket", Name: "logs"}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance),
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"id":"logs"}`)},
+			provider,
+		)
+	})
+	var buf bytes.Buffer
+	require.NoError(t, statefile.Write(statefile.New(state, "", 1), &buf, enc))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func readTaskState(t *testing.T, root, taskID string, taskConfig []byte) *states.State {
+	enc, err := TaskEncryption(taskConfig)
+	require.NoError(t, err)
+	f, err := os.Open(filepath.Join(root, TaskStatePath(taskID)))
+	require.NoError(t, err)
+	defer f.Close()
+	sf, err := statefile.Read(f, enc.State())
+	require.NoError(t, err)
+	return sf.State
+}
+
+func TestAdoptState(t *testing.T) {
+	root := t.TempDir()
+	from := filepath.Join(root, "terraform.tfstate")
+	writeSourceState(t, from, encryption.StateEncryptionDisabled())
+
+	adopted, err := AdoptState(root, AdoptOptions{Task: "net", From: from, Addresses: []string{"module.vpc"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"module.vpc.aws_subnet.private[0]"}, adopted, "only the resources in the address")
+
+	adopted, err = AdoptState(root, AdoptOptions{Task: "storage", From: from, Addresses: []string{"aws_s3_bucket.logs"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws_s3_bucket.logs"}, adopted)
+	assert.Nil(t, readTaskState(t, root, "storage", []byte(`{}`)).Module(addrs.RootModuleInstance.Child("vpc", addrs.NoKey)))
+
+	_, err = AdoptState(root, AdoptOptions{Task: "net", From: from, Addresses: []string{"module.vpc.aws_subnet.private"}})
+	assert.ErrorContains(t, err, "module.vpc.aws_subnet.private[0] is already in the state of task net")
+
+	_, err = AdoptState(root, AdoptOptions{Task: "net", From: from, Addresses: []string{"aws_instance.web"}})
+	assert.ErrorContains(t, err, "no resources of")
+	_, err = AdoptState(root, AdoptOptions{Task: "net", From: from, Addresses: []string{"not an address"}})
+	assert.ErrorContains(t, err, "invalid address")
+
+	// the source is left untouched
+	data, err := os.ReadFile(from)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "aws_subnet")
+}
+
+func TestAdoptStateEncrypted(t *testing.T) {
+	root := t.TempDir()
+	taskConfig := []byte(`{"terraform": {"encryption": ` + string(pbkdf2Encryption("correct horse battery staple")) + `}}`)
+
+	// the source is decrypted with TF_ENCRYPTION
+	sourceEnc, err := TaskEncryption([]byte(`{"terraform": {"encryption": ` + string(pbkdf2Encryption("another long passphrase")) + `}}`))
+	require.NoError(t, err)
+	from := filepath.Join(root, "terraform.tfstate")
+	writeSourceState(t, from, sourceEnc.State())
+
+	_, err = AdoptState(root, AdoptOptions{Task: "net", From: from, TaskConfig: taskConfig})
+	assert.Error(t, err, "the source can not be decrypted")
+
+	t.Setenv(encryptionConfigEnvName, string(pbkdf2Encryption("another long passphrase")))
+	sourceEnc, err = TaskEncryption([]byte(`{}`))
+	require.NoError(t, err)
+	writeSourceState(t, from, sourceEnc.State())
+	adopted, err := AdoptState(root, AdoptOptions{Task: "net", From: from})
+	require.NoError(t, err)
+	assert.Len(t, adopted, 2)
+
+	// the state of the task is written with its own encryption
+	t.Setenv(encryptionConfigEnvName, "")
+	from = filepath.Join(root, "plain.tfstate")
+	writeSourceState(t, from, encryption.StateEncryptionDisabled())
+	_, err = AdoptState(root, AdoptOptions{Task: "app", From: from, TaskConfig: taskConfig})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(root, TaskStatePath("app")))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "aws_subnet")
+	assert.NotNil(t, readTaskState(t, root, "app", taskConfig).ResourceInstance(
+		addrs.Resource{Mode: addrs.ManagedResourceMode, Type: "aws_s3_bucket", Name: "logs"}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)))
+
+	// more resources can then be adopted next to the encrypted ones
+	writeSourceState(t, filepath.Join(root, "again.tfstate"), encryption.StateEncryptionDisabled())
+	_, err = AdoptState(root, AdoptOptions{Task: "app", From: filepath.Join(root, "again.tfstate"), TaskConfig: taskConfig})
+	assert.ErrorContains(t, err, "already in the state of task app", "the encrypted state of the task is read")
+}
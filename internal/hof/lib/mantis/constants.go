@@ -25,15 +25,89 @@ const (
 	// MantisExportAs is the default alias for task outputs
 	MantisExportAs = "as"
 
+	// MantisExportPublic re-exports a var under its bare name to every flow
+	MantisExportPublic = "public"
+
 	// MantisBackendConfigPath is the default path for backend configuration
 	MantisBackendConfigPath = "mantis_state/"
 
 	// MantisStateFilePath is the default path for the state file
 	MantisStateFilePath = "mantis_state/mantis_%s.tfstate"
 
+	// MantisJournalPath is the run journal of a flow, used by --resume
+	MantisJournalPath = "mantis_state/journal_%s.json"
+
+	// MantisImageLockPath records the digests images of K8s tasks are pinned
+	// to, next to the flow
+	MantisImageLockPath = "mantis.images.lock.json"
+
 	// MantisTaskOuts is the default path for the task outputs
 	MantisTaskOuts = "out"
 
+	// MantisTaskWhen is the condition under which a task runs
+	MantisTaskWhen = "when"
+
+	// MantisTaskForEach is the list or struct a task is repeated over
+	MantisTaskForEach = "for_each"
+
+	// MantisTaskEach holds the key and value of a for_each instance
+	MantisTaskEach = "each"
+
+	// MantisTaskMoved names the task IDs a task's state was moved from
+	MantisTaskMoved = "moved"
+
+	// MantisTaskWait makes a K8s task wait for its object to be ready
+	// before filling out, for up to 5 minutes unless the task has a timeout
+	MantisTaskWait = "wait"
+
+	// MantisTaskRollback makes a K8s task restore its object when it does
+	// not become ready, it implies wait. The outcome is out.rollback.
+	MantisTaskRollback = "rollback_on_failure"
+
+	// MantisTaskCluster selects the cluster a K8s task deploys to
+	MantisTaskCluster = "cluster"
+
+	// MantisTaskFieldManager is the server-side apply field manager of a K8s task
+	MantisTaskFieldManager = "field_manager"
+
+	// MantisTaskForceConflicts makes a K8s task take over fields owned by other managers
+	MantisTaskForceConflicts = "force_conflicts"
+
+	// MantisTaskIgnoreFields are paths of a K8s manifest neither applied nor checked for drift
+	MantisTaskIgnoreFields = "ignore_fields"
+
+	// MantisTaskPinImages makes a K8s task replace image tags by their digest.
+	// The pinned images are out.images.
+	MantisTaskPinImages = "pin_images"
+
+	// MantisJobTTL is the ttl in seconds of the Job of a K8sJob task once finished
+	MantisJobTTL = "ttl_seconds"
+
+	// MantisJobLogValues are regexps extracting values from the logs of a K8sJob task
+	MantisJobLogValues = "log_values"
+
+	// MantisTaskTF is the task type of tofu tasks
+	MantisTaskTF = "mantis.core.TF"
+
+	// MantisUnknownVarPrefix names the variables which pass values only
+	// known after apply into the plan of a tofu task
+	MantisUnknownVarPrefix = "mantis_unknown_"
+
+	// MantisTaskK8s is the task type of Kubernetes tasks
+	MantisTaskK8s = "mantis.core.K8s"
+
+	// MantisTaskK8sJob is the task type of Kubernetes Jobs run to completion
+	MantisTaskK8sJob = "mantis.core.K8sJob"
+
+	// MantisTaskHelm is the task type of Helm releases
+	MantisTaskHelm = "mantis.core.Helm"
+
+	// MantisTaskK8sSecret is the task type of Secrets decrypted from encrypted files
+	MantisTaskK8sSecret = "mantis.core.K8sSecret"
+
+	// MantisSecretSource is the encrypted file the values of a K8sSecret task come from
+	MantisSecretSource = "source"
+
 	// MantisJsonConfig is the in-memory json file used to push config to OpenTF engine
 	MantisJsonConfig = "mantis.json"
 )
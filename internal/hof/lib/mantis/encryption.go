@@ -0,0 +1,93 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/opentofu/opentofu/internal/configs"
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/encryption/config"
+)
+
+// encryptionConfigEnvName configures the encryption of every task,
+// merged with the encryption block of the task as tofu does
+const encryptionConfigEnvName = "TF_ENCRYPTION"
+
+// TaskEncryption returns the encryption of a TF task, given the JSON of its
+// config, the way tofu builds it for the task: from the encryption block of
+// the config, merged with TF_ENCRYPTION. It is disabled when neither sets one.
+func TaskEncryption(taskConfig []byte) (encryption.Encryption, error) {
+	module, diags := configs.NewParser(nil).LoadConfigFromStr(&configs.MantisConfig{
+		Identifier: "encryption",
+		Content:    taskConfig,
+		Format:     "json",
+	}, ".")
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid task config: %v", diags.Error())
+	}
+
+	return envEncryption(module.Encryption)
+}
+
+// envEncryption returns the encryption configured by cfg merged with
+// TF_ENCRYPTION, as tofu builds it for a root module. cfg may be nil.
+func envEncryption(cfg *config.EncryptionConfig) (encryption.Encryption, error) {
+	if env := os.Getenv(encryptionConfigEnvName); env != "" {
+		envCfg, diags := config.LoadConfigFromString(encryptionConfigEnvName, env)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid %s: %v", encryptionConfigEnvName, diags.Error())
+		}
+		cfg = cfg.Merge(envCfg)
+	}
+
+	enc, diags := encryption.New(encryption.DefaultRegistry, cfg)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("invalid encryption config: %v", diags.Error())
+	}
+	return enc, nil
+}
+
+// SealTaskData encrypts data with the state encryption of a TF task, for
+// UnsealTaskData. It returns nil when the task does not encrypt its state.
+func SealTaskData(taskConfig []byte, data []byte) ([]byte, error) {
+	enc, err := TaskEncryption(taskConfig)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := enc.State().EncryptState(data)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := encryption.IsEncryptionPayload(sealed); !ok {
+		return nil, nil
+	}
+	return sealed, nil
+}
+
+// UnsealTaskData decrypts the data written by SealTaskData
+func UnsealTaskData(taskConfig []byte, sealed []byte) ([]byte, error) {
+	enc, err := TaskEncryption(taskConfig)
+	if err != nil {
+		return nil, err
+	}
+	data, err := enc.State().DecryptState(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := encryption.IsEncryptionPayload(data); ok {
+		return nil, fmt.Errorf("the task does not encrypt its state, which the data was sealed with")
+	}
+	return data, nil
+}
@@ -0,0 +1,52 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealTaskData(t *testing.T) {
+	config := []byte(`{"terraform": {"encryption": ` + string(pbkdf2Encryption("correct horse battery staple")) + `}}`)
+	sealed, err := SealTaskData(config, []byte(`{"password":"hunter2"}`))
+	require.NoError(t, err)
+	require.NotEmpty(t, sealed)
+	assert.NotContains(t, string(sealed), "hunter2")
+
+	data, err := UnsealTaskData(config, sealed)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"hunter2"}`, string(data))
+
+	_, err = UnsealTaskData([]byte(`{"terraform": {"encryption": `+string(pbkdf2Encryption("another long passphrase"))+`}}`), sealed)
+	assert.Error(t, err, "a different key")
+	_, err = UnsealTaskData([]byte(`{}`), sealed)
+	assert.ErrorContains(t, err, "does not encrypt its state")
+
+	// a task which does not encrypt its state seals nothing
+	sealed, err = SealTaskData([]byte(`{}`), []byte(`{"password":"hunter2"}`))
+	require.NoError(t, err)
+	assert.Nil(t, sealed)
+}
+
+func TestTaskEncryptionEnv(t *testing.T) {
+	t.Setenv(encryptionConfigEnvName, string(pbkdf2Encryption("correct horse battery staple")))
+	sealed, err := SealTaskData([]byte(`{}`), []byte(`{"password":"hunter2"}`))
+	require.NoError(t, err)
+	assert.NotEmpty(t, sealed, "TF_ENCRYPTION applies to every task")
+
+	_, err = TaskEncryption([]byte(`{"resource": 1}`))
+	assert.ErrorContains(t, err, "invalid task config")
+}
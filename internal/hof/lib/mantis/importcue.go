@@ -0,0 +1,224 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+	cuejson "cuelang.org/go/encoding/json"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// GeneratedResources converts the HCL written by -generate-config-out into
+// the resource section of a task's config: type -> name -> body. Attributes
+// left null are dropped, nested blocks become an object, or a list of objects
+// when repeated, as in the JSON syntax of tofu configurations.
+func GeneratedResources(src []byte, filename string) (map[string]any, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	resources := map[string]any{}
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 {
+			return nil, fmt.Errorf("%s: unexpected %s block in generated configuration", block.DefRange(), block.Type)
+		}
+		body, err := generatedBody(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		typ, name := block.Labels[0], block.Labels[1]
+		if resources[typ] == nil {
+			resources[typ] = map[string]any{}
+		}
+		resources[typ].(map[string]any)[name] = body
+	}
+	return resources, nil
+}
+
+// generated configuration only holds literals, and jsonencode for strings
+// which contain JSON
+var generatedEvalContext = &hcl.EvalContext{
+	Functions: map[string]function.Function{
+		"jsonencode": stdlib.JSONEncodeFunc,
+	},
+}
+
+func generatedBody(body *hclsyntax.Body) (map[string]any, error) {
+	out := map[string]any{}
+	for name, attr := range body.Attributes {
+		val, diags := attr.Expr.Value(generatedEvalContext)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		if val.IsNull() {
+			continue
+		}
+		goVal, err := ctyToAny(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", attr.SrcRange, err)
+		}
+		out[name] = goVal
+	}
+
+	nested := map[string][]any{}
+	for _, block := range body.Blocks {
+		b, err := generatedBody(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		nested[block.Type] = append(nested[block.Type], b)
+	}
+	for typ, blocks := range nested {
+		if len(blocks) == 1 {
+			out[typ] = blocks[0]
+		} else {
+			out[typ] = blocks
+		}
+	}
+	return out, nil
+}
+
+func ctyToAny(val cty.Value) (any, error) {
+	data, err := ctyjson.SimpleJSONValue{Value: val}.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ImportedCUE returns CUE declaring resources under the config of the task at
+// path, so that it unifies with the task when placed in its package. With pkg
+// set, the result is a complete file, otherwise declarations which can be
+// appended to one.
+func ImportedCUE(pkg string, path cue.Path, resources map[string]any, comment string) ([]byte, error) {
+	expr, err := jsonExpr(map[string]any{"resource": resources})
+	if err != nil {
+		return nil, err
+	}
+
+	sels := append(path.Selectors(), cue.Str("config"))
+	for i := len(sels) - 1; i >= 0; i-- {
+		if sels[i].LabelType() != cue.StringLabel {
+			return nil, fmt.Errorf("task path %s has a non regular label %s", path, sels[i])
+		}
+		expr = ast.NewStruct(&ast.Field{Label: label(sels[i].Unquoted()), Value: expr})
+	}
+
+	decls := expr.(*ast.StructLit).Elts
+	if comment != "" {
+		ast.AddComment(decls[0], &ast.CommentGroup{
+			Doc:  true,
+			List: []*ast.Comment{{Text: "// " + comment}},
+		})
+	}
+	if pkg != "" {
+		decls = append([]ast.Decl{&ast.Package{Name: ast.NewIdent(pkg)}}, decls...)
+	}
+	return format.Node(&ast.File{Decls: decls}, format.Simplify())
+}
+
+func label(name string) ast.Label {
+	if ast.IsValidIdent(name) {
+		return ast.NewIdent(name)
+	}
+	return ast.NewString(name)
+}
+
+// ImportedK8sCUE returns a CUE file of package pkg declaring a definition
+// per Kubernetes object, #<Kind>_<name>, and a flow holding a K8s task per
+// object whose config is its definition. Tasks are named <kind>_<name> and
+// deploy to cluster when set.
+func ImportedK8sCUE(pkg, flow string, objects []map[string]any, cluster map[string]any, comment string) ([]byte, error) {
+	var defs []ast.Decl
+	tasks := []ast.Decl{&ast.Attribute{Text: fmt.Sprintf("@flow(%s)", flow)}}
+	used := map[string]bool{}
+	for _, obj := range objects {
+		kind, _ := obj["kind"].(string)
+		metadata, _ := obj["metadata"].(map[string]any)
+		name, _ := metadata["name"].(string)
+		suffix := identifier(name)
+		for i := 2; used[strings.ToLower(kind)+"_"+suffix]; i++ {
+			suffix = fmt.Sprintf("%s_%d", identifier(name), i)
+		}
+		id, def := strings.ToLower(kind)+"_"+suffix, "#"+kind+"_"+suffix
+		used[id] = true
+
+		expr, err := jsonExpr(obj)
+		if err != nil {
+			return nil, err
+		}
+		defs = append(defs, &ast.Field{Label: ast.NewIdent(def), Value: expr})
+
+		task := []ast.Decl{&ast.Attribute{Text: fmt.Sprintf("@task(%s)", MantisTaskK8s)}}
+		if len(cluster) > 0 {
+			expr, err := jsonExpr(cluster)
+			if err != nil {
+				return nil, err
+			}
+			task = append(task, &ast.Field{Label: ast.NewIdent(MantisTaskCluster), Value: expr})
+		}
+		task = append(task, &ast.Field{Label: ast.NewIdent("config"), Value: ast.NewIdent(def)})
+		tasks = append(tasks, &ast.Field{Label: ast.NewIdent(id), Value: &ast.StructLit{Elts: task}})
+	}
+
+	decls := append(defs, &ast.Field{Label: label(flow), Value: &ast.StructLit{Elts: tasks}})
+	if comment != "" {
+		ast.AddComment(decls[0], &ast.CommentGroup{
+			Doc:  true,
+			List: []*ast.Comment{{Text: "// " + comment}},
+		})
+	}
+	decls = append([]ast.Decl{&ast.Package{Name: ast.NewIdent(pkg)}}, decls...)
+	return format.Node(&ast.File{Decls: decls}, format.Simplify())
+}
+
+// jsonExpr returns v as a CUE expression, through indented JSON to keep one
+// field per line once formatted
+func jsonExpr(v any) (ast.Expr, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return cuejson.Extract("generated.json", data)
+}
+
+// identifier replaces the characters of name not allowed in a CUE
+// identifier with underscores
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
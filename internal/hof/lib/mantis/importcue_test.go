@@ -0,0 +1,113 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const generatedHCL = `
+# __generated__ by OpenTofu
+resource "aws_s3_bucket" "logs" {
+  bucket        = "acme-logs"
+  force_destroy = false
+  tags = {
+    team = "infra"
+  }
+  object_lock_enabled = null
+  policy = jsonencode({
+    Version = "2012-10-17"
+  })
+  versioning {
+    enabled = true
+  }
+  lifecycle_rule {
+    id      = "a"
+    enabled = true
+  }
+  lifecycle_rule {
+    id   = "b"
+    days = 30
+  }
+}
+
+resource "aws_s3_bucket" "assets" {
+  bucket = "acme-assets"
+}
+`
+
+func TestGeneratedResources(t *testing.T) {
+	resources, err := GeneratedResources([]byte(generatedHCL), "generated.tf")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"aws_s3_bucket": map[string]any{
+			"logs": map[string]any{
+				"bucket":        "acme-logs",
+				"force_destroy": false,
+				"tags":          map[string]any{"team": "infra"},
+				"policy":        `{"Version":"2012-10-17"}`,
+				"versioning":    map[string]any{"enabled": true},
+				"lifecycle_rule": []any{
+					map[string]any{"id": "a", "enabled": true},
+					map[string]any{"id": "b", "days": json.Number("30")},
+				},
+			},
+			"assets": map[string]any{"bucket": "acme-assets"},
+		},
+	}, resources)
+}
+
+func TestGeneratedResourcesErrors(t *testing.T) {
+	_, err := GeneratedResources([]byte(`data "aws_s3_bucket" "logs" {}`), "generated.tf")
+	assert.ErrorContains(t, err, "unexpected data block")
+
+	_, err = GeneratedResources([]byte(`resource "aws_s3_bucket" "logs" {`), "generated.tf")
+	assert.Error(t, err)
+}
+
+func TestImportedCUE(t *testing.T) {
+	resources, err := GeneratedResources([]byte(generatedHCL), "generated.tf")
+	require.NoError(t, err)
+
+	path := cue.ParsePath(`infra."s3-buckets"`)
+	src, err := ImportedCUE("flows", path, resources, "imported by mantis")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(src), "package flows\n"), string(src))
+	assert.Contains(t, string(src), "// imported by mantis")
+
+	val := cuecontext.New().CompileBytes(src)
+	require.NoError(t, val.Err())
+	bucket, err := val.LookupPath(cue.ParsePath(`infra."s3-buckets".config.resource.aws_s3_bucket.logs.bucket`)).String()
+	require.NoError(t, err)
+	assert.Equal(t, "acme-logs", bucket)
+	days, err := val.LookupPath(cue.ParsePath(`infra."s3-buckets".config.resource.aws_s3_bucket.logs.lifecycle_rule[1].days`)).Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), days)
+
+	// without a package, the declarations are appended to an existing file
+	src, err = ImportedCUE("", path, resources, "")
+	require.NoError(t, err)
+	assert.NotContains(t, string(src), "package")
+	require.NoError(t, cuecontext.New().CompileBytes(src).Err())
+
+	_, err = ImportedCUE("", cue.MakePath(cue.Str("infra"), cue.Index(0)), resources, "")
+	assert.ErrorContains(t, err, "non regular label")
+}
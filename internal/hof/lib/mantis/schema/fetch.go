@@ -0,0 +1,98 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// kubernetesSpecURL lists the OpenAPI v3 documents of a Kubernetes release
+const kubernetesSpecURL = "https://api.github.com/repos/kubernetes/kubernetes/contents/api/openapi-spec/v3?ref=release-%s"
+
+// FetchKubernetes downloads the OpenAPI v3 documents published with
+// the given Kubernetes minor version, e.g. 1.30
+func FetchKubernetes(version string) (*OpenAPI, error) {
+	var listing []struct {
+		Name        string `json:"name"`
+		DownloadURL string `json:"download_url"`
+	}
+	data, err := httpGet(fmt.Sprintf(kubernetesSpecURL, strings.TrimPrefix(version, "v")))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, fmt.Errorf("unexpected listing of the Kubernetes OpenAPI spec: %w", err)
+	}
+
+	o := &OpenAPI{}
+	for _, entry := range listing {
+		if !strings.HasSuffix(entry.Name, ".json") {
+			continue
+		}
+		data, err := httpGet(entry.DownloadURL)
+		if err != nil {
+			return nil, err
+		}
+		if err := o.AddDocument(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+	}
+	if len(o.Schemas) == 0 {
+		return nil, fmt.Errorf("no OpenAPI documents found for Kubernetes %s", version)
+	}
+	return o, nil
+}
+
+// LoadOpenAPI reads an OpenAPI v3 document, or every *.json document of a
+// directory, e.g. as saved from `kubectl get --raw /openapi/v3/apis/apps/v1`
+func LoadOpenAPI(path string) (*OpenAPI, error) {
+	files := []string{path}
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	o := &OpenAPI{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := o.AddDocument(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return o, nil
+}
+
+func httpGet(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,69 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+// Package schema generates CUE definitions from provider and
+// Kubernetes schemas, so task configs can be validated offline.
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+)
+
+// exprDef matches a tofu template, such as "${var.x}" or a value which
+// is only known after apply, and is accepted wherever a non-string value is.
+const exprDef = `#expr: =~"^\\$\\{.*\\}$"`
+
+// file accumulates the source of a generated CUE file.
+type file struct {
+	strings.Builder
+}
+
+func (f *file) printf(format string, args ...any) {
+	fmt.Fprintf(f, format, args...)
+}
+
+// comment writes text as a // comment, one per line
+func (f *file) comment(text string) {
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		f.printf("// %s\n", strings.TrimRight(line, " \t\r"))
+	}
+}
+
+// format returns the gofmt-like formatted source
+func (f *file) format() ([]byte, error) {
+	out, err := format.Source([]byte(f.String()))
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid CUE: %w", err)
+	}
+	return out, nil
+}
+
+// label quotes names which are not valid CUE identifiers
+func label(name string) string {
+	if ast.IsValidIdent(name) && !strings.HasPrefix(name, "#") && !strings.HasPrefix(name, "_") {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// field returns the label with its required (!) or optional (?) marker
+func field(name string, required bool) string {
+	if required {
+		return label(name) + "!"
+	}
+	return label(name) + "?"
+}
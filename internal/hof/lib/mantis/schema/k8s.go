@@ -0,0 +1,404 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/yagu"
+)
+
+// JSONSchema is the subset of an OpenAPI v3 schema object, and the
+// Kubernetes extensions to it, which is needed to generate CUE.
+type JSONSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties,omitempty"`
+	AllOf                []*JSONSchema          `json:"allOf,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Nullable             bool                   `json:"nullable,omitempty"`
+
+	IntOrString           bool  `json:"x-kubernetes-int-or-string,omitempty"`
+	PreserveUnknownFields bool  `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	EmbeddedResource      bool  `json:"x-kubernetes-embedded-resource,omitempty"`
+	GroupVersionKinds     []GVK `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+// GVK identifies a Kubernetes kind
+type GVK struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// APIVersion returns the apiVersion of manifests of this kind
+func (g GVK) APIVersion() string {
+	if g.Group == "" {
+		return g.Version
+	}
+	return g.Group + "/" + g.Version
+}
+
+// additional returns the schema of additionalProperties, and whether
+// additional properties are allowed at all
+func (s *JSONSchema) additional() (*JSONSchema, bool) {
+	if len(s.AdditionalProperties) == 0 {
+		return nil, false
+	}
+	var allowed bool
+	if err := json.Unmarshal(s.AdditionalProperties, &allowed); err == nil {
+		return nil, allowed
+	}
+	var sub JSONSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &sub); err != nil {
+		return nil, true
+	}
+	return &sub, true
+}
+
+// OpenAPI holds the named schemas of one or more merged OpenAPI v3 documents
+type OpenAPI struct {
+	Schemas map[string]*JSONSchema
+}
+
+// AddDocument merges the components.schemas of an OpenAPI v3 document.
+// Kubernetes publishes one document per group version, which repeat the
+// shared definitions such as ObjectMeta.
+func (o *OpenAPI) AddDocument(data []byte) error {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*JSONSchema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if len(doc.Components.Schemas) == 0 {
+		return errors.New("OpenAPI document has no components.schemas")
+	}
+	if o.Schemas == nil {
+		o.Schemas = make(map[string]*JSONSchema)
+	}
+	for name, s := range doc.Components.Schemas {
+		o.Schemas[name] = s
+	}
+	return nil
+}
+
+// Kubernetes renders the merged Kubernetes OpenAPI schemas as CUE. Every
+// schema becomes a closed definition, and kinds are indexed by
+//
+//	#gvk: [apiVersion]: [kind]: #Definition
+//
+// which is what mantis validate uses to check K8s task configs.
+func Kubernetes(version string, o *OpenAPI) ([]byte, error) {
+	f := &file{}
+	f.printf("// Code generated by mantis schema import k8s. DO NOT EDIT.\n")
+	f.printf("// kubernetes: %s\n\n", version)
+	f.printf("package k8s\n\n")
+
+	gvks := map[GVK]string{}
+	for _, name := range yagu.SortedKeys(o.Schemas) {
+		s := o.Schemas[name]
+		for _, g := range s.GroupVersionKinds {
+			gvks[g] = defName(name)
+		}
+		if s.Description != "" {
+			f.comment(s.Description)
+		}
+		f.printf("%s: ", defName(name))
+		if strings.HasSuffix(name, ".resource.Quantity") {
+			// published as a string, but manifests commonly use numbers
+			f.printf("string | number\n\n")
+			continue
+		}
+		f.schema(s, kindOf(s))
+		f.printf("\n\n")
+	}
+	f.index(gvks)
+
+	return f.format()
+}
+
+// CRD is the part of a CustomResourceDefinition describing its schemas
+type CRD struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name   string         `json:"name"`
+			Schema *CRDValidation `json:"schema"`
+		} `json:"versions"`
+
+		// apiextensions.k8s.io/v1beta1 shares one schema between versions
+		Version    string         `json:"version"`
+		Validation *CRDValidation `json:"validation"`
+	} `json:"spec"`
+}
+
+// CRDValidation holds the schema of a CRD version
+type CRDValidation struct {
+	OpenAPIV3Schema *JSONSchema `json:"openAPIV3Schema"`
+}
+
+// schemas returns the schema of each served version of the CRD
+func (crd *CRD) schemas() map[string]*JSONSchema {
+	out := map[string]*JSONSchema{}
+	shared := crd.Spec.Validation
+	if len(crd.Spec.Versions) == 0 && crd.Spec.Version != "" && shared != nil {
+		out[crd.Spec.Version] = shared.OpenAPIV3Schema
+	}
+	for _, v := range crd.Spec.Versions {
+		switch {
+		case v.Schema != nil && v.Schema.OpenAPIV3Schema != nil:
+			out[v.Name] = v.Schema.OpenAPIV3Schema
+		case shared != nil && shared.OpenAPIV3Schema != nil:
+			out[v.Name] = shared.OpenAPIV3Schema
+		}
+	}
+	return out
+}
+
+// ParseCRDs reads the CustomResourceDefinitions of a multi-document YAML
+// or JSON file. Documents of other kinds are skipped.
+func ParseCRDs(data []byte) ([]*CRD, error) {
+	var crds []*CRD
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc map[string]any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode document: %w", err)
+		}
+		if doc == nil || doc["kind"] != "CustomResourceDefinition" {
+			continue
+		}
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		var crd CRD
+		if err := json.Unmarshal(raw, &crd); err != nil {
+			return nil, fmt.Errorf("invalid CustomResourceDefinition: %w", err)
+		}
+		crds = append(crds, &crd)
+	}
+	if len(crds) == 0 {
+		return nil, errors.New("no CustomResourceDefinition found")
+	}
+	return crds, nil
+}
+
+// CRDs renders the versions of the given CRDs as CUE, indexed by #gvk
+// like the Kubernetes schemas.
+func CRDs(crds []*CRD) ([]byte, error) {
+	f := &file{}
+	f.printf("// Code generated by mantis schema import crd. DO NOT EDIT.\n\n")
+	f.printf("package crd\n\n")
+
+	gvks := map[GVK]string{}
+	for _, crd := range crds {
+		schemas := crd.schemas()
+		for _, version := range yagu.SortedKeys(schemas) {
+			s := schemas[version]
+			if s == nil {
+				continue
+			}
+			g := GVK{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.Kind}
+			name := defName(crd.Spec.Group + "." + version + "." + g.Kind)
+			gvks[g] = name
+
+			f.printf("// %s\n", crd.Metadata.Name)
+			f.printf("%s: ", name)
+			f.schema(s, &g)
+			f.printf("\n\n")
+		}
+	}
+	if len(gvks) == 0 {
+		return nil, errors.New("no CRD version has an openAPIV3Schema")
+	}
+	f.index(gvks)
+
+	return f.format()
+}
+
+func (f *file) index(gvks map[GVK]string) {
+	byVersion := map[string]map[string]string{}
+	for g, name := range gvks {
+		kinds, ok := byVersion[g.APIVersion()]
+		if !ok {
+			kinds = map[string]string{}
+			byVersion[g.APIVersion()] = kinds
+		}
+		kinds[g.Kind] = name
+	}
+
+	f.printf("#gvk: {\n")
+	for _, av := range yagu.SortedKeys(byVersion) {
+		f.printf("%s: {\n", label(av))
+		for _, kind := range yagu.SortedKeys(byVersion[av]) {
+			f.printf("%s: %s\n", label(kind), byVersion[av][kind])
+		}
+		f.printf("}\n")
+	}
+	f.printf("}\n")
+}
+
+// kindOf returns the only kind a schema describes, if any
+func kindOf(s *JSONSchema) *GVK {
+	if len(s.GroupVersionKinds) == 1 {
+		return &s.GroupVersionKinds[0]
+	}
+	return nil
+}
+
+var nonIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// defName returns the CUE definition for a schema name,
+// e.g. #io_k8s_api_apps_v1_Deployment
+func defName(name string) string {
+	return "#" + nonIdent.ReplaceAllString(name, "_")
+}
+
+// schema writes the CUE expression for s. When kind is set, apiVersion
+// and kind are pinned to it.
+func (f *file) schema(s *JSONSchema, kind *GVK) {
+	switch {
+	case s.Ref != "":
+		f.printf("%s", defName(s.Ref[strings.LastIndex(s.Ref, "/")+1:]))
+	case len(s.AllOf) == 1:
+		f.schema(s.AllOf[0], kind)
+	case s.IntOrString || s.Format == "int-or-string":
+		f.printf("int | string")
+	case s.Format == "quantity":
+		f.printf("string | number")
+	case len(s.Enum) > 0:
+		vals := make([]string, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			b, _ := json.Marshal(e)
+			vals = append(vals, string(b))
+		}
+		sort.Strings(vals)
+		f.printf("%s", strings.Join(vals, " | "))
+	case s.Type == "string":
+		f.printf("string")
+	case s.Type == "integer":
+		f.printf("int")
+	case s.Type == "number":
+		f.printf("number")
+	case s.Type == "boolean":
+		f.printf("bool")
+	case s.Type == "array":
+		f.printf("[...")
+		if s.Items != nil {
+			f.schema(s.Items, nil)
+		} else {
+			f.printf("_")
+		}
+		f.printf("]")
+	case len(s.Properties) > 0 || kind != nil || s.EmbeddedResource:
+		f.object(s, kind)
+	default:
+		if sub, ok := s.additional(); ok && sub != nil {
+			f.printf("{[string]: ")
+			f.schema(sub, nil)
+			f.printf("}")
+		} else if s.Type == "object" && !s.PreserveUnknownFields {
+			f.printf("{...}")
+		} else {
+			f.printf("_")
+		}
+	}
+
+	if s.Nullable {
+		f.printf(" | null")
+	}
+}
+
+func (f *file) object(s *JSONSchema, kind *GVK) {
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+
+	f.printf("{\n")
+	for _, name := range yagu.SortedKeys(s.Properties) {
+		p := s.Properties[name]
+		if p.Description != "" {
+			f.comment(p.Description)
+		}
+		f.printf("%s: ", field(name, required[name]))
+		switch {
+		case kind != nil && name == "apiVersion":
+			f.printf("%q", kind.APIVersion())
+		case kind != nil && name == "kind":
+			f.printf("%q", kind.Kind)
+		default:
+			f.schema(p, nil)
+		}
+		f.printf("\n")
+	}
+	if s.EmbeddedResource || kind != nil {
+		if _, ok := s.Properties["apiVersion"]; !ok {
+			if kind != nil {
+				f.printf("apiVersion?: %q\n", kind.APIVersion())
+			} else {
+				f.printf("apiVersion?: string\n")
+			}
+		}
+		if _, ok := s.Properties["kind"]; !ok {
+			if kind != nil {
+				f.printf("kind?: %q\n", kind.Kind)
+			} else {
+				f.printf("kind?: string\n")
+			}
+		}
+		if _, ok := s.Properties["metadata"]; !ok {
+			f.printf("metadata?: {...}\n")
+		}
+	}
+	if sub, ok := s.additional(); ok {
+		f.printf("[string]: ")
+		if sub != nil {
+			f.schema(sub, nil)
+		} else {
+			f.printf("_")
+		}
+		f.printf("\n")
+	} else if s.PreserveUnknownFields {
+		f.printf("...\n")
+	}
+	f.printf("}")
+}
@@ -0,0 +1,99 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// genFixtures generates the schemas of the testdata fixtures into dir
+func genFixtures(t *testing.T, dir string) {
+	o, err := LoadOpenAPI("testdata/openapi.json")
+	require.NoError(t, err)
+	k8s, err := Kubernetes("test", o)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile("testdata/crd.yaml")
+	require.NoError(t, err)
+	crds, err := ParseCRDs(data)
+	require.NoError(t, err)
+	require.Len(t, crds, 1, "documents of other kinds are skipped")
+	crd, err := CRDs(crds)
+	require.NoError(t, err)
+
+	for path, out := range map[string][]byte{
+		"k8s.io/api/schema.cue": k8s,
+		"k8s.io/crd/crd.cue":    crd,
+	} {
+		target := filepath.Join(dir, GenDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(target), 0755))
+		require.NoError(t, os.WriteFile(target, out, 0644))
+	}
+}
+
+func TestKinds(t *testing.T) {
+	dir := t.TempDir()
+	genFixtures(t, dir)
+
+	ctx := cuecontext.New()
+	kinds, err := LoadKinds(ctx, dir)
+	require.NoError(t, err)
+	require.False(t, kinds.Empty())
+
+	_, ok := kinds.Lookup("v1", "ConfigMap")
+	assert.True(t, ok)
+	_, ok = kinds.Lookup("example.com/v1", "Widget")
+	assert.True(t, ok)
+	_, ok = kinds.Lookup("v1", "Widget")
+	assert.False(t, ok)
+
+	cases := []struct {
+		name, manifest string
+		ok             bool
+	}{
+		{"configmap", `{apiVersion: "v1", kind: "ConfigMap", metadata: {name: "a", labels: app: "x"}, data: k: "v", immutable: null}`, true},
+		{"configmap data type", `{apiVersion: "v1", kind: "ConfigMap", data: k: 1}`, false},
+		{"configmap unknown field", `{apiVersion: "v1", kind: "ConfigMap", datas: k: "v"}`, false},
+		{"service", `{apiVersion: "v1", kind: "Service", spec: ports: [{port: 80, targetPort: "http", protocol: "TCP"}]}`, true},
+		{"service int target port", `{apiVersion: "v1", kind: "Service", spec: ports: [{port: 80, targetPort: 8080}]}`, true},
+		{"service missing port", `{apiVersion: "v1", kind: "Service", spec: ports: [{targetPort: 8080}]}`, false},
+		{"service bad enum", `{apiVersion: "v1", kind: "Service", spec: ports: [{port: 80, protocol: "HTTP"}]}`, false},
+		{"widget", `{apiVersion: "example.com/v1", kind: "Widget", metadata: name: "w", spec: {size: 3, colour: "red", extra: any: "thing"}}`, true},
+		{"widget missing size", `{apiVersion: "example.com/v1", kind: "Widget", spec: colour: "red"}`, false},
+		{"widget preserved", `{apiVersion: "example.com/v1beta1", kind: "Widget", spec: anything: 1}`, true},
+		{"unknown kind", `{apiVersion: "v1", kind: "Gadget"}`, false},
+		{"no kind", `{apiVersion: "v1"}`, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := kinds.Check(ctx.CompileString(c.manifest))
+			if c.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadKindsWithoutSchemas(t *testing.T) {
+	kinds, err := LoadKinds(cuecontext.New(), t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, kinds.Empty())
+}
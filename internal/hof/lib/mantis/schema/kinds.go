@@ -0,0 +1,95 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"cuelang.org/go/cue"
+)
+
+// GenDir is where generated schemas are written, relative to the module
+// root, so they can be imported like any other CUE package.
+const GenDir = "cue.mod/gen"
+
+// Kinds looks up the generated definitions of Kubernetes kinds and CRDs
+type Kinds struct {
+	indexes []cue.Value
+}
+
+// LoadKinds compiles every generated schema below dir/cue.mod/gen which
+// carries a #gvk index. No schemas is not an error; Check then has nothing
+// to check against.
+func LoadKinds(ctx *cue.Context, dir string) (*Kinds, error) {
+	k := &Kinds{}
+	root := filepath.Join(dir, GenDir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return k, nil
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".cue" {
+			return err
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		v := ctx.CompileBytes(src, cue.Filename(path))
+		if v.Err() != nil {
+			return fmt.Errorf("invalid generated schema %s: %w", path, v.Err())
+		}
+		if idx := v.LookupPath(cue.MakePath(cue.Def("gvk"))); idx.Exists() {
+			k.indexes = append(k.indexes, idx)
+		}
+		return nil
+	})
+	return k, err
+}
+
+// Empty reports whether no Kubernetes schemas have been imported
+func (k *Kinds) Empty() bool {
+	return len(k.indexes) == 0
+}
+
+// Lookup returns the definition of the given kind
+func (k *Kinds) Lookup(apiVersion, kind string) (cue.Value, bool) {
+	for _, idx := range k.indexes {
+		def := idx.LookupPath(cue.MakePath(cue.Str(apiVersion), cue.Str(kind)))
+		if def.Exists() {
+			return def, true
+		}
+	}
+	return cue.Value{}, false
+}
+
+// Check validates a manifest against the definition of its kind
+func (k *Kinds) Check(manifest cue.Value) error {
+	apiVersion, err := manifest.LookupPath(cue.ParsePath("apiVersion")).String()
+	if err != nil {
+		return fmt.Errorf("manifest has no apiVersion: %w", err)
+	}
+	kind, err := manifest.LookupPath(cue.ParsePath("kind")).String()
+	if err != nil {
+		return fmt.Errorf("manifest has no kind: %w", err)
+	}
+
+	def, ok := k.Lookup(apiVersion, kind)
+	if !ok {
+		return fmt.Errorf("no schema for %s %s; check the apiVersion and kind, or import the CRD with mantis schema import crd", apiVersion, kind)
+	}
+	return def.Unify(manifest).Validate(cue.Concrete(true))
+}
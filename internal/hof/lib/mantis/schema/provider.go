@@ -0,0 +1,261 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/opentofu/opentofu/internal/command/jsonprovider"
+	"github.com/opentofu/opentofu/internal/hof/lib/yagu"
+)
+
+// ProviderInfo identifies the provider a schema was generated from.
+type ProviderInfo struct {
+	// Source is the fully qualified address, e.g. registry.opentofu.org/hashicorp/aws
+	Source  string
+	Type    string
+	Version string
+}
+
+// Provider renders the schema of a provider as a CUE file with a closed
+// definition per resource and data source type:
+//
+//	#resource: aws_instance?: [string]: #aws_instance
+//	#data:     aws_ami?:      [string]: #data_aws_ami
+//
+// Required attributes are marked with "!", attributes which are only
+// computed by the provider may not be set.
+func Provider(info ProviderInfo, p *jsonprovider.Provider) ([]byte, error) {
+	f := &file{}
+	f.printf("// Code generated by mantis schema import provider. DO NOT EDIT.\n")
+	f.printf("// source: %s\n// version: %s\n\n", info.Source, info.Version)
+	f.printf("package %s\n\n", packageName(info.Type))
+	f.printf("%s\n\n", exprDef)
+
+	f.printf("#meta: {\n")
+	f.printf("count?: int | #expr\n")
+	f.printf("for_each?: _\n")
+	f.printf("depends_on?: [...string]\n")
+	f.printf("provider?: string\n")
+	f.printf("lifecycle?: {...}\n")
+	f.printf("}\n\n")
+
+	f.printf("#resource: {\n")
+	for _, name := range yagu.SortedKeys(p.ResourceSchemas) {
+		f.printf("%s: [string]: #%s\n", field(name, false), name)
+	}
+	f.printf("}\n\n")
+
+	f.printf("#data: {\n")
+	for _, name := range yagu.SortedKeys(p.DataSourceSchemas) {
+		f.printf("%s: [string]: #data_%s\n", field(name, false), name)
+	}
+	f.printf("}\n\n")
+
+	for _, name := range yagu.SortedKeys(p.ResourceSchemas) {
+		s := p.ResourceSchemas[name]
+		if err := f.definition(name, s, true); err != nil {
+			return nil, fmt.Errorf("resource %s: %w", name, err)
+		}
+	}
+	for _, name := range yagu.SortedKeys(p.DataSourceSchemas) {
+		s := p.DataSourceSchemas[name]
+		if err := f.definition("data_"+name, s, false); err != nil {
+			return nil, fmt.Errorf("data source %s: %w", name, err)
+		}
+	}
+
+	return f.format()
+}
+
+// packageName derives a CUE package name from a provider type
+func packageName(typ string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, typ)
+	if name == "" {
+		return "provider"
+	}
+	return name
+}
+
+func (f *file) definition(name string, s *jsonprovider.Schema, resource bool) error {
+	if s == nil || s.Block == nil {
+		f.printf("#%s: {...}\n\n", name)
+		return nil
+	}
+	if s.Block.Description != "" {
+		f.comment(s.Block.Description)
+	}
+	if s.Block.Deprecated {
+		f.comment("Deprecated")
+	}
+	f.printf("#%s: {\n#meta\n", name)
+	if resource {
+		f.printf("provisioner?: _\nconnection?: {...}\n")
+	}
+	if err := f.blockBody(s.Block); err != nil {
+		return err
+	}
+	f.printf("}\n\n")
+	return nil
+}
+
+func (f *file) blockBody(b *jsonprovider.Block) error {
+	for _, name := range yagu.SortedKeys(b.Attributes) {
+		if err := f.attribute(name, b.Attributes[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, name := range yagu.SortedKeys(b.BlockTypes) {
+		bt := b.BlockTypes[name]
+		if bt.Block != nil && bt.Block.Description != "" {
+			f.comment(bt.Block.Description)
+		}
+		f.printf("%s: ", field(name, bt.MinItems > 0))
+		if err := f.blockType(bt); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		f.printf("\n")
+	}
+	return nil
+}
+
+func (f *file) blockType(bt *jsonprovider.BlockType) error {
+	single := func() error {
+		f.printf("{\n")
+		if bt.Block != nil {
+			if err := f.blockBody(bt.Block); err != nil {
+				return err
+			}
+		}
+		f.printf("}")
+		return nil
+	}
+
+	switch bt.NestingMode {
+	case "single", "group":
+		return single()
+	case "map":
+		f.printf("[string]: ")
+		return single()
+	default:
+		// list and set blocks may also be written as a single struct,
+		// the way the json configuration syntax allows
+		if err := single(); err != nil {
+			return err
+		}
+		f.printf(" | [...")
+		if err := single(); err != nil {
+			return err
+		}
+		f.printf("]")
+		return nil
+	}
+}
+
+func (f *file) attribute(name string, a *jsonprovider.Attribute) error {
+	if a.Description != "" {
+		f.comment(a.Description)
+	}
+	if a.Deprecated {
+		f.comment("Deprecated")
+	}
+
+	// attributes which are only computed can be read, never set
+	if a.Computed && !a.Optional && !a.Required {
+		f.printf("%s: _|_ // computed by the provider\n", field(name, false))
+		return nil
+	}
+
+	f.printf("%s: ", field(name, a.Required))
+	if a.AttributeNestedType != nil {
+		if err := f.nestedType(a.AttributeNestedType); err != nil {
+			return err
+		}
+		f.printf("\n")
+		return nil
+	}
+
+	var ty cty.Type
+	if err := json.Unmarshal(a.AttributeType, &ty); err != nil {
+		return fmt.Errorf("invalid type: %w", err)
+	}
+	f.printf("%s\n", typeExpr(ty))
+	return nil
+}
+
+func (f *file) nestedType(nt *jsonprovider.NestedType) error {
+	obj := func() error {
+		f.printf("{\n")
+		for _, name := range yagu.SortedKeys(nt.Attributes) {
+			if err := f.attribute(name, nt.Attributes[name]); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		f.printf("}")
+		return nil
+	}
+
+	switch nt.NestingMode {
+	case "list", "set":
+		f.printf("[...")
+		defer f.printf("] | #expr")
+	case "map":
+		f.printf("{[string]: ")
+		defer f.printf("} | #expr")
+	}
+	return obj()
+}
+
+// typeExpr returns the CUE expression accepting values of the given type.
+// Every type other than string also accepts a tofu expression.
+func typeExpr(ty cty.Type) string {
+	switch {
+	case ty == cty.String:
+		return "string"
+	case ty == cty.Number:
+		return "number | #expr"
+	case ty == cty.Bool:
+		return "bool | #expr"
+	case ty == cty.DynamicPseudoType:
+		return "_"
+	case ty.IsListType() || ty.IsSetType():
+		return fmt.Sprintf("[...(%s)] | #expr", typeExpr(ty.ElementType()))
+	case ty.IsMapType():
+		return fmt.Sprintf("{[string]: %s} | #expr", typeExpr(ty.ElementType()))
+	case ty.IsTupleType():
+		elems := make([]string, 0, len(ty.TupleElementTypes()))
+		for _, et := range ty.TupleElementTypes() {
+			elems = append(elems, typeExpr(et))
+		}
+		return fmt.Sprintf("[%s] | #expr", strings.Join(elems, ", "))
+	case ty.IsObjectType():
+		var b strings.Builder
+		b.WriteString("{")
+		for _, name := range yagu.SortedKeys(ty.AttributeTypes()) {
+			fmt.Fprintf(&b, "%s: %s, ", field(name, !ty.AttributeOptional(name)), typeExpr(ty.AttributeType(name)))
+		}
+		b.WriteString("} | #expr")
+		return b.String()
+	default:
+		return "_"
+	}
+}
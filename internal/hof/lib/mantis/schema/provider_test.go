@@ -0,0 +1,111 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/command/jsonprovider"
+)
+
+func attr(ty string, required, optional, computed bool) *jsonprovider.Attribute {
+	return &jsonprovider.Attribute{
+		AttributeType: json.RawMessage(ty),
+		Required:      required,
+		Optional:      optional,
+		Computed:      computed,
+	}
+}
+
+func TestProvider(t *testing.T) {
+	p := &jsonprovider.Provider{
+		ResourceSchemas: map[string]*jsonprovider.Schema{
+			"demo_instance": {Block: &jsonprovider.Block{
+				Attributes: map[string]*jsonprovider.Attribute{
+					"ami":  attr(`"string"`, true, false, false),
+					"size": attr(`"number"`, false, true, false),
+					"tags": attr(`["map","string"]`, false, true, false),
+					"id":   attr(`"string"`, false, false, true),
+				},
+				BlockTypes: map[string]*jsonprovider.BlockType{
+					"disk": {NestingMode: "list", Block: &jsonprovider.Block{
+						Attributes: map[string]*jsonprovider.Attribute{
+							"gb": attr(`"number"`, true, false, false),
+						},
+					}},
+				},
+			}},
+		},
+		DataSourceSchemas: map[string]*jsonprovider.Schema{
+			"demo_ami": {Block: &jsonprovider.Block{
+				Attributes: map[string]*jsonprovider.Attribute{
+					"name": attr(`"string"`, false, true, false),
+				},
+			}},
+		},
+	}
+
+	out, err := Provider(ProviderInfo{Source: "registry.opentofu.org/acme/demo-cloud", Type: "demo-cloud", Version: "1.0.0"}, p)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "package demo_cloud")
+
+	schema := cuecontext.New().CompileBytes(out)
+	require.NoError(t, schema.Err())
+
+	check := func(def, config string) error {
+		v := schema.LookupPath(cue.ParsePath(def)).Unify(schema.Context().CompileString(config))
+		return v.Validate(cue.Concrete(true))
+	}
+
+	cases := []struct {
+		name, def, config string
+		ok                bool
+	}{
+		{"valid", "#resource", `demo_instance: web: {ami: "ami-1", size: 2, tags: a: "b", disk: [{gb: 10}]}`, true},
+		{"single block", "#resource", `demo_instance: web: {ami: "ami-1", disk: gb: 10}`, true},
+		{"template", "#resource", `demo_instance: web: {ami: "${var.ami}", size: "${var.size}", count: "${var.n}"}`, true},
+		{"missing required", "#resource", `demo_instance: web: size: 2`, false},
+		{"computed", "#resource", `demo_instance: web: {ami: "ami-1", id: "i-1"}`, false},
+		{"unknown attribute", "#resource", `demo_instance: web: {ami: "ami-1", colour: "red"}`, false},
+		{"wrong type", "#resource", `demo_instance: web: {ami: "ami-1", size: "large"}`, false},
+		{"unknown type", "#resource", `demo_bucket: b: {}`, false},
+		{"data source", "#data", `demo_ami: latest: name: "x"`, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := check(c.def, c.config)
+			if c.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	assert.Equal(t, "name", label("name"))
+	assert.Equal(t, `"#name"`, label("#name"))
+	assert.Equal(t, `"_name"`, label("_name"))
+	assert.Equal(t, `"a-b"`, label("a-b"))
+	assert.Equal(t, "name!", field("name", true))
+	assert.Equal(t, "name?", field("name", false))
+	assert.Equal(t, "provider", packageName(""))
+	assert.Equal(t, "a_b_c", packageName("a-b.c"))
+}
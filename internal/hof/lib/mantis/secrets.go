@@ -0,0 +1,138 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+
+	"github.com/opentofu/opentofu/internal/encryption"
+	"github.com/opentofu/opentofu/internal/encryption/config"
+)
+
+// sopsBinary is the sops CLI decrypting SOPS files, with the age, PGP or
+// KMS keys it finds in its environment
+var sopsBinary = "sops"
+
+// secretsPayload wraps the values of an encrypted secrets file, so that
+// they can not be mistaken for the fields of a state file
+type secretsPayload struct {
+	Data map[string]string `json:"data"`
+}
+
+// secretsEncryption returns the encryption of the state target of an
+// encryption config, the JSON of the encryption block of a TF task
+func secretsEncryption(encryptionConfig []byte) (encryption.StateEncryption, error) {
+	file, diags := hcljson.Parse(encryptionConfig, "encryption")
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	cfg, diags := config.DecodeConfig(file.Body, hcl.Range{Filename: "encryption"})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if cfg.State == nil {
+		return nil, fmt.Errorf("the encryption config has no state target, whose method encrypts secrets")
+	}
+	enc, diags := encryption.New(encryption.DefaultRegistry, cfg)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return enc.State(), nil
+}
+
+// EncryptSecrets encrypts values with the method of the state target of an
+// encryption config, for DecryptSecrets
+func EncryptSecrets(encryptionConfig []byte, values map[string]string) ([]byte, error) {
+	enc, err := secretsEncryption(encryptionConfig)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := json.Marshal(secretsPayload{Data: values})
+	if err != nil {
+		return nil, err
+	}
+	data, err := enc.EncryptState(plain)
+	if err != nil {
+		return nil, err
+	}
+	if ok, _ := encryption.IsEncryptionPayload(data); !ok {
+		return nil, fmt.Errorf("the state target of the encryption config does not encrypt")
+	}
+	return data, nil
+}
+
+// DecryptSecrets decrypts the values written by EncryptSecrets
+func DecryptSecrets(encryptionConfig []byte, data []byte) (map[string]string, error) {
+	if ok, _ := encryption.IsEncryptionPayload(data); !ok {
+		return nil, fmt.Errorf("not an encrypted secrets file, create one with mantis secret encrypt")
+	}
+	enc, err := secretsEncryption(encryptionConfig)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := enc.DecryptState(data)
+	if err != nil {
+		return nil, err
+	}
+	var payload secretsPayload
+	if err := json.Unmarshal(plain, &payload); err != nil {
+		return nil, fmt.Errorf("invalid secrets file: %v", err)
+	}
+	return payload.Data, nil
+}
+
+// DecryptSOPS decrypts a SOPS file with the sops CLI, for SecretValues
+func DecryptSOPS(ctx context.Context, path string) (map[string]interface{}, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, sopsBinary, "--decrypt", "--output-type", "json", path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("sops failed to decrypt %s: %s", path, msg)
+		}
+		return nil, fmt.Errorf("sops failed to decrypt %s: %w", path, err)
+	}
+
+	var decoded map[string]interface{}
+	dec := json.NewDecoder(&stdout)
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("unexpected output of sops for %s: %v", path, err)
+	}
+	return decoded, nil
+}
+
+// SecretValues converts the values of a secrets file to strings, failing
+// on nested values which a Secret can not hold
+func SecretValues(decoded map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string, len(decoded))
+	for k, v := range decoded {
+		switch v := v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("value of %s is not a scalar, secrets files must be flat", k)
+		case nil:
+			values[k] = ""
+		default:
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,101 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pbkdf2Encryption is the JSON of an encryption block of a TF task whose
+// state is encrypted with a key derived from a passphrase
+func pbkdf2Encryption(passphrase string) []byte {
+	return []byte(`{
+  "key_provider": {"pbkdf2": {"k": {"passphrase": "` + passphrase + `"}}},
+  "method": {"aes_gcm": {"m": {"keys": "${key_provider.pbkdf2.k}"}}},
+  "state": {"method": "${method.aes_gcm.m}"}
+}`)
+}
+
+func TestEncryptSecrets(t *testing.T) {
+	values := map[string]string{"user": "admin", "password": "hunter2-hunter2"}
+	data, err := EncryptSecrets(pbkdf2Encryption("correct horse battery staple"), values)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+
+	decrypted, err := DecryptSecrets(pbkdf2Encryption("correct horse battery staple"), data)
+	require.NoError(t, err)
+	assert.Equal(t, values, decrypted)
+
+	_, err = DecryptSecrets(pbkdf2Encryption("wrong horse battery staple!"), data)
+	assert.Error(t, err)
+}
+
+func TestEncryptSecretsErrors(t *testing.T) {
+	// without a state target nothing encrypts the values
+	_, err := EncryptSecrets([]byte(`{"key_provider": {"pbkdf2": {"k": {"passphrase": "correct horse battery staple"}}}}`), map[string]string{"a": "b"})
+	assert.ErrorContains(t, err, "no state target")
+
+	_, err = DecryptSecrets(pbkdf2Encryption("correct horse battery staple"), []byte(`{"data": {"a": "b"}}`))
+	assert.ErrorContains(t, err, "not an encrypted secrets file")
+}
+
+func TestDecryptSOPS(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\n[ \"$4\" = secrets.enc.yaml ] || exit 1\necho '{\"user\": \"admin\", \"port\": 5432}'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sops"), []byte(script), 0755))
+	binary := sopsBinary
+	sopsBinary = filepath.Join(dir, "sops")
+	t.Cleanup(func() { sopsBinary = binary })
+
+	decoded, err := DecryptSOPS(context.Background(), "secrets.enc.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": "admin", "port": json.Number("5432")}, decoded)
+
+	_, err = DecryptSOPS(context.Background(), "other.yaml")
+	assert.ErrorContains(t, err, "sops failed to decrypt other.yaml")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = DecryptSOPS(ctx, "secrets.enc.yaml")
+	assert.Error(t, err)
+}
+
+func TestSecretValues(t *testing.T) {
+	values, err := SecretValues(map[string]interface{}{
+		"user":    "admin",
+		"port":    json.Number("5432"),
+		"ratio":   0.5,
+		"enabled": true,
+		"empty":   nil,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"user":    "admin",
+		"port":    "5432",
+		"ratio":   "0.5",
+		"enabled": "true",
+		"empty":   "",
+	}, values)
+
+	_, err = SecretValues(map[string]interface{}{"db": map[string]interface{}{"user": "admin"}})
+	assert.EqualError(t, err, "value of db is not a scalar, secrets files must be flat")
+	_, err = SecretValues(map[string]interface{}{"hosts": []interface{}{"a"}})
+	assert.EqualError(t, err, "value of hosts is not a scalar, secrets files must be flat")
+}
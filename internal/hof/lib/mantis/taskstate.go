@@ -0,0 +1,100 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// TaskStatePath returns the state file of a TF task, relative to the flow root
+func TaskStatePath(taskID string) string {
+	return fmt.Sprintf(MantisStateFilePath, taskID)
+}
+
+// TaskBackendStatePath returns where tofu keeps the backend configuration
+// of a TF task, relative to its data directory
+func TaskBackendStatePath(taskID string) string {
+	return fmt.Sprintf("./terraform/back_%s.tfstate", taskID)
+}
+
+// PendingMove picks which of the tasks named by a moved declaration still
+// holds the state of task to, following the semantics of moved blocks:
+// a source without state was already moved or never applied, and a move
+// onto a task which already has state is blocked and left in place.
+//
+// The refactoring package is not used here: it moves resource instances
+// between addresses within a single state, while every task has a state
+// file of its own, so moving a task moves whole files. Moved and removed
+// blocks within a task still go through it when tofu plans the task.
+func PendingMove(root, to string, from []string) (source string, blocked []string) {
+	occupied := exists(filepath.Join(root, TaskStatePath(to)))
+	for _, f := range from {
+		if f == to || !exists(filepath.Join(root, TaskStatePath(f))) {
+			continue
+		}
+		if occupied || source != "" {
+			blocked = append(blocked, f)
+			continue
+		}
+		source = f
+	}
+	return source, blocked
+}
+
+// MoveTaskState moves the state of a task, its backup and its working
+// directory over to another task ID
+func MoveTaskState(root, from, to string) error {
+	if from == to {
+		return fmt.Errorf("task %q can not be moved onto itself", from)
+	}
+
+	src := filepath.Join(root, TaskStatePath(from))
+	dst := filepath.Join(root, TaskStatePath(to))
+	if !exists(src) {
+		return fmt.Errorf("task %q has no state at %s", from, src)
+	}
+	if exists(dst) {
+		return fmt.Errorf("task %q already has state at %s", to, dst)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	if exists(src + ".backup") {
+		if err := os.Rename(src+".backup", dst+".backup"); err != nil {
+			return err
+		}
+	}
+
+	// the working directory only caches providers and modules,
+	// one already initialized for the new ID is kept
+	srcDir := filepath.Join(root, TaskWorkDir(from))
+	dstDir := filepath.Join(root, TaskWorkDir(to))
+	if exists(srcDir) && !exists(dstDir) {
+		if err := os.MkdirAll(filepath.Dir(dstDir), 0755); err != nil {
+			return err
+		}
+		return os.Rename(srcDir, dstDir)
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,70 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTaskState(t *testing.T, root, taskID string) {
+	path := filepath.Join(root, TaskStatePath(taskID))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(taskID), 0644))
+}
+
+func TestPendingMove(t *testing.T) {
+	root := t.TempDir()
+	writeTaskState(t, root, "old")
+	writeTaskState(t, root, "older")
+	writeTaskState(t, root, "taken")
+
+	source, blocked := PendingMove(root, "new", []string{"gone", "old", "older"})
+	assert.Equal(t, "old", source)
+	assert.Equal(t, []string{"older"}, blocked, "only one source can be moved")
+
+	source, blocked = PendingMove(root, "taken", []string{"old"})
+	assert.Empty(t, source)
+	assert.Equal(t, []string{"old"}, blocked, "destination already has state")
+
+	source, blocked = PendingMove(root, "new", []string{"gone", "new"})
+	assert.Empty(t, source)
+	assert.Empty(t, blocked)
+}
+
+func TestMoveTaskState(t *testing.T) {
+	root := t.TempDir()
+	writeTaskState(t, root, "vpc.subnets")
+	backup := filepath.Join(root, TaskStatePath("vpc.subnets")+".backup")
+	require.NoError(t, os.WriteFile(backup, nil, 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, TaskWorkDir("vpc.subnets"), ".terraform"), 0755))
+
+	require.NoError(t, MoveTaskState(root, "vpc.subnets", "network.vpc.subnets"))
+
+	data, err := os.ReadFile(filepath.Join(root, TaskStatePath("network.vpc.subnets")))
+	require.NoError(t, err)
+	assert.Equal(t, "vpc.subnets", string(data))
+	assert.FileExists(t, filepath.Join(root, TaskStatePath("network.vpc.subnets")+".backup"))
+	assert.DirExists(t, filepath.Join(root, TaskWorkDir("network.vpc.subnets"), ".terraform"))
+	assert.NoFileExists(t, filepath.Join(root, TaskStatePath("vpc.subnets")))
+	assert.NoDirExists(t, filepath.Join(root, TaskWorkDir("vpc.subnets")))
+
+	assert.ErrorContains(t, MoveTaskState(root, "vpc.subnets", "other"), "has no state")
+	writeTaskState(t, root, "other")
+	assert.ErrorContains(t, MoveTaskState(root, "other", "network.vpc.subnets"), "already has state")
+	assert.ErrorContains(t, MoveTaskState(root, "other", "other"), "onto itself")
+}
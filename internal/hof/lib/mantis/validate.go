@@ -22,6 +22,8 @@ import (
 	"cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/load"
 	"cuelang.org/go/cue/token"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis/schema"
 )
 
 func Validate(dir string) error {
@@ -38,6 +40,12 @@ func Validate(dir string) error {
 		return fmt.Errorf("no CUE files found in directory: %s", dir)
 	}
 
+	// Kubernetes schemas imported with mantis schema import k8s|crd
+	kinds, err := schema.LoadKinds(ctx, dir)
+	if err != nil {
+		return err
+	}
+
 	var validationErrors []errors.Error // Change this to store CUE errors directly
 
 	for _, inst := range instances {
@@ -71,6 +79,10 @@ func Validate(dir string) error {
 		} else {
 			fmt.Printf("Validation successful for %s\n", inst.Dir)
 		}
+
+		if !kinds.Empty() {
+			validationErrors = append(validationErrors, validateK8sTasks(value, kinds)...)
+		}
 	}
 
 	// If there were any errors, print them in a more readable format
@@ -87,6 +99,43 @@ func Validate(dir string) error {
 	return nil
 }
 
+// validateK8sTasks checks the config of every K8s and K8sJob task against the
+// schema of its apiVersion and kind
+func validateK8sTasks(value cue.Value, kinds *schema.Kinds) []errors.Error {
+	var errs []errors.Error
+	value.Walk(func(v cue.Value) bool {
+		if !IsTask(v, MantisTaskK8s) && !IsTask(v, MantisTaskK8sJob) {
+			return true
+		}
+		// the config of a for_each template depends on each,
+		// it is only complete in the instances of the flow
+		if v.LookupPath(cue.ParsePath(MantisTaskForEach)).Exists() {
+			return false
+		}
+		config := v.LookupPath(cue.ParsePath("config"))
+		if err := kinds.Check(config); err != nil {
+			fmt.Printf("Schema errors in %s\n", v.Path())
+			if cueerr, ok := err.(errors.Error); ok {
+				errs = append(errs, cueerr)
+			} else {
+				errs = append(errs, errors.Newf(config.Pos(), "%s: %v", v.Path(), err))
+			}
+		}
+		return false
+	}, nil)
+	return errs
+}
+
+// IsTask reports whether v is a task of the given type, such as MantisTaskTF
+func IsTask(v cue.Value, taskType string) bool {
+	for _, attr := range v.Attributes(cue.ValueAttr) {
+		if attr.Name() == "task" && attr.Contents() == taskType {
+			return true
+		}
+	}
+	return false
+}
+
 func printDetailedError(err errors.Error) {
 	errs := errors.Errors(err)
 	for i, e := range errs {
@@ -0,0 +1,69 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/hof/lib/mantis/schema"
+)
+
+func TestValidateK8sTasks(t *testing.T) {
+	o, err := schema.LoadOpenAPI("schema/testdata/openapi.json")
+	require.NoError(t, err)
+	out, err := schema.Kubernetes("test", o)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, schema.GenDir, "k8s.io", "api", "schema.cue")
+	require.NoError(t, os.MkdirAll(filepath.Dir(target), 0755))
+	require.NoError(t, os.WriteFile(target, out, 0644))
+
+	ctx := cuecontext.New()
+	kinds, err := schema.LoadKinds(ctx, dir)
+	require.NoError(t, err)
+
+	flow := ctx.CompileString(`
+f: {
+	@flow(f)
+	good: {
+		@task(mantis.core.K8s)
+		config: {apiVersion: "v1", kind: "ConfigMap", metadata: name: "good", data: k: "v"}
+	}
+	bad: {
+		@task(mantis.core.K8s)
+		config: {apiVersion: "v1", kind: "ConfigMap", metadata: name: "bad", data: k: 1}
+	}
+	each: {
+		@task(mantis.core.K8s)
+		for_each: ["a"]
+		config: {apiVersion: "v1", kind: "ConfigMap", data: k: 1}
+	}
+	tf: {
+		@task(mantis.core.TF)
+		config: {apiVersion: "v1", kind: "ConfigMap", data: k: 1}
+	}
+}
+`)
+	require.NoError(t, flow.Err())
+
+	errs := validateK8sTasks(flow, kinds)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "data.k")
+}
@@ -0,0 +1,246 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/depsfile"
+	"github.com/opentofu/opentofu/internal/getproviders"
+	"github.com/opentofu/opentofu/internal/providercache"
+)
+
+const (
+	// MantisTasksDir holds a working directory per TF task, so tasks never
+	// share module installs, backend configuration or lock files
+	MantisTasksDir = ".mantis/tasks"
+
+	// MantisPluginCacheDir is the provider cache shared by all tasks, used
+	// when the CLI configuration does not set plugin_cache_dir
+	MantisPluginCacheDir = ".mantis/plugin-cache"
+
+	// MantisProvidersLockFile pins provider versions across all tasks
+	MantisProvidersLockFile = ".mantis/providers.lock.hcl"
+
+	// MantisTaskLockFile is the dependency lock file within a task's working directory
+	MantisTaskLockFile = ".terraform.lock.hcl"
+)
+
+// TaskWorkDir returns the working directory of a task, relative to the flow root
+func TaskWorkDir(taskID string) string {
+	name := strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(taskID)
+	return filepath.Join(MantisTasksDir, name)
+}
+
+// EnsureTaskWorkDir creates the working directory of a task on first use
+func EnsureTaskWorkDir(taskID string) (string, error) {
+	workDir := TaskWorkDir(taskID)
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create task working directory: %w", err)
+	}
+	return workDir, nil
+}
+
+var (
+	pluginCachesMu sync.Mutex
+	pluginCaches   = map[string]*sync.Mutex{}
+)
+
+// LockPluginCache serializes the provider installs of the tasks sharing a
+// plugin cache, which tofu does not support writing to concurrently. It
+// returns the function releasing the lock.
+func LockPluginCache(dir string) (unlock func()) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	pluginCachesMu.Lock()
+	mu, ok := pluginCaches[dir]
+	if !ok {
+		mu = &sync.Mutex{}
+		pluginCaches[dir] = mu
+	}
+	pluginCachesMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// SeedTaskLocks copies the provider versions pinned by `mantis providers lock`
+// into the lock file of a task before it is initialized.
+func SeedTaskLocks(root, workDir string) error {
+	shared, err := loadLocks(filepath.Join(root, MantisProvidersLockFile))
+	if err != nil || shared == nil {
+		return err
+	}
+
+	lockFile := filepath.Join(workDir, MantisTaskLockFile)
+	locks, err := loadLocks(lockFile)
+	if err != nil {
+		return err
+	}
+	if locks == nil {
+		locks = depsfile.NewLocks()
+	}
+
+	for addr, pin := range shared.AllProviders() {
+		constraints := pin.VersionConstraints()
+		if own := locks.Provider(addr); own != nil {
+			constraints = own.VersionConstraints()
+		}
+		locks.SetProvider(addr, pin.Version(), constraints, pin.AllHashes())
+	}
+
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return err
+	}
+	if diags := depsfile.SaveLocksToFile(locks, lockFile); diags.HasErrors() {
+		return diags.Err()
+	}
+	return nil
+}
+
+// LockProviders selects one version of each provider used by the tasks
+// below root, the newest which satisfies the constraints of every task,
+// and writes it to the shared lock file. The lock file of a task is only
+// updated where the selected version is installed in the task, the others
+// are listed as pending and pick up the pin on their next init.
+func LockProviders(root string) (locks *depsfile.Locks, pending []string, err error) {
+	lockFiles, err := filepath.Glob(filepath.Join(root, MantisTasksDir, "*", MantisTaskLockFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lockFiles) == 0 {
+		return nil, nil, fmt.Errorf("no task lock files found in %s; run the flow with -I first", filepath.Join(root, MantisTasksDir))
+	}
+
+	taskLocks := make(map[string]*depsfile.Locks, len(lockFiles))
+	candidates := map[addrs.Provider][]getproviders.Version{}
+	constraints := map[addrs.Provider]getproviders.VersionConstraints{}
+	hashes := map[addrs.Provider]map[string][]getproviders.Hash{}
+
+	for _, lockFile := range lockFiles {
+		locks, err := loadLocks(lockFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		taskLocks[lockFile] = locks
+
+		for addr, lock := range locks.AllProviders() {
+			candidates[addr] = append(candidates[addr], lock.Version())
+			constraints[addr] = mergeConstraints(constraints[addr], lock.VersionConstraints())
+			if hashes[addr] == nil {
+				hashes[addr] = map[string][]getproviders.Hash{}
+			}
+			v := lock.Version().String()
+			hashes[addr][v] = mergeHashes(hashes[addr][v], lock.AllHashes())
+		}
+	}
+
+	merged := depsfile.NewLocks()
+	for addr, versions := range candidates {
+		allowed := getproviders.MeetingConstraints(constraints[addr])
+		sort.Slice(versions, func(i, j int) bool { return versions[j].LessThan(versions[i]) })
+
+		var selected *getproviders.Version
+		for i := range versions {
+			if allowed.Has(versions[i]) {
+				selected = &versions[i]
+				break
+			}
+		}
+		if selected == nil {
+			return nil, nil, fmt.Errorf("no locked version of %s satisfies the constraints of every task: %s", addr, getproviders.VersionConstraintsString(constraints[addr]))
+		}
+		merged.SetProvider(addr, *selected, constraints[addr], hashes[addr][selected.String()])
+	}
+
+	for lockFile, locks := range taskLocks {
+		installed := providercache.NewDir(filepath.Join(filepath.Dir(lockFile), ".terraform", "providers"))
+		changed := false
+		for addr, lock := range locks.AllProviders() {
+			pin := merged.Provider(addr)
+			if pin.Version() == lock.Version() {
+				continue
+			}
+			if installed.ProviderVersion(addr, pin.Version()) == nil {
+				pending = append(pending, fmt.Sprintf("%s: %s %s", filepath.Base(filepath.Dir(lockFile)), addr, pin.Version()))
+				continue
+			}
+			locks.SetProvider(addr, pin.Version(), lock.VersionConstraints(), pin.AllHashes())
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+		if diags := depsfile.SaveLocksToFile(locks, lockFile); diags.HasErrors() {
+			return nil, nil, diags.Err()
+		}
+	}
+	sort.Strings(pending)
+
+	sharedFile := filepath.Join(root, MantisProvidersLockFile)
+	if err := os.MkdirAll(filepath.Dir(sharedFile), 0755); err != nil {
+		return nil, nil, err
+	}
+	if diags := depsfile.SaveLocksToFile(merged, sharedFile); diags.HasErrors() {
+		return nil, nil, diags.Err()
+	}
+	return merged, pending, nil
+}
+
+// loadLocks returns nil when the lock file does not exist
+func loadLocks(lockFile string) (*depsfile.Locks, error) {
+	if _, err := os.Stat(lockFile); os.IsNotExist(err) {
+		return nil, nil
+	}
+	locks, diags := depsfile.LoadLocksFromFile(lockFile)
+	if diags.HasErrors() {
+		return nil, diags.Err()
+	}
+	return locks, nil
+}
+
+func mergeConstraints(a, b getproviders.VersionConstraints) getproviders.VersionConstraints {
+	out := a
+	for _, c := range b {
+		found := false
+		for _, existing := range out {
+			if existing == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func mergeHashes(a, b []getproviders.Hash) []getproviders.Hash {
+	seen := make(map[getproviders.Hash]bool, len(a)+len(b))
+	var out []getproviders.Hash
+	for _, h := range append(append([]getproviders.Hash{}, a...), b...) {
+		if !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	return out
+}
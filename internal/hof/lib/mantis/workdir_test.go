@@ -0,0 +1,101 @@
+/*
+/*
+ * Copyright (c) 2024 Augur AI, Inc.
+ * This Source Code Form is subject to the terms of the Mozilla Public License, v. 2.0. 
+ * If a copy of the MPL was not distributed with this file, you can obtain one at https://mozilla.org/MPL/2.0/.
+ *
+ 
+ * Copyright (c) 2024 Augur AI, Inc.
+ *
+ * This file is licensed under the Augur AI Proprietary License.
+ */
+
+package mantis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/depsfile"
+	"github.com/opentofu/opentofu/internal/getproviders"
+)
+
+func TestLockProviders(t *testing.T) {
+	root := t.TempDir()
+	addr := addrs.MustParseProviderSourceString("acme/demo")
+	v1 := getproviders.MustParseVersion("1.0.0")
+	v2 := getproviders.MustParseVersion("1.1.0")
+
+	task := func(id string, locked getproviders.Version, installed ...getproviders.Version) string {
+		workDir := filepath.Join(root, TaskWorkDir(id))
+		for _, v := range installed {
+			pkg := filepath.Join(workDir, ".terraform", "providers", addr.Hostname.String(), addr.Namespace, addr.Type, v.String(), getproviders.CurrentPlatform.String())
+			require.NoError(t, os.MkdirAll(pkg, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(pkg, "terraform-provider-demo"), nil, 0755))
+		}
+		locks := depsfile.NewLocks()
+		locks.SetProvider(addr, locked, getproviders.MustParseVersionConstraints(">= 1.0"), nil)
+		lockFile := filepath.Join(workDir, MantisTaskLockFile)
+		require.False(t, depsfile.SaveLocksToFile(locks, lockFile).HasErrors())
+		return lockFile
+	}
+	locked := func(lockFile string) getproviders.Version {
+		locks, err := loadLocks(lockFile)
+		require.NoError(t, err)
+		return locks.Provider(addr).Version()
+	}
+
+	newest := task("newest", v2, v2)
+	upgradable := task("upgradable", v1, v1, v2)
+	missing := task("missing", v1, v1)
+
+	merged, pending, err := LockProviders(root)
+	require.NoError(t, err)
+	assert.Equal(t, v2, merged.Provider(addr).Version())
+	assert.Equal(t, []string{"missing: " + addr.String() + " 1.1.0"}, pending)
+
+	assert.Equal(t, v2, locked(newest))
+	assert.Equal(t, v2, locked(upgradable))
+	assert.Equal(t, v1, locked(missing), "lock of a version which is not installed")
+
+	shared, err := loadLocks(filepath.Join(root, MantisProvidersLockFile))
+	require.NoError(t, err)
+	assert.Equal(t, v2, shared.Provider(addr).Version())
+}
+
+func TestLockProvidersWithoutTasks(t *testing.T) {
+	_, _, err := LockProviders(t.TempDir())
+	assert.ErrorContains(t, err, "run the flow with -I first")
+}
+
+func TestLockPluginCache(t *testing.T) {
+	dir := t.TempDir()
+	unlock := LockPluginCache(dir)
+
+	// another cache is not held up
+	LockPluginCache(t.TempDir())()
+
+	locked := make(chan struct{})
+	go func() {
+		LockPluginCache(filepath.Join(dir, "."))()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the same cache was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cache was not unlocked")
+	}
+}
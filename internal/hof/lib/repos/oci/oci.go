@@ -102,6 +102,20 @@ func GetRefHash(url, ref string) (string, error) {
 	return s, nil
 }
 
+// Digest returns the digest of the manifest an image reference such as
+// repo:tag points at, sha256:..., which for multi-platform images is the
+// digest of their index
+func Digest(image string) (string, error) {
+	if debug {
+		fmt.Println("oci.Digest:", image)
+	}
+	digest, err := crane.Digest(image, crane.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", fmt.Errorf("while resolving the digest of %s: %w", image, err)
+	}
+	return digest, nil
+}
+
 func Pull(url, outPath string) error {
 	if debug {
 		fmt.Println("oci.Pull:", outPath, url)
@@ -0,0 +1,98 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package tfdiags
+
+// SourceMapper translates source ranges within generated configuration back
+// to the files that the configuration was generated from.
+type SourceMapper interface {
+	// MapRange returns the original range for the given range, or false if
+	// the range does not belong to the generated configuration.
+	MapRange(rng SourceRange) (SourceRange, bool)
+
+	// Sources returns the content of the files which mapped ranges refer to,
+	// keyed by filename, so that diagnostic snippets can be rendered.
+	Sources() map[string][]byte
+}
+
+// MergeSources returns the given config sources along with the sources of
+// the mapper, for rendering the snippets of mapped diagnostics.
+func MergeSources(sources map[string][]byte, mapper SourceMapper) map[string][]byte {
+	if mapper == nil {
+		return sources
+	}
+	merged := make(map[string][]byte, len(sources))
+	for name, src := range sources {
+		merged[name] = src
+	}
+	for name, src := range mapper.Sources() {
+		merged[name] = src
+	}
+	return merged
+}
+
+// mappedDiagnostic implements the Diagnostic interface by wrapping another
+// Diagnostic while replacing its source ranges.
+type mappedDiagnostic struct {
+	original Diagnostic
+	source   Source
+}
+
+var _ Diagnostic = mappedDiagnostic{}
+
+// MapSources returns the given diagnostics with their subject and context
+// ranges translated by the given mapper. Diagnostics without a mappable
+// subject are returned unchanged.
+func MapSources(originals Diagnostics, mapper SourceMapper) Diagnostics {
+	if mapper == nil {
+		return originals
+	}
+
+	var diags Diagnostics
+	for _, diag := range originals {
+		src := diag.Source()
+		if src.Subject == nil {
+			diags = diags.Append(diag)
+			continue
+		}
+		subject, ok := mapper.MapRange(*src.Subject)
+		if !ok {
+			diags = diags.Append(diag)
+			continue
+		}
+
+		mapped := Source{Subject: &subject}
+		if src.Context != nil {
+			if context, ok := mapper.MapRange(*src.Context); ok {
+				mapped.Context = &context
+			}
+		}
+		diags = diags.Append(mappedDiagnostic{
+			original: diag,
+			source:   mapped,
+		})
+	}
+	return diags
+}
+
+func (m mappedDiagnostic) Severity() Severity {
+	return m.original.Severity()
+}
+
+func (m mappedDiagnostic) Description() Description {
+	return m.original.Description()
+}
+
+func (m mappedDiagnostic) Source() Source {
+	return m.source
+}
+
+// FromExpr returns nil, since the expression belongs to the generated
+// configuration and its ranges no longer line up with the mapped source.
+func (m mappedDiagnostic) FromExpr() *FromExpr {
+	return nil
+}
+
+func (m mappedDiagnostic) ExtraInfo() interface{} {
+	return m.original.ExtraInfo()
+}
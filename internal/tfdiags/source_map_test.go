@@ -0,0 +1,87 @@
+// Copyright (c) The OpenTofu Authors
+// SPDX-License-Identifier: MPL-2.0
+
+package tfdiags
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+type testSourceMapper struct{}
+
+func (testSourceMapper) MapRange(rng SourceRange) (SourceRange, bool) {
+	if rng.Filename != "generated.json" {
+		return rng, false
+	}
+	return SourceRange{
+		Filename: "original.cue",
+		Start:    SourcePos{Line: rng.Start.Line + 10, Column: 1, Byte: 100},
+		End:      SourcePos{Line: rng.Start.Line + 10, Column: 5, Byte: 104},
+	}, true
+}
+
+func (testSourceMapper) Sources() map[string][]byte {
+	return map[string][]byte{"original.cue": []byte("")}
+}
+
+func TestMapSources(t *testing.T) {
+	var diags Diagnostics
+	diags = diags.Append(&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "generated",
+		Subject: &hcl.Range{
+			Filename: "generated.json",
+			Start:    hcl.Pos{Line: 1, Column: 1, Byte: 0},
+			End:      hcl.Pos{Line: 1, Column: 5, Byte: 4},
+		},
+	})
+	diags = diags.Append(&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "other",
+		Subject: &hcl.Range{
+			Filename: "main.tf",
+			Start:    hcl.Pos{Line: 2, Column: 1, Byte: 10},
+			End:      hcl.Pos{Line: 2, Column: 5, Byte: 14},
+		},
+	})
+	diags = diags.Append(Sourceless(Error, "sourceless", "detail"))
+
+	mapped := MapSources(diags, testSourceMapper{})
+	if len(mapped) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %d", len(mapped))
+	}
+
+	if got := mapped[0].Source().Subject; got.Filename != "original.cue" || got.Start.Line != 11 {
+		t.Errorf("wrong mapped subject %#v", got)
+	}
+	if got := mapped[0].Description().Summary; got != "generated" {
+		t.Errorf("wrong summary %q", got)
+	}
+	if got := mapped[1].Source().Subject; got.Filename != "main.tf" {
+		t.Errorf("unmappable subject was changed to %#v", got)
+	}
+	if got := mapped[2].Source().Subject; got != nil {
+		t.Errorf("sourceless diagnostic gained a subject %#v", got)
+	}
+}
+
+func TestMergeSources(t *testing.T) {
+	sources := map[string][]byte{"main.tf": []byte("x")}
+
+	if got := MergeSources(sources, nil); len(got) != 1 {
+		t.Errorf("nil mapper changed the sources: %v", got)
+	}
+
+	merged := MergeSources(sources, testSourceMapper{})
+	if _, ok := merged["main.tf"]; !ok {
+		t.Error("config source was dropped")
+	}
+	if _, ok := merged["original.cue"]; !ok {
+		t.Error("mapper source is missing")
+	}
+	if len(sources) != 1 {
+		t.Error("config sources were modified")
+	}
+}
@@ -221,5 +221,5 @@ type EvalContext interface {
 	// Returns the currently configured encryption setup
 	GetEncryption() encryption.Encryption
 
-	UpdateHofCtxVariables(key string, vars cty.Value) *sync.Map
+	UpdateHofCtxVariables(key string, vars cty.Value, schema *configschema.Block) *sync.Map
 }
@@ -24,6 +24,7 @@ import (
 	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
 	"github.com/opentofu/opentofu/internal/instances"
 	"github.com/opentofu/opentofu/internal/lang"
+	"github.com/opentofu/opentofu/internal/lang/marks"
 	"github.com/opentofu/opentofu/internal/plans"
 	"github.com/opentofu/opentofu/internal/providers"
 	"github.com/opentofu/opentofu/internal/provisioners"
@@ -99,7 +100,7 @@ func (ctx *BuiltinEvalContext) WithPath(path addrs.ModuleInstance) EvalContext {
 	return &newCtx
 }
 
-func (ctx *BuiltinEvalContext) UpdateHofCtxVariables(key string, vars cty.Value) *sync.Map {
+func (ctx *BuiltinEvalContext) UpdateHofCtxVariables(key string, vars cty.Value, schema *configschema.Block) *sync.Map {
 	// Ensure TfContext is initialized
 	if ctx.TfContext == nil {
 		ctx.TfContext = &hofcontext.TFContext{}
@@ -110,13 +111,31 @@ func (ctx *BuiltinEvalContext) UpdateHofCtxVariables(key string, vars cty.Value)
 		ctx.TfContext.ParsedVariables = &sync.Map{}
 	}
 
+	// Nothing was read or planned, e.g. after a failed data source read
+	if vars == cty.NilVal || vars.IsNull() {
+		return ctx.TfContext.ParsedVariables
+	}
+	// attributes the schema declares sensitive are not marked by providers
+	if schema != nil {
+		vars = vars.MarkWithPaths(schema.ValueMarks(vars, nil))
+	}
+	vars, pvm := vars.UnmarkDeepWithPaths()
+
 	parts := strings.FieldsFunc(key, func(r rune) bool {
 		return r == '.' || r == '[' || r == ']'
 	})
+	for _, pv := range pvm {
+		if _, ok := pv.Marks[marks.Sensitive]; ok {
+			ctx.TfContext.AddSensitive(hofSensitivePath(parts, pv.Path))
+		}
+	}
 
 	current := ctx.TfContext.ParsedVariables
 	for i, part := range parts {
-		if i == len(parts)-1 {
+		if i == len(parts)-1 && !vars.IsKnown() {
+			// The whole object is only known after apply
+			current.Store(part, hofcontext.NewUnknownValue(vars.Type()))
+		} else if i == len(parts)-1 {
 			// At the last part, assign the new value
 			goMap, err := convertCtyValueMapToGoMap(vars.AsValueMap())
 			if err != nil {
@@ -164,11 +183,36 @@ func (ctx *BuiltinEvalContext) UpdateHofCtxVariables(key string, vars cty.Value)
 
 	return ctx.TfContext.ParsedVariables
 }
+// hofSensitivePath returns the dotted path within ParsedVariables of the
+// value at path in the resource instance stored under parts. The elements
+// of sets have no index, a path into a set stops at the whole set.
+func hofSensitivePath(parts []string, path cty.Path) string {
+	elems := append([]string(nil), parts...)
+	for _, step := range path {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			elems = append(elems, s.Name)
+		case cty.IndexStep:
+			switch s.Key.Type() {
+			case cty.String:
+				elems = append(elems, s.Key.AsString())
+			case cty.Number:
+				elems = append(elems, s.Key.AsBigFloat().String())
+			default:
+				return strings.Join(elems, ".")
+			}
+		}
+	}
+	return strings.Join(elems, ".")
+}
+
 func convertCtyValueMapToGoMap(ctyMap map[string]cty.Value) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
 	for key, value := range ctyMap {
 		switch {
+		case !value.IsKnown():
+			result[key] = hofcontext.NewUnknownValue(value.Type())
 		case value.IsNull():
 			result[key] = nil
 		case value.Type() == cty.String:
@@ -227,6 +271,8 @@ func convertCtyValueMapToGoMap(ctyMap map[string]cty.Value) (map[string]interfac
 // Convert cty.Value to Go interface{}
 func convertCtyValueToInterface(value cty.Value) (interface{}, error) {
 	switch {
+	case !value.IsKnown():
+		return hofcontext.NewUnknownValue(value.Type()), nil
 	case value.IsNull():
 		return nil, nil
 	case value.Type() == cty.String:
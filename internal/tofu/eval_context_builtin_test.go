@@ -7,10 +7,14 @@ package tofu
 
 import (
 	"reflect"
+	"sort"
 	"sync"
 	"testing"
 
 	"github.com/opentofu/opentofu/internal/addrs"
+	"github.com/opentofu/opentofu/internal/configs/configschema"
+	hofcontext "github.com/opentofu/opentofu/internal/hof/flow/context"
+	"github.com/opentofu/opentofu/internal/lang/marks"
 	"github.com/opentofu/opentofu/internal/providers"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -91,3 +95,75 @@ func TestBuildingEvalContextInitProvider(t *testing.T) {
 func testBuiltinEvalContext(t *testing.T) *BuiltinEvalContext {
 	return &BuiltinEvalContext{}
 }
+
+func TestBuiltinEvalContextUpdateHofCtxVariables(t *testing.T) {
+	ctx := testBuiltinEvalContext(t)
+
+	// a planned resource, partially known
+	ctx.UpdateHofCtxVariables("aws_instance.web", cty.ObjectVal(map[string]cty.Value{
+		"ami": cty.StringVal("ami-123"),
+		"id":  cty.UnknownVal(cty.String),
+		"ips": cty.UnknownVal(cty.List(cty.String)),
+	}), nil)
+	// a deferred data source read, wholly unknown
+	ctx.UpdateHofCtxVariables("data.aws_vpc.main", cty.UnknownVal(cty.Object(map[string]cty.Type{
+		"id": cty.String,
+	})), nil)
+	// nothing planned
+	ctx.UpdateHofCtxVariables("aws_instance.gone", cty.NullVal(cty.DynamicPseudoType), nil)
+
+	load := func(path ...string) interface{} {
+		var val interface{} = ctx.TfContext.ParsedVariables
+		for _, p := range path {
+			m, ok := val.(*sync.Map)
+			if !ok {
+				t.Fatalf("%v: not a map: %#v", path, val)
+			}
+			val, _ = m.Load(p)
+		}
+		return val
+	}
+
+	if got := load("aws_instance", "web", "ami"); got != "ami-123" {
+		t.Errorf("ami: got %#v", got)
+	}
+	if got, want := load("aws_instance", "web", "id"), hofcontext.NewUnknownValue(cty.String); got != want {
+		t.Errorf("id: got %#v, want %#v", got, want)
+	}
+	if got, want := load("aws_instance", "web", "ips"), (hofcontext.UnknownValue{Type: "list(string)"}); got != want {
+		t.Errorf("ips: got %#v, want %#v", got, want)
+	}
+	if got, want := load("data", "aws_vpc", "main"), (hofcontext.UnknownValue{Type: "object({id=string})"}); got != want {
+		t.Errorf("data source: got %#v, want %#v", got, want)
+	}
+	if got := load("aws_instance", "gone"); got != nil {
+		t.Errorf("null value: got %#v", got)
+	}
+}
+
+func TestBuiltinEvalContextUpdateHofCtxVariablesSensitive(t *testing.T) {
+	ctx := testBuiltinEvalContext(t)
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name":     {Type: cty.String, Optional: true},
+			"password": {Type: cty.String, Optional: true, Sensitive: true},
+			"tags":     {Type: cty.Map(cty.String), Optional: true},
+		},
+	}
+
+	ctx.UpdateHofCtxVariables("aws_db_instance.main[0]", cty.ObjectVal(map[string]cty.Value{
+		"name":     cty.StringVal("db"),
+		"password": cty.StringVal("hunter2"),
+		// sensitive by the config, e.g. set from a sensitive variable
+		"tags": cty.MapVal(map[string]cty.Value{
+			"owner": cty.StringVal("ops").Mark(marks.Sensitive),
+		}),
+	}), schema)
+
+	got := ctx.TfContext.Sensitive()
+	sort.Strings(got)
+	want := []string{"aws_db_instance.main.0.password", "aws_db_instance.main.0.tags.owner"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sensitive paths: got %#v, want %#v", got, want)
+	}
+}
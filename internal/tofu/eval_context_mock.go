@@ -187,7 +187,10 @@ func (c *MockEvalContext) Input() UIInput {
 	return c.InputInput
 }
 
-func (ctx *MockEvalContext) UpdateHofCtxVariables(key string, vars cty.Value) *sync.Map {
+func (ctx *MockEvalContext) UpdateHofCtxVariables(key string, vars cty.Value, schema *configschema.Block) *sync.Map {
+	if ctx.TfContext == nil || ctx.TfContext.ParsedVariables == nil || !vars.IsKnown() {
+		return nil
+	}
 	(*ctx.TfContext.ParsedVariables).Store(key, vars.AsValueMap())
 	return ctx.TfContext.ParsedVariables
 }
@@ -638,7 +638,7 @@ func (n *NodeAbstractResourceInstance) refresh(ctx EvalContext, deposedKey state
 	}
 	newVal := resp.NewState
 	if !newVal.IsNull() {
-		ctx.UpdateHofCtxVariables(n.Addr.String(), newVal) // @todo: change this to do a deepcopy of the value. Currently it is flattening the keys as "key1.key2[0]"
+		ctx.UpdateHofCtxVariables(n.Addr.String(), newVal, schema) // @todo: change this to do a deepcopy of the value. Currently it is flattening the keys as "key1.key2[0]"
 	}
 
 	ret := state.DeepCopy()
@@ -1153,6 +1153,12 @@ func (n *NodeAbstractResourceInstance) plan(
 		return nil, nil, keyData, diags
 	}
 
+	// Expose the planned value, including any values which are only known
+	// after apply, so that dependent mantis tasks can be planned as well.
+	if !plannedNewVal.IsNull() {
+		ctx.UpdateHofCtxVariables(n.Addr.String(), plannedNewVal, schema)
+	}
+
 	// Update our return plan
 	plan := &plans.ResourceInstanceChange{
 		Addr:         n.Addr,
@@ -1725,6 +1731,9 @@ func (n *NodeAbstractResourceInstance) planDataSource(ctx EvalContext, checkRule
 			return h.PostDiff(n.Addr, states.CurrentGen, plans.Read, priorVal, proposedNewVal)
 		}))
 
+		// The read is deferred, expose its placeholder value to mantis
+		ctx.UpdateHofCtxVariables(n.Addr.String(), proposedNewVal, schema)
+
 		return plannedChange, plannedNewState, keyData, diags
 	}
 
@@ -1732,7 +1741,7 @@ func (n *NodeAbstractResourceInstance) planDataSource(ctx EvalContext, checkRule
 	// can read the data source into the state.
 	newVal, readDiags := n.readDataSource(ctx, configVal)
 
-	ctx.UpdateHofCtxVariables(n.Addr.String(), newVal)
+	ctx.UpdateHofCtxVariables(n.Addr.String(), newVal, schema)
 	// Now we've loaded the data, and diags tells us whether we were successful
 	// or not, we are going to create our plannedChange and our
 	// proposedNewState.
@@ -2360,7 +2369,7 @@ func (n *NodeAbstractResourceInstance) apply(
 	// incomplete.
 	newVal := resp.NewState
 	if !newVal.IsNull() {
-		ctx.UpdateHofCtxVariables(n.Addr.String(), newVal) // @todo: change this to do a deepcopy of the value. Currently it is flattening the keys as "key1.key2[0]"
+		ctx.UpdateHofCtxVariables(n.Addr.String(), newVal, schema) // @todo: change this to do a deepcopy of the value. Currently it is flattening the keys as "key1.key2[0]"
 	}
 
 	// If we have paths to mark, mark those on this new value
@@ -9,6 +9,8 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 
 	"github.com/hashicorp/go-plugin"
 	svchost "github.com/hashicorp/terraform-svchost"
@@ -55,6 +57,8 @@ var hiddenCommands map[string]struct{}
 // Ui is the cli.Ui used for communicating to the outside world.
 var Ui cli.Ui
 
+var commandsMu sync.Mutex
+
 // InitCommandsWrapper initializes the commands and returns the Commands map.
 func InitCommandsWrapper(
 	ctx context.Context,
@@ -67,6 +71,11 @@ func InitCommandsWrapper(
 	unmanagedProviders map[addrs.Provider]*plugin.ReattachConfig,
 	configDetails *configs.MantisConfig,
 ) map[string]cli.CommandFactory {
+	// Tasks run concurrently, each with its own configuration, while
+	// InitCommands publishes the commands through a package variable
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+
 	// Call the existing InitCommands function
 	InitCommands(ctx, originalWorkingDir, streams, config, services, providerSrc, providerDevOverrides, unmanagedProviders, configDetails)
 
@@ -106,6 +115,18 @@ func InitCommands(
 	}
 
 	wd := WorkingDir(originalWorkingDir, os.Getenv("TF_DATA_DIR"))
+	if configDetails != nil && configDetails.WorkingDir != "" && os.Getenv("TF_DATA_DIR") == "" {
+		wd.OverrideDataDir(filepath.Join(configDetails.WorkingDir, ".terraform"))
+	}
+
+	// mantis cancels the context of its flow on SIGINT/SIGTERM instead of
+	// letting every task install its own signal handler
+	var shutdownCh <-chan struct{}
+	if configDetails != nil && ctx != nil {
+		shutdownCh = contextShutdownCh(ctx)
+	} else {
+		shutdownCh = makeShutdownCh()
+	}
 
 	meta := command.Meta{
 		WorkingDir: wd,
@@ -125,7 +146,7 @@ func InitCommands(
 
 		PluginCacheMayBreakDependencyLockFile: config.PluginCacheMayBreakDependencyLockFile,
 
-		ShutdownCh:    makeShutdownCh(),
+		ShutdownCh:    shutdownCh,
 		CallerContext: ctx,
 
 		ProviderSource:       providerSrc,
@@ -506,6 +527,17 @@ func makeShutdownCh() <-chan struct{} {
 	return resultCh
 }
 
+// contextShutdownCh returns a channel which receives once ctx is done,
+// asking running operations to stop gracefully
+func contextShutdownCh(ctx context.Context) <-chan struct{} {
+	resultCh := make(chan struct{}, 1)
+	go func() {
+		<-ctx.Done()
+		resultCh <- struct{}{}
+	}()
+	return resultCh
+}
+
 func credentialsSource(config *cliconfig.Config) (auth.CredentialsSource, error) {
 	helperPlugins := pluginDiscovery.FindPlugins("credentials", globalPluginDirs())
 	return config.CredentialsSource(helperPlugins)